@@ -1,12 +1,14 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
 	"net"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -19,7 +21,7 @@ func main() {
 	clientCount := utils.GetClientCount()
 	clients := make([]net.Conn, 0, clientCount)
 	proto := "tcp"
-	addr := fmt.Sprintf("%s:%d", utils.GetAddr(), utils.GetPort())
+	addr := net.JoinHostPort(utils.GetAddr(), strconv.FormatUint(utils.GetPort(), 10))
 	delay := utils.GetDelay()
 
 	for i := 0; i < int(clientCount); i++ {
@@ -88,6 +90,11 @@ func read_write(clients []net.Conn, stopChan chan os.Signal) bool {
 
 						resp := new(op.Value)
 						if _, err := resp.ReadFrom(conn); err != nil {
+							if errors.Is(err, op.ErrNotFound) {
+								log.Printf("Read %s => not found [%s]\n", key, conn.LocalAddr())
+								return
+							}
+
 							log.Printf("Failed to read response : %s\n", err.Error())
 							return
 						}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/itzmeanjan/tseep/config"
+	"github.com/itzmeanjan/tseep/persist"
+	"github.com/itzmeanjan/tseep/pubsub"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+func getWatcherCount() uint {
+	if wc, ok := os.LookupEnv("WATCHER_COUNT"); ok {
+		if parsed, err := strconv.ParseUint(wc, 10, 64); err == nil {
+			return uint(parsed)
+		}
+	}
+
+	return config.DefaultWatcherCount
+}
+
+func getShardCount() uint {
+	if sc, ok := os.LookupEnv("SHARD_COUNT"); ok {
+		if parsed, err := strconv.ParseUint(sc, 10, 64); err == nil {
+			return uint(parsed)
+		}
+	}
+
+	return 16
+}
+
+// getMaxMemory returns max total length of keys & values, where zero
+// denotes memory isn't bounded
+func getMaxMemory() uint64 {
+	if size, ok := os.LookupEnv("MAX_MEMORY"); ok {
+		if parsed, err := strconv.ParseUint(size, 10, 64); err == nil {
+			return parsed
+		}
+	}
+
+	return 0
+}
+
+func getEvictionPolicy() store.EvictionPolicy {
+	if policy, ok := os.LookupEnv("EVICTION_POLICY"); ok {
+		if parsed, err := store.ParseEvictionPolicy(policy); err == nil {
+			return parsed
+		}
+	}
+
+	return store.NoEviction
+}
+
+func getMaxFrameSize() uint32 {
+	if size, ok := os.LookupEnv("MAX_FRAME_SIZE"); ok {
+		if parsed, err := strconv.ParseUint(size, 10, 32); err == nil {
+			return uint32(parsed)
+		}
+	}
+
+	return config.DefaultMaxFrameSize
+}
+
+// getAOFPath returns path of append-only file, where empty path denotes
+// persistence is disabled
+func getAOFPath() string {
+	if path, ok := os.LookupEnv("AOF_PATH"); ok {
+		return path
+	}
+
+	return ""
+}
+
+// getSnapshotPath returns path of snapshot file, where empty path denotes
+// snapshots are disabled
+func getSnapshotPath() string {
+	if path, ok := os.LookupEnv("SNAPSHOT_PATH"); ok {
+		return path
+	}
+
+	return ""
+}
+
+func getAOFFsync() persist.FsyncPolicy {
+	if policy, ok := os.LookupEnv("AOF_FSYNC"); ok {
+		if parsed, err := persist.ParseFsyncPolicy(policy); err == nil {
+			return parsed
+		}
+	}
+
+	return persist.FsyncEverySec
+}
+
+func getPushQueueSize() int {
+	if size, ok := os.LookupEnv("PUSH_QUEUE_SIZE"); ok {
+		if parsed, err := strconv.ParseUint(size, 10, 31); err == nil {
+			return int(parsed)
+		}
+	}
+
+	return pubsub.DefaultQueueSize
+}
+
+func getPushPolicy() pubsub.Policy {
+	if policy, ok := os.LookupEnv("PUSH_POLICY"); ok {
+		if parsed, err := pubsub.ParsePolicy(policy); err == nil {
+			return parsed
+		}
+	}
+
+	return pubsub.DropMessage
+}
+
+// getShutdownTimeout returns how long server waits for requests in flight
+// to be answered, when shutting down, before closing connections
+func getShutdownTimeout() time.Duration {
+	if timeout, ok := os.LookupEnv("SHUTDOWN_TIMEOUT"); ok {
+		if parsed, err := time.ParseDuration(timeout); err == nil {
+			return parsed
+		}
+	}
+
+	return 5 * time.Second
+}
+
+func getIdleTimeout() time.Duration {
+	if timeout, ok := os.LookupEnv("IDLE_TIMEOUT"); ok {
+		if parsed, err := time.ParseDuration(timeout); err == nil {
+			return parsed
+		}
+	}
+
+	return 0
+}
+
+func getHeaderTimeout() time.Duration {
+	if timeout, ok := os.LookupEnv("HEADER_TIMEOUT"); ok {
+		if parsed, err := time.ParseDuration(timeout); err == nil {
+			return parsed
+		}
+	}
+
+	return config.DefaultHeaderTimeout
+}
+
+func getBodyTimeout() time.Duration {
+	if timeout, ok := os.LookupEnv("BODY_TIMEOUT"); ok {
+		if parsed, err := time.ParseDuration(timeout); err == nil {
+			return parsed
+		}
+	}
+
+	return config.DefaultBodyTimeout
+}
+
+func getMaxConns() int {
+	if max, ok := os.LookupEnv("MAX_CONNS"); ok {
+		if parsed, err := strconv.ParseUint(max, 10, 31); err == nil {
+			return int(parsed)
+		}
+	}
+
+	return 0
+}
+
+func getMaxConnsPerIP() int {
+	if max, ok := os.LookupEnv("MAX_CONNS_PER_IP"); ok {
+		if parsed, err := strconv.ParseUint(max, 10, 31); err == nil {
+			return int(parsed)
+		}
+	}
+
+	return 0
+}
+
+func getMaxInFlight() int {
+	if max, ok := os.LookupEnv("MAX_IN_FLIGHT"); ok {
+		if parsed, err := strconv.ParseUint(max, 10, 31); err == nil {
+			return int(parsed)
+		}
+	}
+
+	return config.DefaultMaxInFlight
+}
+
+// getMode returns name of server mode to be started, one of registered
+// ones
+func getMode() string {
+	if mode, ok := os.LookupEnv("MODE"); ok {
+		return mode
+	}
+
+	return "v3"
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/itzmeanjan/tseep/config"
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/persist"
+	"github.com/itzmeanjan/tseep/server"
+	"github.com/itzmeanjan/tseep/store"
+	"github.com/itzmeanjan/tseep/utils"
+
+	// every server mode is registered, when its package is imported
+	_ "github.com/itzmeanjan/tseep/v1"
+	_ "github.com/itzmeanjan/tseep/v2"
+	_ "github.com/itzmeanjan/tseep/v3"
+	_ "github.com/itzmeanjan/tseep/v4"
+)
+
+func main() {
+	mode := flag.String("mode", getMode(), fmt.Sprintf("server mode, one of %s", strings.Join(server.Modes(), ", ")))
+	flag.Parse()
+
+	var st store.Store = store.NewSharded(getShardCount(), expiry.System, store.WithMaxMemory(getMaxMemory(), getEvictionPolicy()))
+
+	// snapshot is loaded first, as append-only file holds records
+	// appended since it was saved
+	snapshotPath := getSnapshotPath()
+	if snapshotPath != "" {
+		if err := persist.LoadSnapshot(snapshotPath, st, expiry.System); err != nil {
+			log.Printf("Failed to load snapshot : %s\n", err.Error())
+			return
+		}
+	}
+
+	var aof *persist.AOF
+	if path := getAOFPath(); path != "" {
+		var err error
+		if aof, err = persist.OpenAOF(path, getAOFFsync(), st, expiry.System); err != nil {
+			log.Printf("Failed to open append-only file : %s\n", err.Error())
+			return
+		}
+
+		st = aof
+	}
+
+	var snapshotter *persist.Snapshotter
+	if snapshotPath != "" {
+		snapshotter = persist.NewSnapshotter(snapshotPath, st, expiry.System)
+		st = snapshotter
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	srv, err := server.New(ctx, *mode, "tcp", fmt.Sprintf("%s:%d", utils.GetAddr(), utils.GetPort()), st, config.WithMaxFrameSize(getMaxFrameSize()), config.WithPushQueue(getPushQueueSize(), getPushPolicy()), config.WithTimeouts(getIdleTimeout(), getHeaderTimeout(), getBodyTimeout()), config.WithMaxConns(getMaxConns(), getMaxConnsPerIP()), config.WithWatchers(getWatcherCount()), config.WithMaxInFlight(getMaxInFlight()))
+	if err != nil {
+		log.Printf("Failed to start server : %s\n", err.Error())
+		return
+	}
+
+	log.Printf("Server [ %s ] listening on %s\n", *mode, srv.Addr())
+
+	interruptChan := make(chan os.Signal, 1)
+	signal.Notify(interruptChan, syscall.SIGTERM, syscall.SIGINT)
+	<-interruptChan
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(ctx, getShutdownTimeout())
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Closed connections with requests in flight : %s\n", err.Error())
+	}
+	cancelShutdown()
+	cancel()
+
+	if snapshotter != nil {
+		snapshotter.Close()
+	}
+
+	if aof != nil {
+		if err := aof.Close(); err != nil {
+			log.Printf("Failed to close append-only file : %s\n", err.Error())
+		}
+	}
+
+	log.Println("Graceful shutdown")
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"time"
+
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/pubsub"
+)
+
+// DefaultMaxFrameSize is used when server is started without explicitly
+// setting max frame size
+const DefaultMaxFrameSize = 1 << 20
+
+// DefaultReapInterval is how often expired keys are swept, unless set
+// otherwise
+const DefaultReapInterval = 100 * time.Millisecond
+
+// DefaultHeaderTimeout & DefaultBodyTimeout bound how long a partially
+// received request may keep its connection, unless set otherwise
+const (
+	DefaultHeaderTimeout = 10 * time.Second
+	DefaultBodyTimeout   = 30 * time.Second
+)
+
+// DefaultWatcherCount is how many event loops are run by server, which
+// runs more than one, unless set otherwise
+const DefaultWatcherCount = 8
+
+// DefaultMaxInFlight is how many tagged requests of one connection may
+// be served at once, unless set otherwise
+const DefaultMaxInFlight = 64
+
+// Config holds settings, shared by all server implementations
+type Config struct {
+	// MaxFrameSize is largest request body length server accepts.
+	// Client declaring a larger body is sent an error frame & disconnected.
+	MaxFrameSize uint32
+	// ReapInterval is how often background sweeper looks for expired
+	// keys, which were never accessed after expiring
+	ReapInterval time.Duration
+	// PushQueueSize is how many pushed messages may be waiting to be
+	// written to subscribed connection, before `PushPolicy` kicks in
+	PushQueueSize int
+	PushPolicy    pubsub.Policy
+	// IdleTimeout is how long connection may wait before sending next
+	// request, unless it's subscribed or parked. HeaderTimeout &
+	// BodyTimeout are how long envelope & body of request may take to
+	// be received, from when its first byte & envelope are received,
+	// respectively. Connection taking any longer is closed, while zero
+	// timeout means no limit.
+	IdleTimeout   time.Duration
+	HeaderTimeout time.Duration
+	BodyTimeout   time.Duration
+	// MaxConns is how many connections server keeps open, while
+	// MaxConnsPerIP is how many of them may come from same IP address.
+	// Connection over either limit is sent an error frame & closed,
+	// while zero means no limit.
+	MaxConns      int
+	MaxConnsPerIP int
+	// WatcherCount is how many event loops connections are spread over,
+	// by server running more than one
+	WatcherCount uint
+	// MaxInFlight is how many tagged requests of one connection may be
+	// served at once, by server serving them concurrently. Connection
+	// isn't read any further, while as many are in flight, while zero
+	// means they're served one after another.
+	MaxInFlight int
+}
+
+// Option updates one setting of server config
+type Option func(*Config)
+
+// New returns config with defaults, updated with given options
+func New(opts ...Option) Config {
+	cfg := Config{
+		MaxFrameSize:  DefaultMaxFrameSize,
+		ReapInterval:  DefaultReapInterval,
+		PushQueueSize: pubsub.DefaultQueueSize,
+		PushPolicy:    pubsub.DropMessage,
+		HeaderTimeout: DefaultHeaderTimeout,
+		BodyTimeout:   DefaultBodyTimeout,
+		WatcherCount:  DefaultWatcherCount,
+		MaxInFlight:   DefaultMaxInFlight,
+	}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return cfg
+}
+
+func WithMaxFrameSize(size uint32) Option {
+	return func(c *Config) {
+		c.MaxFrameSize = size
+	}
+}
+
+func WithReapInterval(interval time.Duration) Option {
+	return func(c *Config) {
+		c.ReapInterval = interval
+	}
+}
+
+func WithPushQueue(size int, policy pubsub.Policy) Option {
+	return func(c *Config) {
+		c.PushQueueSize = size
+		c.PushPolicy = policy
+	}
+}
+
+func WithTimeouts(idle, header, body time.Duration) Option {
+	return func(c *Config) {
+		c.IdleTimeout = idle
+		c.HeaderTimeout = header
+		c.BodyTimeout = body
+	}
+}
+
+func WithMaxConns(total, perIP int) Option {
+	return func(c *Config) {
+		c.MaxConns = total
+		c.MaxConnsPerIP = perIP
+	}
+}
+
+func WithWatchers(count uint) Option {
+	return func(c *Config) {
+		c.WatcherCount = count
+	}
+}
+
+func WithMaxInFlight(n int) Option {
+	return func(c *Config) {
+		c.MaxInFlight = n
+	}
+}
+
+// Timeout returns how long connection may take to send part of request,
+// which decoder is awaiting
+func (c Config) Timeout(stage op.Stage) time.Duration {
+	switch stage {
+	case op.AwaitingEnvelope:
+		return c.HeaderTimeout
+	case op.AwaitingBody:
+		return c.BodyTimeout
+	default:
+		return c.IdleTimeout
+	}
+}
+
+// ShortestTimeout returns shortest of timeouts, which are set, or zero,
+// when none is
+func (c Config) ShortestTimeout() time.Duration {
+	var shortest time.Duration
+	for _, timeout := range []time.Duration{c.IdleTimeout, c.HeaderTimeout, c.BodyTimeout} {
+		if timeout > 0 && (shortest == 0 || timeout < shortest) {
+			shortest = timeout
+		}
+	}
+
+	return shortest
+}
+
+// Deadline returns deadline of read, started at `since`, which may take
+// at most `timeout`, with zero time when it's not limited
+func Deadline(since time.Time, timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+
+	return since.Add(timeout)
+}
@@ -0,0 +1,46 @@
+package expiry
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells current time, against which key deadlines are checked
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// System is wall clock, used unless server is given some other clock
+var System Clock = systemClock{}
+
+// ManualClock only moves when told to, so that expiry can be tested
+// without sleeping
+type ManualClock struct {
+	lock *sync.Mutex
+	now  time.Time
+}
+
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{lock: &sync.Mutex{}, now: now}
+}
+
+func (m *ManualClock) Now() time.Time {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.now
+}
+
+// Advance moves clock forward by d
+func (m *ManualClock) Advance(d time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.now = m.now.Add(d)
+}
@@ -0,0 +1,123 @@
+package expiry
+
+import (
+	"context"
+	"time"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+// SampleSize is number of keys with TTL, examined by one sweep
+const SampleSize = 20
+
+// Table keeps deadlines of keys, which were written with some TTL.
+//
+// It's not safe for concurrent use, rather it's meant to be guarded by
+// same lock, which guards key-value store it's used with.
+type Table struct {
+	clock     Clock
+	deadlines map[op.Key]time.Time
+}
+
+func NewTable(clock Clock) *Table {
+	return &Table{clock: clock, deadlines: make(map[op.Key]time.Time)}
+}
+
+// Set makes key expire after ttl, replacing earlier deadline, if any
+func (t *Table) Set(key op.Key, ttl time.Duration) {
+	t.deadlines[key] = t.clock.Now().Add(ttl)
+}
+
+// Delete removes deadline of key, returning whether it had one
+func (t *Table) Delete(key op.Key) bool {
+	_, ok := t.deadlines[key]
+	delete(t.deadlines, key)
+	return ok
+}
+
+// Expired tells whether deadline of key has passed
+func (t *Table) Expired(key op.Key) bool {
+	deadline, ok := t.deadlines[key]
+	return ok && !t.clock.Now().Before(deadline)
+}
+
+// TTL returns time left till key expires, with false for key without
+// deadline
+func (t *Table) TTL(key op.Key) (time.Duration, bool) {
+	deadline, ok := t.deadlines[key]
+	if !ok {
+		return 0, false
+	}
+
+	if left := deadline.Sub(t.clock.Now()); left > 0 {
+		return left, true
+	}
+
+	return 0, true
+}
+
+// Len returns number of keys with deadline
+func (t *Table) Len() int {
+	return len(t.deadlines)
+}
+
+// Collect examines at most `sample` keys with deadline, removing &
+// returning those which have expired. Map iteration order is random, so
+// successive calls examine different keys.
+func (t *Table) Collect(sample int) []op.Key {
+	now := t.clock.Now()
+	expired := make([]op.Key, 0)
+
+	for key, deadline := range t.deadlines {
+		if sample <= 0 {
+			break
+		}
+
+		sample--
+		if !now.Before(deadline) {
+			expired = append(expired, key)
+		}
+	}
+
+	for _, key := range expired {
+		delete(t.deadlines, key)
+	}
+
+	return expired
+}
+
+// Sample calls fn with at most n keys with deadline. Map iteration order
+// is random, so successive calls see different keys.
+func (t *Table) Sample(n int, fn func(key op.Key, deadline time.Time)) {
+	for key, deadline := range t.deadlines {
+		if n <= 0 {
+			break
+		}
+
+		n--
+		fn(key, deadline)
+	}
+}
+
+// Reap calls sweep every interval, till ctx is done. Sweep is called
+// again right away, as long as it reports many keys were reclaimed, so
+// that a burst of expired keys doesn't linger in memory.
+func Reap(ctx context.Context, interval time.Duration, sweep func() bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			for sweep() {
+				if ctx.Err() != nil {
+					return
+				}
+			}
+
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package expiry_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/op"
+)
+
+func TestTable(t *testing.T) {
+	clock := expiry.NewManualClock(time.Unix(0, 0))
+	table := expiry.NewTable(clock)
+
+	table.Set("a", time.Second)
+	table.Set("b", time.Minute)
+
+	if table.Expired("a") || table.Expired("b") || table.Expired("c") {
+		t.Fatalf("Expected no key to be expired\n")
+	}
+
+	if ttl, ok := table.TTL("a"); !ok || ttl != time.Second {
+		t.Fatalf("Expected TTL of 1s, received %s\n", ttl)
+	}
+
+	if _, ok := table.TTL("c"); ok {
+		t.Fatalf("Expected no TTL for key without deadline\n")
+	}
+
+	clock.Advance(time.Second)
+	if !table.Expired("a") || table.Expired("b") {
+		t.Fatalf("Expected only `a` to be expired\n")
+	}
+
+	if ttl, ok := table.TTL("a"); !ok || ttl != 0 {
+		t.Fatalf("Expected TTL of 0s, received %s\n", ttl)
+	}
+
+	if !table.Delete("b") || table.Delete("b") {
+		t.Fatalf("Expected deadline of `b` to be deleted once\n")
+	}
+
+	if table.Expired("b") {
+		t.Fatalf("Expected key without deadline to never expire\n")
+	}
+}
+
+func TestCollect(t *testing.T) {
+	clock := expiry.NewManualClock(time.Unix(0, 0))
+	table := expiry.NewTable(clock)
+
+	for i := 0; i < 100; i++ {
+		table.Set(op.Key(fmt.Sprintf("key-%d", i)), time.Duration(i%2+1)*time.Second)
+	}
+
+	if keys := table.Collect(1000); len(keys) != 0 {
+		t.Fatalf("Expected no expired key, received %d\n", len(keys))
+	}
+
+	clock.Advance(time.Second)
+	collected := 0
+	for table.Len() > 50 {
+		keys := table.Collect(expiry.SampleSize)
+		if len(keys) > expiry.SampleSize {
+			t.Fatalf("Expected at most %d keys, received %d\n", expiry.SampleSize, len(keys))
+		}
+
+		collected += len(keys)
+	}
+
+	if collected != 50 {
+		t.Fatalf("Expected 50 expired keys, received %d\n", collected)
+	}
+}
+
+func TestReap(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sweeps := make(chan struct{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		expiry.Reap(ctx, time.Millisecond, func() bool {
+			sweeps <- struct{}{}
+			return false
+		})
+	}()
+
+	for i := 0; i < 3; i++ {
+		<-sweeps
+	}
+
+	cancel()
+	go func() {
+		for range sweeps {
+		}
+	}()
+
+	<-done
+	close(sweeps)
+}
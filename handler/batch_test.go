@@ -0,0 +1,101 @@
+package handler_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/handler"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+func TestBatch(t *testing.T) {
+	h := handler.New(store.NewSharded(4, expiry.System))
+
+	mSet := op.MSetRequest{Pairs: []op.Pair{
+		{Key: "a", Value: op.Value("1")},
+		{Key: "b", Value: op.Value("2")},
+		{Key: "a", Value: op.Value("3")},
+		{Key: "c", Value: op.Value{}},
+	}}
+	if err := roundTrip(t, h, &mSet, new(op.Value)); err != nil {
+		t.Fatalf("Failed to write : %s\n", err.Error())
+	}
+
+	for _, hdr := range []op.Header{{}, {Legacy: true}} {
+		resp := new(op.MGetResponse)
+		if err := roundTrip(t, h, &op.MGetRequest{Header: hdr, Keys: []op.Key{"a", "missing", "b", "c"}}, resp); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		expected := []op.Lookup{{Found: true, Value: op.Value("3")}, {}, {Found: true, Value: op.Value("2")}, {Found: true}}
+		if len(resp.Lookups) != len(expected) {
+			t.Fatalf("Expected %d lookups, received %d\n", len(expected), len(resp.Lookups))
+		}
+
+		for i := range expected {
+			if resp.Lookups[i].Found != expected[i].Found || string(resp.Lookups[i].Value) != string(expected[i].Value) {
+				t.Fatalf("Expected %+v, received %+v\n", expected[i], resp.Lookups[i])
+			}
+		}
+	}
+
+	if h.Store.Len() != 3 {
+		t.Fatalf("Expected 3 keys, found %d\n", h.Store.Len())
+	}
+}
+
+// TestBatchMemory fills store up to max memory, before MSET, which needs
+// room for its keys, where every key of batch must be written without
+// going over max memory
+func TestBatchMemory(t *testing.T) {
+	for _, st := range []store.Store{
+		store.NewMap(expiry.System, store.WithMaxMemory(40, store.AllKeysLRU)),
+		store.NewSharded(4, expiry.System, store.WithMaxMemory(40, store.AllKeysLRU)),
+	} {
+		// room for four keys, as each takes 10 bytes
+		h := handler.New(st)
+		val := op.Value("value")
+		for i := 0; i < 4; i++ {
+			key := op.Key(fmt.Sprintf("key-%d", i))
+			if err := roundTrip(t, h, &op.WriteRequest{Key: &key, Value: &val}, new(op.Value)); err != nil {
+				t.Fatalf("Failed to write : %s\n", err.Error())
+			}
+		}
+
+		// least recently used key is rewritten by batch, so it's the one
+		// to be evicted, if batch weren't reserved at once
+		keys := []op.Key{"key-0", "key-4", "key-5"}
+		mSet := op.MSetRequest{}
+		for _, key := range keys {
+			mSet.Pairs = append(mSet.Pairs, op.Pair{Key: key, Value: val})
+		}
+
+		if err := roundTrip(t, h, &mSet, new(op.Value)); err != nil {
+			t.Fatalf("Failed to write batch : %s\n", err.Error())
+		}
+
+		resp := new(op.MGetResponse)
+		if err := roundTrip(t, h, &op.MGetRequest{Keys: keys}, resp); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		for i, lookup := range resp.Lookups {
+			if !lookup.Found {
+				t.Fatalf("Expected `%s` of batch to be present\n", keys[i])
+			}
+		}
+
+		stats := new(op.StatsResponse)
+		if err := roundTrip(t, h, &op.StatsRequest{}, stats); err != nil {
+			t.Fatalf("Failed to read stats : %s\n", err.Error())
+		}
+
+		for name, expected := range map[string]uint64{"keys": 4, "used_memory": 40, "evicted_keys": 2} {
+			if v, ok := stats.Get(name); !ok || v != expected {
+				t.Fatalf("Expected `%s` = %d, received %d\n", name, expected, v)
+			}
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package handler_test
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/handler"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+// write is one successful conditional write, as seen by client
+type write struct {
+	version uint64
+	value   int
+}
+
+// TestCAS hammers one key from many clients. Each client increments it
+// using read-modify-CAS, retrying on conflict, so that linearizable CAS
+// makes every increment land exactly once, with values growing in order
+// of versions.
+func TestCAS(t *testing.T) {
+	const (
+		clients    = 8
+		increments = 50
+	)
+
+	h := handler.New(store.NewSharded(4, expiry.System))
+	key := op.Key("counter")
+	zero := op.Value("0")
+	if err := roundTrip(t, h, &op.WriteRequest{Key: &key, Value: &zero}, new(op.Value)); err != nil {
+		t.Fatalf("Failed to write : %s\n", err.Error())
+	}
+
+	var (
+		wg     sync.WaitGroup
+		lock   sync.Mutex
+		writes []write
+	)
+
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(byValue bool) {
+			defer wg.Done()
+
+			for done := 0; done < increments; {
+				cur := new(op.VersionedValue)
+				if err := roundTrip(t, h, &op.ReadVersionRequest{ReadRequest: op.ReadRequest{Key: &key}}, cur); err != nil {
+					t.Errorf("Failed to read : %s\n", err.Error())
+					return
+				}
+
+				n, _ := strconv.Atoi(string(cur.Value))
+				next := op.Value(strconv.Itoa(n + 1))
+
+				// half of clients match on value, others on version
+				req := op.CASRequest{Key: &key, Value: &next, Version: cur.Version}
+				if byValue {
+					req.Expected = &cur.Value
+				}
+
+				res := new(op.WriteResult)
+				if err := roundTrip(t, h, &req, res); err != nil {
+					t.Errorf("Failed to CAS : %s\n", err.Error())
+					return
+				}
+
+				if !res.Applied {
+					if res.Version == cur.Version && !byValue {
+						t.Errorf("CAS on current version %d wasn't applied\n", cur.Version)
+						return
+					}
+
+					continue
+				}
+
+				lock.Lock()
+				writes = append(writes, write{version: res.Version, value: n + 1})
+				lock.Unlock()
+				done++
+			}
+		}(i%2 == 0)
+	}
+
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+
+	if len(writes) != clients*increments {
+		t.Fatalf("Expected %d successful CAS, found %d\n", clients*increments, len(writes))
+	}
+
+	sort.Slice(writes, func(i, j int) bool { return writes[i].version < writes[j].version })
+	for i, w := range writes {
+		if w.value != i+1 || (i > 0 && w.version == writes[i-1].version) {
+			t.Fatalf("Expected write #%d to set %d with unique version, found %+v\n", i, i+1, w)
+		}
+	}
+
+	// only one of racing SETNX wins, while others see its version
+	lockKey := op.Key("lock")
+	results := make([]op.WriteResult, clients)
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			owner := op.Value(strconv.Itoa(i))
+			req := op.CondWriteRequest{WriteRequest: op.WriteRequest{Key: &lockKey, Value: &owner}}
+			if err := roundTrip(t, h, &req, &results[i]); err != nil {
+				t.Errorf("Failed to SETNX : %s\n", err.Error())
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	winners := 0
+	for _, res := range results {
+		if res.Applied {
+			winners++
+		}
+	}
+
+	for _, res := range results {
+		if res.Version != results[0].Version {
+			t.Fatalf("Expected all SETNX to report version of winner, received %+v\n", results)
+		}
+	}
+
+	if winners != 1 {
+		t.Fatalf("Expected exactly one SETNX to win, %d did\n", winners)
+	}
+
+	// SETXX writes only present keys
+	val := op.Value("v")
+	for k, applied := range map[op.Key]bool{"lock": true, "missing": false} {
+		k := k
+		res := new(op.WriteResult)
+		req := op.CondWriteRequest{WriteRequest: op.WriteRequest{Key: &k, Value: &val}, IfExists: true}
+		if err := roundTrip(t, h, &req, res); err != nil {
+			t.Fatalf("Failed to SETXX : %s\n", err.Error())
+		}
+
+		if res.Applied != applied || (!applied && res.Version != 0) {
+			t.Fatalf("Expected SETXX of `%s` to be applied = %v, received %+v\n", k, applied, res)
+		}
+	}
+
+	// CAS never matches missing key
+	res := new(op.WriteResult)
+	missing := op.Key("missing")
+	if err := roundTrip(t, h, &op.CASRequest{Key: &missing, Value: &val, Version: 0}, res); err != nil || res.Applied {
+		t.Fatalf("Expected CAS of missing key to fail, received %+v [%v]\n", res, err)
+	}
+}
@@ -0,0 +1,105 @@
+package handler_test
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/handler"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+// TestCounter increments & decrements one counter from many clients,
+// where no update may be lost
+func TestCounter(t *testing.T) {
+	const (
+		clients = 8
+		rounds  = 100
+	)
+
+	h := handler.New(store.NewSharded(4, expiry.System))
+	key := op.Key("hits")
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// every round adds 3 - 1
+			for j := 0; j < rounds; j++ {
+				for _, req := range []op.IncrRequest{{Key: &key, Delta: 3}, {Key: &key, Delta: 1, Decrement: true}} {
+					req := req
+					if err := roundTrip(t, h, &req, new(op.IncrResponse)); err != nil {
+						t.Errorf("Failed to update counter : %s\n", err.Error())
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+
+	resp := new(op.IncrResponse)
+	if err := roundTrip(t, h, &op.IncrRequest{Key: &key, Delta: 0}, resp); err != nil || resp.Value != 2*clients*rounds {
+		t.Fatalf("Expected counter = %d, received %d [%v]\n", 2*clients*rounds, resp.Value, err)
+	}
+
+	// counter is stored in decimal, so it's readable as is
+	val := new(op.Value)
+	if err := roundTrip(t, h, &op.ReadRequest{Key: &key}, val); err != nil || string(*val) != "1600" {
+		t.Fatalf("Expected `1600`, received `%s` [%v]\n", *val, err)
+	}
+
+	incr := func(delta int64, decrement bool) int64 {
+		resp := new(op.IncrResponse)
+		if err := roundTrip(t, h, &op.IncrRequest{Key: &key, Delta: delta, Decrement: decrement}, resp); err != nil {
+			t.Fatalf("Failed to add %d : %s\n", delta, err.Error())
+		}
+
+		return resp.Value
+	}
+
+	// result must fit in 64 bits, leaving value as it's otherwise
+	if v := incr(math.MaxInt64-1600, false); v != math.MaxInt64 {
+		t.Fatalf("Expected %d, received %d\n", int64(math.MaxInt64), v)
+	}
+
+	expectError(t, h, &op.IncrRequest{Key: &key, Delta: 1}, op.Overflow)
+	expectError(t, h, &op.IncrRequest{Key: &key, Delta: -1, Decrement: true}, op.Overflow)
+	if err := roundTrip(t, h, &op.ReadRequest{Key: &key}, val); err != nil || string(*val) != fmt.Sprint(int64(math.MaxInt64)) {
+		t.Fatalf("Expected %d, received `%s` [%v]\n", int64(math.MaxInt64), *val, err)
+	}
+
+	if v := incr(math.MaxInt64, true); v != 0 {
+		t.Fatalf("Expected 0, received %d\n", v)
+	}
+
+	if v := incr(math.MinInt64, false); v != math.MinInt64 {
+		t.Fatalf("Expected %d, received %d\n", int64(math.MinInt64), v)
+	}
+
+	expectError(t, h, &op.IncrRequest{Key: &key, Delta: 1, Decrement: true}, op.Overflow)
+	expectError(t, h, &op.IncrRequest{Key: &key, Delta: math.MinInt64}, op.Overflow)
+
+	// missing key counts from zero, while value, which isn't decimal
+	// integer, can't be counted
+	fresh := op.Key("fresh")
+	if err := roundTrip(t, h, &op.IncrRequest{Key: &fresh, Delta: -7, Decrement: true}, resp); err != nil || resp.Value != 7 {
+		t.Fatalf("Expected counter = 7, received %d [%v]\n", resp.Value, err)
+	}
+
+	text := op.Key("text")
+	word := op.Value("word")
+	if err := roundTrip(t, h, &op.WriteRequest{Key: &text, Value: &word}, new(op.Value)); err != nil {
+		t.Fatalf("Failed to write : %s\n", err.Error())
+	}
+
+	expectError(t, h, &op.IncrRequest{Key: &text, Delta: 1}, op.NotNumeric)
+}
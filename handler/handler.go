@@ -0,0 +1,615 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/pubsub"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+// Handler serves requests against a store. It's shared by all server
+// implementations, so that they differ only in how they do network I/O.
+type Handler struct {
+	Store   store.Store
+	Broker  *pubsub.Broker
+	waiters *waiters
+	// connections closed, as they took too long to send request
+	timedOut uint64
+	// connections rejected, as server had too many open
+	rejected uint64
+	// set while BGSAVE is saving snapshot in background
+	saving int32
+}
+
+// New creates handler for store. When store can tell about changes it
+// applies, they're fanned out to connections watching keys.
+func New(st store.Store) *Handler {
+	h := &Handler{Store: st, Broker: pubsub.NewBroker(), waiters: newWaiters()}
+	if n, ok := st.(store.Notifier); ok {
+		n.OnChange(func(ev op.Event) { h.Broker.Notify(ev) })
+	}
+
+	return h
+}
+
+// TimedOut counts connection, which server closed, as it took too long
+// to send request, which is reported by STATS
+func (h *Handler) TimedOut() {
+	atomic.AddUint64(&h.timedOut, 1)
+}
+
+// Rejected counts connection, which server rejected, as it was over
+// connection limits, which is reported by STATS
+func (h *Handler) Rejected() {
+	atomic.AddUint64(&h.rejected, 1)
+}
+
+// Stats returns counters, reported by STATS
+func (h *Handler) Stats() []op.Counter {
+	mem := h.Store.Memory()
+	return []op.Counter{
+		{Name: "keys", Value: uint64(h.Store.Len())},
+		{Name: "used_memory", Value: mem.Used},
+		{Name: "max_memory", Value: mem.Max},
+		{Name: "evicted_keys", Value: mem.Evicted},
+		{Name: "blocked_clients", Value: uint64(atomic.LoadInt64(&h.waiters.parked))},
+		{Name: "timed_out_connections", Value: atomic.LoadUint64(&h.timedOut)},
+		{Name: "rejected_connections", Value: atomic.LoadUint64(&h.rejected)},
+	}
+}
+
+// HandleSubscriber serves request of connection, whose subscriber is
+// sub. Besides requests served by `Handle`, it serves SUBSCRIBE,
+// UNSUBSCRIBE, KSUBSCRIBE & KUNSUBSCRIBE, which are the only ones
+// allowed, while connection is subscribed to any channel or key.
+func (h *Handler) HandleSubscriber(sub *pubsub.Subscriber, env op.Envelope, body []byte) op.Response {
+	switch env.Op {
+	case op.SUBSCRIBE, op.UNSUBSCRIBE:
+		sReq := &op.SubscribeRequest{Header: env.Header, Unsubscribe: env.Op == op.UNSUBSCRIBE}
+		if err := op.ReadBody(sReq, body); err != nil {
+			return err
+		}
+
+		if sReq.Unsubscribe {
+			return &op.SubscribeResponse{Count: uint32(h.Broker.Unsubscribe(sub, sReq.Channels))}
+		}
+
+		return &op.SubscribeResponse{Count: uint32(h.Broker.Subscribe(sub, sReq.Channels))}
+
+	case op.KSUBSCRIBE, op.KUNSUBSCRIBE:
+		kReq := &op.KeyspaceRequest{Header: env.Header, Unsubscribe: env.Op == op.KUNSUBSCRIBE}
+		if err := op.ReadBody(kReq, body); err != nil {
+			return err
+		}
+
+		if kReq.Unsubscribe {
+			return &op.SubscribeResponse{Count: uint32(h.Broker.Unwatch(sub, kReq.Patterns))}
+		}
+
+		return &op.SubscribeResponse{Count: uint32(h.Broker.Watch(sub, kReq.Patterns))}
+
+	default:
+		if h.Broker.Subscriptions(sub) != 0 {
+			return &op.Error{Code: op.NotAllowed, Message: fmt.Sprintf("opcode %d in push mode", env.Op)}
+		}
+
+		return h.Handle(env, body)
+
+	}
+}
+
+// HandleBlocking serves request like `HandleSubscriber`, besides BRPOP,
+// which parks connection, when list is empty. Nil is returned for parked
+// request, whose response is passed to wake later, from another
+// goroutine, along with header of request. Connection must not serve any
+// other request, till then.
+func (h *Handler) HandleBlocking(sub *pubsub.Subscriber, env op.Envelope, body []byte, wake func(op.Header, op.Response)) op.Response {
+	if env.Op != op.BRPOP || h.Broker.Subscriptions(sub) != 0 {
+		return h.HandleSubscriber(sub, env, body)
+	}
+
+	bReq := &op.BlockingPopRequest{ReadRequest: op.ReadRequest{Header: env.Header}}
+	if err := op.ReadBody(bReq, body); err != nil {
+		return err
+	}
+
+	return h.blockingPop(sub, bReq, wake)
+}
+
+// Handle serves request with given envelope & body, returning response
+// to be written back to client. Failing requests are responded to with
+// error frame, so connection can keep serving next requests.
+func (h *Handler) Handle(env op.Envelope, body []byte) op.Response {
+	switch env.Op {
+	case op.READ:
+		rReq := &op.ReadRequest{Header: env.Header}
+		if err := op.ReadBody(rReq, body); err != nil {
+			return err
+		}
+
+		entry, ok := h.Store.Get(*rReq.Key)
+		if !ok {
+			return op.StatusNotFound
+		}
+
+		if entry.Type != store.String {
+			return wrongType
+		}
+
+		return &entry.Value
+
+	case op.WRITE:
+		wReq := &op.WriteRequest{Header: env.Header}
+		if err := op.ReadBody(wReq, body); err != nil {
+			return err
+		}
+
+		if err := h.Store.Reserve(*wReq.Key, len(*wReq.Key)+len(*wReq.Value)); err != nil {
+			return &op.Error{Code: op.OutOfMemory, Message: err.Error()}
+		}
+
+		h.Store.Set(*wReq.Key, store.Entry{Value: *wReq.Value, TTL: wReq.TTL})
+		return wReq.Value
+
+	case op.MGET:
+		mReq := &op.MGetRequest{Header: env.Header}
+		if err := op.ReadBody(mReq, body); err != nil {
+			return err
+		}
+
+		lookups := make([]op.Lookup, len(mReq.Keys))
+		for i, key := range mReq.Keys {
+			// key holding value of another type is taken as missing
+			entry, ok := h.Store.Get(key)
+			lookups[i] = op.Lookup{Found: ok && entry.Type == store.String, Value: entry.Value}
+		}
+
+		return &op.MGetResponse{Lookups: lookups}
+
+	case op.MSET:
+		mReq := &op.MSetRequest{Header: env.Header}
+		if err := op.ReadBody(mReq, body); err != nil {
+			return err
+		}
+
+		// repeated key keeps its last value
+		keys := make([]op.Key, 0, len(mReq.Pairs))
+		values := make(map[op.Key]op.Value, len(mReq.Pairs))
+		for _, pair := range mReq.Pairs {
+			if _, ok := values[pair.Key]; !ok {
+				keys = append(keys, pair.Key)
+			}
+
+			values[pair.Key] = pair.Value
+		}
+
+		// whole batch is reserved at once, so that making room for one
+		// key doesn't evict another
+		sizes := make(map[op.Key]int, len(keys))
+		for _, key := range keys {
+			sizes[key] = len(key) + len(values[key])
+		}
+
+		if err := h.Store.ReserveMany(sizes, nil); err != nil {
+			return &op.Error{Code: op.OutOfMemory, Message: err.Error()}
+		}
+
+		h.Store.UpdateMany(keys, func(entries []store.Entry, exist []bool) {
+			for i, key := range keys {
+				entries[i] = store.Entry{Value: values[key]}
+				exist[i] = true
+			}
+		})
+
+		return op.StatusOK
+
+	case op.GETV:
+		rReq := &op.ReadVersionRequest{ReadRequest: op.ReadRequest{Header: env.Header}}
+		if err := op.ReadBody(rReq, body); err != nil {
+			return err
+		}
+
+		entry, ok := h.Store.Get(*rReq.Key)
+		if ok && entry.Type != store.String {
+			return wrongType
+		}
+
+		return &op.VersionedValue{Found: ok, Value: entry.Value, Version: entry.Version}
+
+	case op.CAS:
+		cReq := &op.CASRequest{Header: env.Header}
+		if err := op.ReadBody(cReq, body); err != nil {
+			return err
+		}
+
+		return h.writeIf(*cReq.Key, store.Entry{Value: *cReq.Value}, func(entry store.Entry, ok bool) bool {
+			if !ok {
+				return false
+			}
+
+			if cReq.Expected != nil {
+				return bytes.Equal(entry.Value, *cReq.Expected)
+			}
+
+			return entry.Version == cReq.Version
+		})
+
+	case op.SETNX, op.SETXX:
+		cReq := &op.CondWriteRequest{WriteRequest: op.WriteRequest{Header: env.Header}, IfExists: env.Op == op.SETXX}
+		if err := op.ReadBody(cReq, body); err != nil {
+			return err
+		}
+
+		return h.writeIf(*cReq.Key, store.Entry{Value: *cReq.Value, TTL: cReq.TTL}, func(_ store.Entry, ok bool) bool {
+			return ok == cReq.IfExists
+		})
+
+	case op.INCRBY, op.DECRBY:
+		iReq := &op.IncrRequest{Header: env.Header, Decrement: env.Op == op.DECRBY}
+		if err := op.ReadBody(iReq, body); err != nil {
+			return err
+		}
+
+		// room for longest int64 in decimal
+		if err := h.Store.Reserve(*iReq.Key, len(*iReq.Key)+20); err != nil {
+			return &op.Error{Code: op.OutOfMemory, Message: err.Error()}
+		}
+
+		var sum int64
+		_, err := h.Store.Modify(*iReq.Key, func(entry store.Entry, ok bool) (store.Entry, error) {
+			var cur int64
+			if ok && entry.Type != store.String {
+				return entry, wrongType
+			}
+
+			if ok {
+				v, err := strconv.ParseInt(string(entry.Value), 10, 64)
+				if err != nil {
+					return entry, &op.Error{Code: op.NotNumeric, Message: "value isn't a 64-bit integer"}
+				}
+
+				cur = v
+			}
+
+			var fits bool
+			if sum, fits = add(cur, iReq.Delta, iReq.Decrement); !fits {
+				return entry, &op.Error{Code: op.Overflow, Message: "result doesn't fit in 64 bits"}
+			}
+
+			entry.Value = strconv.AppendInt(nil, sum, 10)
+			return entry, nil
+		})
+
+		if err != nil {
+			return opError(err)
+		}
+
+		return &op.IncrResponse{Value: sum}
+
+	case op.EXEC:
+		tReq := &op.TxRequest{Header: env.Header}
+		if err := op.ReadBody(tReq, body); err != nil {
+			return err
+		}
+
+		return h.exec(tReq)
+
+	case op.PUBLISH:
+		pReq := &op.PublishRequest{Header: env.Header}
+		if err := op.ReadBody(pReq, body); err != nil {
+			return err
+		}
+
+		return &op.PublishResponse{Receivers: uint32(h.Broker.Publish(*pReq.Channel, *pReq.Message))}
+
+	case op.LPUSH, op.RPUSH:
+		pReq := &op.PushRequest{ReadRequest: op.ReadRequest{Header: env.Header}, Left: env.Op == op.LPUSH}
+		if err := op.ReadBody(pReq, body); err != nil {
+			return err
+		}
+
+		return h.push(*pReq.Key, pReq.Values, pReq.Left)
+
+	case op.LPOP, op.RPOP:
+		pReq := &op.PopRequest{ReadRequest: op.ReadRequest{Header: env.Header}, Left: env.Op == op.LPOP}
+		if err := op.ReadBody(pReq, body); err != nil {
+			return err
+		}
+
+		val, ok, err := h.pop(*pReq.Key, pReq.Left)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return op.StatusNotFound
+		}
+
+		return &val
+
+	case op.LLEN:
+		lReq := &op.LenRequest{ReadRequest: op.ReadRequest{Header: env.Header}}
+		if err := op.ReadBody(lReq, body); err != nil {
+			return err
+		}
+
+		entry, ok := h.Store.Get(*lReq.Key)
+		if !ok {
+			return &op.LenResponse{}
+		}
+
+		items, err := list(entry)
+		if err != nil {
+			return err
+		}
+
+		return &op.LenResponse{Len: uint32(len(items))}
+
+	case op.LRANGE:
+		rReq := &op.RangeRequest{ReadRequest: op.ReadRequest{Header: env.Header}}
+		if err := op.ReadBody(rReq, body); err != nil {
+			return err
+		}
+
+		entry, ok := h.Store.Get(*rReq.Key)
+		if !ok {
+			return &op.ListResponse{}
+		}
+
+		items, err := list(entry)
+		if err != nil {
+			return err
+		}
+
+		start, stop := rReq.Bounds(len(items))
+		return &op.ListResponse{Items: items[start:stop]}
+
+	case op.BRPOP:
+		// parking is only possible for connections served through
+		// `HandleBlocking`
+		return &op.Error{Code: op.NotAllowed, Message: "blocking request on this connection"}
+
+	case op.DELETE:
+		dReq := &op.DeleteRequest{Header: env.Header}
+		if err := op.ReadBody(dReq, body); err != nil {
+			return err
+		}
+
+		return &op.DeleteResponse{Existed: h.Store.Delete(*dReq.Key)}
+
+	case op.EXPIRE:
+		eReq := &op.ExpireRequest{Header: env.Header}
+		if err := op.ReadBody(eReq, body); err != nil {
+			return err
+		}
+
+		var found bool
+		h.Store.Update(*eReq.Key, func(entry store.Entry, ok bool) (store.Entry, bool) {
+			found = ok
+			entry.TTL = eReq.TTL
+			if entry.TTL == 0 {
+				// zero TTL makes key expire right away
+				entry.TTL = -1
+			}
+
+			return entry, ok
+		})
+
+		if !found {
+			return op.StatusNotFound
+		}
+
+		return op.StatusOK
+
+	case op.TTL:
+		tReq := &op.TTLRequest{Header: env.Header}
+		if err := op.ReadBody(tReq, body); err != nil {
+			return err
+		}
+
+		entry, ok := h.Store.Get(*tReq.Key)
+		if !ok {
+			return &op.TTLResponse{Found: false}
+		}
+
+		if entry.TTL == 0 {
+			return &op.TTLResponse{Found: true, TTL: op.NoExpiry}
+		}
+
+		return &op.TTLResponse{Found: true, TTL: entry.TTL}
+
+	case op.PERSIST:
+		pReq := &op.PersistRequest{Header: env.Header}
+		if err := op.ReadBody(pReq, body); err != nil {
+			return err
+		}
+
+		var found bool
+		h.Store.Update(*pReq.Key, func(entry store.Entry, ok bool) (store.Entry, bool) {
+			found = ok
+			entry.TTL = 0
+			return entry, ok
+		})
+
+		if !found {
+			return op.StatusNotFound
+		}
+
+		return op.StatusOK
+
+	case op.SAVE, op.BGSAVE:
+		sReq := &op.SaveRequest{Header: env.Header, Background: env.Op == op.BGSAVE}
+		if err := op.ReadBody(sReq, body); err != nil {
+			return err
+		}
+
+		sv, ok := h.Store.(store.Saver)
+		if !ok {
+			return &op.Error{Code: op.Internal, Message: "snapshots aren't enabled"}
+		}
+
+		if sReq.Background {
+			if !atomic.CompareAndSwapInt32(&h.saving, 0, 1) {
+				return &op.Error{Code: op.Internal, Message: "snapshot is already being saved"}
+			}
+
+			go func() {
+				defer atomic.StoreInt32(&h.saving, 0)
+
+				if err := sv.Save(); err != nil {
+					log.Printf("Failed to save snapshot in background : %s\n", err.Error())
+				}
+			}()
+
+			return op.StatusOK
+		}
+
+		if err := sv.Save(); err != nil {
+			return &op.Error{Code: op.Internal, Message: err.Error()}
+		}
+
+		return op.StatusOK
+
+	case op.STATS:
+		if err := op.ReadBody(&op.StatsRequest{Header: env.Header}, body); err != nil {
+			return err
+		}
+
+		return &op.StatsResponse{Counters: h.Stats()}
+
+	case op.SCAN:
+		sReq := &op.ScanRequest{Header: env.Header}
+		if err := op.ReadBody(sReq, body); err != nil {
+			return err
+		}
+
+		start, end := sReq.Range()
+		limit := sReq.Count()
+
+		// one extra key is fetched, which becomes cursor of next scan
+		keys := h.Store.Range(start, end, limit+1)
+		if len(keys) > limit {
+			return &op.ScanResponse{Keys: keys[:limit], Cursor: keys[limit]}
+		}
+
+		return &op.ScanResponse{Keys: keys}
+
+	default:
+		return &op.Error{Code: op.BadOpcode, Message: fmt.Sprintf("opcode %d", env.Op)}
+
+	}
+}
+
+// writeIf writes entry, when cond holds for current entry of key,
+// reporting whether it got written along with version of key
+func (h *Handler) writeIf(key op.Key, entry store.Entry, cond func(store.Entry, bool) bool) op.Response {
+	if err := h.Store.Reserve(key, len(key)+len(entry.Value)); err != nil {
+		return &op.Error{Code: op.OutOfMemory, Message: err.Error()}
+	}
+
+	stored, applied := h.Store.SetIf(key, entry, cond)
+	return &op.WriteResult{Applied: applied, Version: stored.Version}
+}
+
+// exec runs transaction, while holding every key it touches, so that
+// it's isolated from requests served concurrently
+func (h *Handler) exec(tReq *op.TxRequest) op.Response {
+	// keys of watches & operations, each once
+	keys := make([]op.Key, 0, len(tReq.Watches)+len(tReq.Ops))
+	index := make(map[op.Key]int, cap(keys))
+	track := func(key op.Key) {
+		if _, ok := index[key]; !ok {
+			index[key] = len(keys)
+			keys = append(keys, key)
+		}
+	}
+
+	for _, w := range tReq.Watches {
+		track(w.Key)
+	}
+
+	for _, o := range tReq.Ops {
+		track(o.Key)
+	}
+
+	// room is made for what keys take, once all operations are applied,
+	// at once, so that no key of transaction is evicted for another
+	sizes := make(map[op.Key]int)
+	for _, o := range tReq.Ops {
+		switch o.Op {
+		case op.WRITE:
+			sizes[o.Key] = len(o.Key) + len(o.Value)
+		case op.DELETE:
+			sizes[o.Key] = 0
+		}
+	}
+
+	if err := h.Store.ReserveMany(sizes, keys); err != nil {
+		return &op.Error{Code: op.OutOfMemory, Message: err.Error()}
+	}
+
+	resp := new(op.TxResponse)
+	h.Store.ModifyMany(keys, func(entries []store.Entry, exist []bool, dirty []bool) error {
+		for _, w := range tReq.Watches {
+			i := index[w.Key]
+			if (exist[i] && entries[i].Version != w.Version) || (!exist[i] && w.Version != 0) {
+				resp.Aborted = true
+				return nil
+			}
+		}
+
+		resp.Results = make([]op.Lookup, len(tReq.Ops))
+		for j, o := range tReq.Ops {
+			i := index[o.Key]
+			switch o.Op {
+			case op.READ:
+				resp.Results[j] = op.Lookup{Found: exist[i] && entries[i].Type == store.String, Value: entries[i].Value}
+
+			case op.WRITE:
+				entries[i] = store.Entry{Value: o.Value, TTL: o.TTL}
+				exist[i], dirty[i] = true, true
+				resp.Results[j] = op.Lookup{Found: true}
+
+			case op.DELETE:
+				resp.Results[j] = op.Lookup{Found: exist[i]}
+				exist[i], dirty[i] = false, true
+
+			}
+		}
+
+		return nil
+	})
+
+	return resp
+}
+
+// opError returns error of store as it's, when it's failure of request
+// itself, otherwise as internal error, as store may fail for its own
+// reasons too, say failing to persist change
+func opError(err error) *op.Error {
+	var opErr *op.Error
+	if errors.As(err, &opErr) {
+		return opErr
+	}
+
+	return &op.Error{Code: op.Internal, Message: err.Error()}
+}
+
+// add returns cur + delta, or cur - delta when sub is set, reporting
+// false when result overflows int64, which shows up as result moving
+// opposite to sign of delta
+func add(cur, delta int64, sub bool) (int64, bool) {
+	if sub {
+		res := cur - delta
+		return res, (delta >= 0) == (res <= cur)
+	}
+
+	res := cur + delta
+	return res, (delta >= 0) == (res >= cur)
+}
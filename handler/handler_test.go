@@ -0,0 +1,147 @@
+package handler_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/handler"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+type request interface {
+	WriteEnvelope(io.Writer) (int64, error)
+	io.WriterTo
+}
+
+// serve encodes request, as client sends it, & has handler serve it,
+// returning header of request along with response
+func serve(t *testing.T, h *handler.Handler, req request) (op.Header, op.Response) {
+	buf := new(bytes.Buffer)
+	if _, err := req.WriteEnvelope(buf); err != nil {
+		t.Fatalf("Failed to write request envelope : %s\n", err.Error())
+	}
+
+	if _, err := req.WriteTo(buf); err != nil {
+		t.Fatalf("Failed to write request body : %s\n", err.Error())
+	}
+
+	dec := op.NewDecoder(1 << 20)
+	dec.Feed(buf.Bytes())
+	frame, ok, err := dec.Next()
+	if err != nil || !ok {
+		t.Fatalf("Failed to decode request : %v\n", err)
+	}
+
+	return frame.Header, h.Handle(frame.Envelope, frame.Body)
+}
+
+// roundTrip serves request & reads its response, as client would
+func roundTrip(t *testing.T, h *handler.Handler, req request, resp io.ReaderFrom) error {
+	hdr, res := serve(t, h, req)
+
+	buf := new(bytes.Buffer)
+	if _, err := op.WriteResponse(buf, hdr, res); err != nil {
+		t.Fatalf("Failed to write response : %s\n", err.Error())
+	}
+
+	_, err := resp.ReadFrom(buf)
+	return err
+}
+
+// expectError checks request is responded to with error of given code,
+// as returned by handler itself
+func expectError(t *testing.T, h *handler.Handler, req request, code op.ErrorCode) {
+	_, res := serve(t, h, req)
+
+	err, ok := res.(*op.Error)
+	if !ok {
+		t.Fatalf("Expected %s error, received %T\n", code, res)
+	}
+
+	if err.Code != code {
+		t.Fatalf("Expected %s error, received %s\n", code, err.Code)
+	}
+}
+
+func TestWrongType(t *testing.T) {
+	h := handler.New(store.NewMap(expiry.System))
+	text, items := op.Key("text"), op.Key("items")
+	word := op.Value("word")
+	if err := roundTrip(t, h, &op.WriteRequest{Key: &text, Value: &word}, new(op.Value)); err != nil {
+		t.Fatalf("Failed to write : %s\n", err.Error())
+	}
+
+	if err := roundTrip(t, h, &op.PushRequest{ReadRequest: op.ReadRequest{Key: &items}, Values: []op.Value{word}}, new(op.LenResponse)); err != nil {
+		t.Fatalf("Failed to push : %s\n", err.Error())
+	}
+
+	// errors of store callbacks are handed back as they're
+	expectError(t, h, &op.IncrRequest{Key: &items, Delta: 1}, op.WrongType)
+	expectError(t, h, &op.PushRequest{ReadRequest: op.ReadRequest{Key: &text}, Values: []op.Value{word}}, op.WrongType)
+	expectError(t, h, &op.PopRequest{ReadRequest: op.ReadRequest{Key: &text}}, op.WrongType)
+	expectError(t, h, &op.LenRequest{ReadRequest: op.ReadRequest{Key: &text}}, op.WrongType)
+	expectError(t, h, &op.ReadRequest{Key: &items}, op.WrongType)
+}
+
+// failingStore fails every change made through callback, as store
+// persisting it would, when it can't write
+type failingStore struct {
+	store.Store
+}
+
+func (failingStore) Modify(op.Key, func(store.Entry, bool) (store.Entry, error)) (store.Entry, error) {
+	return store.Entry{}, io.ErrShortWrite
+}
+
+func (failingStore) ModifyMany([]op.Key, func([]store.Entry, []bool, []bool) error) error {
+	return io.ErrShortWrite
+}
+
+func TestStoreError(t *testing.T) {
+	h := handler.New(failingStore{Store: store.NewMap(expiry.System)})
+	key := op.Key("key")
+
+	// errors, other than those of request itself, are internal ones
+	expectError(t, h, &op.IncrRequest{Key: &key, Delta: 1}, op.Internal)
+	expectError(t, h, &op.PushRequest{ReadRequest: op.ReadRequest{Key: &key}, Values: []op.Value{op.Value("v")}}, op.Internal)
+	expectError(t, h, &op.PopRequest{ReadRequest: op.ReadRequest{Key: &key}}, op.Internal)
+}
+
+// blockingSaver saves snapshot only once it's let to, as store writing
+// large snapshot would
+type blockingSaver struct {
+	store.Store
+	started, release chan struct{}
+}
+
+func (b blockingSaver) Save() error {
+	b.started <- struct{}{}
+	<-b.release
+	return nil
+}
+
+func TestBackgroundSave(t *testing.T) {
+	sv := blockingSaver{Store: store.NewMap(expiry.System), started: make(chan struct{}), release: make(chan struct{})}
+	h := handler.New(sv)
+
+	// responded to before snapshot is saved
+	if _, res := serve(t, h, &op.SaveRequest{Background: true}); res != op.StatusOK {
+		t.Fatalf("Expected BGSAVE to be responded to with OK, received %v\n", res)
+	}
+	<-sv.started
+
+	expectError(t, h, &op.SaveRequest{Background: true}, op.Internal)
+
+	sv.release <- struct{}{}
+	for {
+		_, res := serve(t, h, &op.SaveRequest{Background: true})
+		if res == op.StatusOK {
+			break
+		}
+	}
+	<-sv.started
+	sv.release <- struct{}{}
+}
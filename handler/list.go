@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/pubsub"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+// wrongType is sent back for requests, which expect value of key to be
+// a byte string, while it's a list
+var wrongType = &op.Error{Code: op.WrongType, Message: "value of key isn't a byte string"}
+
+// list returns items of entry, failing when it isn't a list
+func list(entry store.Entry) (op.List, *op.Error) {
+	if entry.Type != store.List {
+		return nil, &op.Error{Code: op.WrongType, Message: "value of key isn't a list"}
+	}
+
+	items, err := op.DecodeList(entry.Value)
+	if err != nil {
+		return nil, &op.Error{Code: op.Internal, Message: err.Error()}
+	}
+
+	return items, nil
+}
+
+// push pushes values to head or tail of list, one after another,
+// responding with length of list afterwards. Connections parked on key
+// are served right after.
+func (h *Handler) push(key op.Key, values []op.Value, left bool) op.Response {
+	if len(values) == 0 {
+		return &op.Error{Code: op.Malformed, Message: "no values to push"}
+	}
+
+	// room for list grown by values, each with its length
+	size := len(key) + 4
+	if entry, ok := h.Store.Get(key); ok {
+		size = len(key) + len(entry.Value)
+	}
+
+	for _, v := range values {
+		size += 4 + len(v)
+	}
+
+	if err := h.Store.Reserve(key, size); err != nil {
+		return &op.Error{Code: op.OutOfMemory, Message: err.Error()}
+	}
+
+	var length int
+	_, err := h.Store.Modify(key, func(entry store.Entry, ok bool) (store.Entry, error) {
+		var items op.List
+		if ok {
+			l, err := list(entry)
+			if err != nil {
+				return entry, err
+			}
+
+			items = l
+		}
+
+		if left {
+			pushed := make(op.List, 0, len(values)+len(items))
+			for i := len(values) - 1; i >= 0; i-- {
+				pushed = append(pushed, values[i])
+			}
+
+			items = append(pushed, items...)
+		} else {
+			items = append(items, values...)
+		}
+
+		length = len(items)
+		entry.Value, entry.Type = items.Encode(), store.List
+		return entry, nil
+	})
+
+	if err != nil {
+		return opError(err)
+	}
+
+	h.serveParked(key)
+	return &op.LenResponse{Len: uint32(length)}
+}
+
+// pop removes head or tail of list, reporting whether there was one.
+// List left empty is deleted.
+func (h *Handler) pop(key op.Key, left bool) (op.Value, bool, *op.Error) {
+	var (
+		val   op.Value
+		found bool
+	)
+
+	err := h.Store.ModifyMany([]op.Key{key}, func(entries []store.Entry, exist []bool, dirty []bool) error {
+		if !exist[0] {
+			return nil
+		}
+
+		items, err := list(entries[0])
+		if err != nil {
+			return err
+		}
+
+		if left {
+			val, items = items[0], items[1:]
+		} else {
+			val, items = items[len(items)-1], items[:len(items)-1]
+		}
+
+		found, dirty[0], exist[0] = true, true, len(items) != 0
+		entries[0].Value = items.Encode()
+		return nil
+	})
+
+	if err != nil {
+		return nil, false, opError(err)
+	}
+
+	return val, found, nil
+}
+
+// blockingPop pops tail of list, parking connection when list is empty,
+// in which case nil is returned
+func (h *Handler) blockingPop(sub *pubsub.Subscriber, bReq *op.BlockingPopRequest, wake func(op.Header, op.Response)) op.Response {
+	w := &waiter{key: *bReq.Key, sub: sub, hdr: bReq.Header, wake: wake}
+
+	h.waiters.lock.Lock()
+	defer h.waiters.lock.Unlock()
+
+	// parked connection is counted before list is looked at, so that
+	// value pushed meanwhile isn't missed by pusher
+	atomic.AddInt64(&h.waiters.parked, 1)
+	val, ok, err := h.pop(w.key, false)
+	if err != nil || ok {
+		atomic.AddInt64(&h.waiters.parked, -1)
+		if err != nil {
+			return err
+		}
+
+		return &val
+	}
+
+	h.waiters.keys[w.key] = append(h.waiters.keys[w.key], w)
+	h.waiters.conns[sub] = w
+	if bReq.Timeout > 0 {
+		w.timer = time.AfterFunc(bReq.Timeout, func() { h.waiters.expire(w) })
+	}
+
+	return nil
+}
+
+// waiter is connection parked by BRPOP, till value is pushed to its key
+// or its timeout runs out
+type waiter struct {
+	key   op.Key
+	sub   *pubsub.Subscriber
+	hdr   op.Header
+	wake  func(op.Header, op.Response)
+	timer *time.Timer
+}
+
+// waiters keeps connections parked on each key, in order they were
+// parked. Connection may have at most one request parked.
+type waiters struct {
+	lock   sync.Mutex
+	keys   map[op.Key][]*waiter
+	conns  map[*pubsub.Subscriber]*waiter
+	parked int64 // updated atomically, so that pushes skip lock, when none is parked
+}
+
+func newWaiters() *waiters {
+	return &waiters{
+		keys:  make(map[op.Key][]*waiter),
+		conns: make(map[*pubsub.Subscriber]*waiter),
+	}
+}
+
+// remove drops waiter, while lock is held, reporting whether it was
+// still parked
+func (ws *waiters) remove(w *waiter) bool {
+	if ws.conns[w.sub] != w {
+		return false
+	}
+
+	delete(ws.conns, w.sub)
+	atomic.AddInt64(&ws.parked, -1)
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+
+	parked := ws.keys[w.key]
+	for i := range parked {
+		if parked[i] == w {
+			parked = append(parked[:i:i], parked[i+1:]...)
+			break
+		}
+	}
+
+	if len(parked) == 0 {
+		delete(ws.keys, w.key)
+	} else {
+		ws.keys[w.key] = parked
+	}
+
+	return true
+}
+
+// serveParked pops values of key for connections parked on it, in order
+// they were parked, till list is empty
+func (h *Handler) serveParked(key op.Key) {
+	ws := h.waiters
+	if atomic.LoadInt64(&ws.parked) == 0 {
+		return
+	}
+
+	type woken struct {
+		w   *waiter
+		val op.Value
+	}
+
+	var served []woken
+	ws.lock.Lock()
+	for len(ws.keys[key]) != 0 {
+		val, ok, err := h.pop(key, false)
+		if err != nil || !ok {
+			break
+		}
+
+		w := ws.keys[key][0]
+		ws.remove(w)
+		served = append(served, woken{w: w, val: val})
+	}
+	ws.lock.Unlock()
+
+	for i := range served {
+		served[i].w.wake(served[i].w.hdr, &served[i].val)
+	}
+}
+
+// expire responds to waiter with `StatusNotFound`, as its timeout ran
+// out, unless it's already served
+func (ws *waiters) expire(w *waiter) {
+	ws.lock.Lock()
+	parked := ws.remove(w)
+	ws.lock.Unlock()
+
+	if parked {
+		w.wake(w.hdr, op.StatusNotFound)
+	}
+}
+
+// Unblock drops request of connection, which is parked, if any, as
+// connection is gone. It must be called, once connection, which may
+// have been parked by `HandleBlocking`, is closed. It reports whether
+// parked request was dropped, in which case it's never answered.
+func (h *Handler) Unblock(sub *pubsub.Subscriber) bool {
+	h.waiters.lock.Lock()
+	defer h.waiters.lock.Unlock()
+
+	w, ok := h.waiters.conns[sub]
+	return ok && h.waiters.remove(w)
+}
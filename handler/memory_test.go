@@ -0,0 +1,47 @@
+package handler_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/handler"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+func TestMemory(t *testing.T) {
+	for _, policy := range []store.EvictionPolicy{store.NoEviction, store.AllKeysLRU} {
+		// room for two keys, as each takes 10 bytes
+		h := handler.New(store.NewMap(expiry.System, store.WithMaxMemory(20, policy)))
+
+		val := op.Value("value")
+		for i := 0; i < 3; i++ {
+			key := op.Key(fmt.Sprintf("key-%d", i))
+			if i == 2 && policy == store.NoEviction {
+				expectError(t, h, &op.WriteRequest{Key: &key, Value: &val}, op.OutOfMemory)
+				continue
+			}
+
+			if err := roundTrip(t, h, &op.WriteRequest{Key: &key, Value: &val}, new(op.Value)); err != nil {
+				t.Fatalf("[%s] Failed to write : %s\n", policy, err.Error())
+			}
+		}
+
+		evicted := uint64(0)
+		if policy != store.NoEviction {
+			evicted = 1
+		}
+
+		stats := new(op.StatsResponse)
+		if err := roundTrip(t, h, &op.StatsRequest{}, stats); err != nil {
+			t.Fatalf("[%s] Failed to read stats : %s\n", policy, err.Error())
+		}
+
+		for name, expected := range map[string]uint64{"keys": 2, "used_memory": 20, "max_memory": 20, "evicted_keys": evicted} {
+			if v, ok := stats.Get(name); !ok || v != expected {
+				t.Fatalf("[%s] Expected `%s` = %d, received %d\n", policy, name, expected, v)
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package handler_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/handler"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+func TestScan(t *testing.T) {
+	h := handler.New(store.NewSharded(4, expiry.System))
+
+	val := op.Value("v")
+	for _, key := range []string{"user:0", "user:10", "user:2:a"} {
+		key := op.Key(key)
+		if err := roundTrip(t, h, &op.WriteRequest{Key: &key, Value: &val}, new(op.Value)); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+	}
+
+	for i := 0; i < 2500; i++ {
+		key := op.Key(fmt.Sprintf("user:1:%04d", i))
+		if err := roundTrip(t, h, &op.WriteRequest{Key: &key, Value: &val}, new(op.Value)); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+	}
+
+	// scan continues from cursor, till it's exhausted
+	scan := func(req op.ScanRequest) ([]op.Key, int) {
+		keys := make([]op.Key, 0)
+		calls := 0
+
+		for {
+			resp := new(op.ScanResponse)
+			if err := roundTrip(t, h, &req, resp); err != nil {
+				t.Fatalf("Failed to scan : %s\n", err.Error())
+			}
+
+			keys = append(keys, resp.Keys...)
+			calls++
+			if resp.Cursor == "" {
+				return keys, calls
+			}
+
+			req.Cursor = resp.Cursor
+		}
+	}
+
+	for _, hdr := range []op.Header{{}, {Legacy: true}} {
+		keys, calls := scan(op.ScanRequest{Header: hdr, Prefix: "user:1:", Limit: 1000})
+		if len(keys) != 2500 || calls != 3 {
+			t.Fatalf("Expected 2500 keys in 3 calls, received %d keys in %d calls\n", len(keys), calls)
+		}
+
+		for i, key := range keys {
+			if expected := op.Key(fmt.Sprintf("user:1:%04d", i)); key != expected {
+				t.Fatalf("Expected `%s`, received `%s`\n", expected, key)
+			}
+		}
+	}
+
+	keys, _ := scan(op.ScanRequest{Start: "user:1:2499", End: "user:3"})
+	if len(keys) != 2 || keys[0] != "user:1:2499" || keys[1] != "user:2:a" {
+		t.Fatalf("Expected keys in range, received %v\n", keys)
+	}
+
+	if keys, _ := scan(op.ScanRequest{Prefix: "none:"}); len(keys) != 0 {
+		t.Fatalf("Expected no keys, received %v\n", keys)
+	}
+
+	// key past limit becomes cursor, without being returned, while no
+	// cursor is left, once exactly limit keys are left
+	for _, step := range []struct {
+		cursor op.Key
+		limit  uint32
+		keys   int
+		next   op.Key
+	}{
+		{"", 2499, 2499, "user:1:2499"},
+		{"user:1:2499", 1, 1, ""},
+		{"", 2500, 2500, ""},
+	} {
+		resp := new(op.ScanResponse)
+		if err := roundTrip(t, h, &op.ScanRequest{Prefix: "user:1:", Cursor: step.cursor, Limit: step.limit}, resp); err != nil {
+			t.Fatalf("Failed to scan : %s\n", err.Error())
+		}
+
+		if len(resp.Keys) != step.keys || resp.Cursor != step.next {
+			t.Fatalf("Expected %d keys with cursor `%s`, received %d keys with cursor `%s`\n", step.keys, step.next, len(resp.Keys), resp.Cursor)
+		}
+	}
+}
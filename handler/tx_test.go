@@ -0,0 +1,232 @@
+package handler_test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/handler"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+// TestTx moves units between accounts using watched transactions, from
+// many clients, while another audits all accounts in one transaction,
+// where total must never change
+func TestTx(t *testing.T) {
+	const (
+		accounts  = 8
+		clients   = 6
+		transfers = 50
+	)
+
+	h := handler.New(store.NewSharded(4, expiry.System))
+	keys := make([]op.Key, accounts)
+	setup := op.TxRequest{}
+	for i := range keys {
+		keys[i] = op.Key(fmt.Sprintf("account-%d", i))
+		setup.Ops = append(setup.Ops, op.TxOp{Op: op.WRITE, Key: keys[i], Value: op.Value("100")})
+	}
+
+	resp := new(op.TxResponse)
+	if err := roundTrip(t, h, &setup, resp); err != nil || resp.Aborted || len(resp.Results) != accounts {
+		t.Fatalf("Expected setup to be applied, received %+v [%v]\n", resp, err)
+	}
+
+	audit := op.TxRequest{}
+	for _, key := range keys {
+		audit.Ops = append(audit.Ops, op.TxOp{Op: op.READ, Key: key})
+	}
+
+	total := func() int {
+		resp := new(op.TxResponse)
+		if err := roundTrip(t, h, &audit, resp); err != nil || resp.Aborted {
+			t.Errorf("Failed to audit : %+v [%v]\n", resp, err)
+			return -1
+		}
+
+		sum := 0
+		for _, res := range resp.Results {
+			n, _ := strconv.Atoi(string(res.Value))
+			sum += n
+		}
+
+		return sum
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+
+			rnd := rand.New(rand.NewSource(seed))
+			for j := 0; j < transfers; {
+				pick := rnd.Perm(accounts)
+				from, to := keys[pick[0]], keys[pick[1]]
+
+				balances := make([]op.VersionedValue, 2)
+				for k, key := range []op.Key{from, to} {
+					key := key
+					if err := roundTrip(t, h, &op.ReadVersionRequest{ReadRequest: op.ReadRequest{Key: &key}}, &balances[k]); err != nil {
+						t.Errorf("Failed to read : %s\n", err.Error())
+						return
+					}
+				}
+
+				a, _ := strconv.Atoi(string(balances[0].Value))
+				b, _ := strconv.Atoi(string(balances[1].Value))
+				if a == 0 {
+					continue
+				}
+
+				tx := op.TxRequest{
+					Watches: []op.Watch{{Key: from, Version: balances[0].Version}, {Key: to, Version: balances[1].Version}},
+					Ops: []op.TxOp{
+						{Op: op.WRITE, Key: from, Value: op.Value(strconv.Itoa(a - 1))},
+						{Op: op.WRITE, Key: to, Value: op.Value(strconv.Itoa(b + 1))},
+					},
+				}
+
+				resp := new(op.TxResponse)
+				if err := roundTrip(t, h, &tx, resp); err != nil {
+					t.Errorf("Failed to transfer : %s\n", err.Error())
+					return
+				}
+
+				if !resp.Aborted {
+					j++
+				}
+			}
+		}(int64(i))
+	}
+
+	// auditor keeps checking, till transfers are over
+	done := make(chan struct{})
+	audited := make(chan struct{})
+	go func() {
+		defer close(audited)
+
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			if sum := total(); sum != 100*accounts {
+				t.Errorf("Expected total of %d, found %d\n", 100*accounts, sum)
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(done)
+	<-audited
+	if t.Failed() {
+		return
+	}
+
+	// operations see writes before them, while missing key is watched
+	// with zero version
+	tx := op.TxRequest{
+		Watches: []op.Watch{{Key: "fresh"}},
+		Ops: []op.TxOp{
+			{Op: op.WRITE, Key: "fresh", Value: op.Value("v")},
+			{Op: op.READ, Key: "fresh"},
+			{Op: op.DELETE, Key: keys[1]},
+			{Op: op.READ, Key: keys[1]},
+		},
+	}
+
+	expected := []op.Lookup{{Found: true}, {Found: true, Value: op.Value("v")}, {Found: true}, {}}
+	if err := roundTrip(t, h, &tx, resp); err != nil || len(resp.Results) != len(expected) {
+		t.Fatalf("Expected %d results, received %+v [%v]\n", len(expected), resp, err)
+	}
+
+	for i, res := range resp.Results {
+		if res.Found != expected[i].Found || string(res.Value) != string(expected[i].Value) {
+			t.Fatalf("Expected result %+v, received %+v\n", expected[i], res)
+		}
+	}
+
+	// watch of missing key fails, once it's written
+	if err := roundTrip(t, h, &tx, resp); err != nil || !resp.Aborted {
+		t.Fatalf("Expected transaction to abort, received %+v [%v]\n", resp, err)
+	}
+
+	// key changed since it was read aborts transaction, which writes
+	// nothing
+	seen := new(op.VersionedValue)
+	if err := roundTrip(t, h, &op.ReadVersionRequest{ReadRequest: op.ReadRequest{Key: &keys[0]}}, seen); err != nil {
+		t.Fatalf("Failed to read : %s\n", err.Error())
+	}
+
+	if err := roundTrip(t, h, &op.WriteRequest{Key: &keys[0], Value: &seen.Value}, new(op.Value)); err != nil {
+		t.Fatalf("Failed to write : %s\n", err.Error())
+	}
+
+	other := op.Key("other")
+	tx = op.TxRequest{
+		Watches: []op.Watch{{Key: keys[0], Version: seen.Version}},
+		Ops:     []op.TxOp{{Op: op.WRITE, Key: other, Value: op.Value("1")}},
+	}
+
+	resp = new(op.TxResponse)
+	if err := roundTrip(t, h, &tx, resp); err != nil || !resp.Aborted || len(resp.Results) != 0 {
+		t.Fatalf("Expected transaction to abort, received %+v [%v]\n", resp, err)
+	}
+
+	if err := roundTrip(t, h, &op.ReadRequest{Key: &other}, new(op.Value)); !errors.Is(err, op.ErrNotFound) {
+		t.Fatalf("Expected `%s` to be missing, received %v\n", other, err)
+	}
+}
+
+// TestTxMemory fills store up to max memory, before transaction, which
+// needs room for its writes, where keys it watches must not be evicted
+// to make room
+func TestTxMemory(t *testing.T) {
+	// room for four keys, as each takes 10 bytes
+	h := handler.New(store.NewMap(expiry.System, store.WithMaxMemory(40, store.AllKeysLRU)))
+	val := op.Value("value")
+	write := func(key op.Key) {
+		if err := roundTrip(t, h, &op.WriteRequest{Key: &key, Value: &val}, new(op.Value)); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+	}
+
+	// watched key is least recently used one
+	watched := op.Key("key-0")
+	write(watched)
+	seen := new(op.VersionedValue)
+	if err := roundTrip(t, h, &op.ReadVersionRequest{ReadRequest: op.ReadRequest{Key: &watched}}, seen); err != nil {
+		t.Fatalf("Failed to read version : %s\n", err.Error())
+	}
+
+	for i := 1; i < 4; i++ {
+		write(op.Key(fmt.Sprintf("key-%d", i)))
+	}
+
+	tx := op.TxRequest{
+		Watches: []op.Watch{{Key: watched, Version: seen.Version}},
+		Ops: []op.TxOp{
+			{Op: op.WRITE, Key: "key-4", Value: val},
+			{Op: op.WRITE, Key: "key-5", Value: val},
+			{Op: op.READ, Key: watched},
+		},
+	}
+
+	resp := new(op.TxResponse)
+	if err := roundTrip(t, h, &tx, resp); err != nil || resp.Aborted || !resp.Results[2].Found {
+		t.Fatalf("Expected transaction to be applied, received %+v [%v]\n", resp, err)
+	}
+
+	if mem := h.Store.Memory(); mem.Used > mem.Max || mem.Evicted != 2 {
+		t.Fatalf("Expected 2 keys to be evicted within max memory, found %+v\n", mem)
+	}
+}
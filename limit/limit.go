@@ -0,0 +1,93 @@
+package limit
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+// ErrTooManyConns & ErrTooManyConnsFromIP are sent to connection, which
+// is rejected, as server is already keeping as many open, as allowed
+var (
+	ErrTooManyConns       = &op.Error{Code: op.Overloaded, Message: "too many connections"}
+	ErrTooManyConnsFromIP = &op.Error{Code: op.Overloaded, Message: "too many connections from address"}
+)
+
+// rejectTimeout bounds how long error frame may take to be written to
+// rejected connection, before it's closed anyway
+const rejectTimeout = 100 * time.Millisecond
+
+// Limiter caps number of connections server keeps open, both in total &
+// from each source IP, where zero means no limit. Connections without IP
+// address, like ones over unix socket, are only counted in total.
+type Limiter struct {
+	max   int
+	perIP int
+	lock  sync.Mutex
+	total int
+	byIP  map[string]int
+}
+
+func New(max, perIP int) *Limiter {
+	return &Limiter{max: max, perIP: perIP, byIP: make(map[string]int)}
+}
+
+// Acquire takes slot for connection from addr, returning error to be
+// sent, when it's over either limit. Slot taken must be given back with
+// `Release`, once connection is closed.
+func (l *Limiter) Acquire(addr net.Addr) *op.Error {
+	ip := ipOf(addr)
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if ip != "" && l.perIP > 0 && l.byIP[ip] >= l.perIP {
+		return ErrTooManyConnsFromIP
+	}
+
+	if l.max > 0 && l.total >= l.max {
+		return ErrTooManyConns
+	}
+
+	l.total++
+	if ip != "" {
+		l.byIP[ip]++
+	}
+
+	return nil
+}
+
+// Release gives back slot of connection from addr
+func (l *Limiter) Release(addr net.Addr) {
+	ip := ipOf(addr)
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.total--
+	if ip == "" {
+		return
+	}
+
+	if l.byIP[ip]--; l.byIP[ip] <= 0 {
+		delete(l.byIP, ip)
+	}
+}
+
+// Reject sends error frame to connection, which isn't taken, closing it
+// afterwards
+func Reject(conn net.Conn, err *op.Error) {
+	conn.SetWriteDeadline(time.Now().Add(rejectTimeout))
+	err.WriteTo(conn)
+	conn.Close()
+}
+
+func ipOf(addr net.Addr) string {
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		return tcp.IP.String()
+	}
+
+	return ""
+}
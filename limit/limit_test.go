@@ -0,0 +1,53 @@
+package limit_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/limit"
+)
+
+func TestLimiter(t *testing.T) {
+	l := limit.New(3, 2)
+	a := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1}
+	b := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 1}
+	unix := &net.UnixAddr{Name: "@", Net: "unix"}
+
+	for i := 0; i < 2; i++ {
+		if err := l.Acquire(a); err != nil {
+			t.Fatalf("Expected connection %d from a to be taken, received %v\n", i, err)
+		}
+	}
+
+	if err := l.Acquire(a); err != limit.ErrTooManyConnsFromIP {
+		t.Fatalf("Expected per IP limit to be hit, received %v\n", err)
+	}
+
+	if err := l.Acquire(b); err != nil {
+		t.Fatalf("Expected connection from b to be taken, received %v\n", err)
+	}
+
+	if err := l.Acquire(b); err != limit.ErrTooManyConns {
+		t.Fatalf("Expected total limit to be hit, received %v\n", err)
+	}
+
+	// slot given back is taken by connection from any address
+	l.Release(a)
+	if err := l.Acquire(unix); err != nil {
+		t.Fatalf("Expected connection without IP to be taken, received %v\n", err)
+	}
+
+	l.Release(unix)
+	l.Release(b)
+	if err := l.Acquire(a); err != nil {
+		t.Fatalf("Expected connection from a to be taken, received %v\n", err)
+	}
+
+	// no limit
+	l = limit.New(0, 0)
+	for i := 0; i < 1024; i++ {
+		if err := l.Acquire(a); err != nil {
+			t.Fatalf("Expected connection %d to be taken, received %v\n", i, err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package op
+
+import (
+	"encoding/binary"
+)
+
+// maxRetained is largest buffer capacity decoder keeps, once all
+// buffered bytes are consumed
+const maxRetained = 1 << 16
+
+// Frame is one complete request, as found in byte stream
+type Frame struct {
+	Envelope
+	Body []byte
+}
+
+// Decoder splits a byte stream, received in arbitrary sized chunks, into
+// request frames. Bytes of a partially received frame are kept across
+// chunks, while complete frames are handed out without being copied.
+type Decoder struct {
+	maxFrameSize uint32
+	buf          []byte // bytes of partial frame, kept from earlier chunks
+	in           []byte // bytes being decoded
+	off          int    // start of undecoded bytes in `in`
+}
+
+func NewDecoder(maxFrameSize uint32) *Decoder {
+	return &Decoder{maxFrameSize: maxFrameSize}
+}
+
+// Feed hands over bytes read off the wire, which can be decoded into
+// frames by calling Next, until it reports no more complete frames.
+func (d *Decoder) Feed(p []byte) {
+	if len(d.buf) == 0 {
+		d.in = p
+	} else {
+		d.buf = append(d.buf, p...)
+		d.in = d.buf
+	}
+
+	d.off = 0
+}
+
+// Next returns next complete frame. Body of returned frame may refer to
+// fed chunk, so it's valid only till next call to Next or Feed.
+//
+// When no complete frame is left, bytes of trailing partial frame are
+// retained & false is returned. Error is returned for a frame declaring
+// body larger than max frame size, after which stream can't be decoded
+// any further.
+func (d *Decoder) Next() (Frame, bool, *Error) {
+	rem := d.in[d.off:]
+	if len(rem) == 0 {
+		d.keep(rem)
+		return Frame{}, false, nil
+	}
+
+	envLen := OP(rem[0]).EnvelopeLen()
+	if len(rem) < envLen {
+		d.keep(rem)
+		return Frame{}, false, nil
+	}
+
+	env := decodeEnvelope(rem[:envLen])
+	if err := env.CheckBodyLen(d.maxFrameSize); err != nil {
+		return Frame{Envelope: env}, false, err
+	}
+
+	frameLen := envLen + int(env.BodyLen)
+	if len(rem) < frameLen {
+		d.keep(rem)
+		return Frame{}, false, nil
+	}
+
+	d.off += frameLen
+	return Frame{Envelope: env, Body: rem[envLen:frameLen]}, true, nil
+}
+
+// Retain copies bytes not decoded yet into decoder's own buffer, so that
+// fed chunk may be reused, before Next has reported no more frames
+func (d *Decoder) Retain() {
+	d.keep(d.in[d.off:])
+}
+
+// Buffered returns number of bytes of partial frame, waiting for rest
+// of its bytes
+func (d *Decoder) Buffered() int {
+	return len(d.buf)
+}
+
+// Stage tells which part of next frame decoder is waiting for
+type Stage uint8
+
+const (
+	// AwaitingFrame is when no byte of next frame is received yet
+	AwaitingFrame Stage = iota
+	// AwaitingEnvelope is when envelope of next frame is partially received
+	AwaitingEnvelope
+	// AwaitingBody is when envelope of next frame is received, while its
+	// body is not, completely
+	AwaitingBody
+)
+
+// Stage returns which part of partial frame is awaited, once Next has
+// reported no more complete frames
+func (d *Decoder) Stage() Stage {
+	if len(d.buf) == 0 {
+		return AwaitingFrame
+	}
+
+	if len(d.buf) < OP(d.buf[0]).EnvelopeLen() {
+		return AwaitingEnvelope
+	}
+
+	return AwaitingBody
+}
+
+// keep retains bytes of partial frame, so that they outlive fed chunk
+func (d *Decoder) keep(rem []byte) {
+	// large buffer, grown for some large frame, isn't held on to
+	if len(rem) == 0 && cap(d.buf) > maxRetained {
+		d.buf = nil
+	}
+
+	// bytes may be moved within `buf` itself, which append handles
+	d.buf = append(d.buf[:0], rem...)
+	d.in = d.buf
+	d.off = 0
+}
+
+// decodeEnvelope decodes envelope from exactly as many bytes as denoted
+// by `EnvelopeLen`
+func decodeEnvelope(b []byte) Envelope {
+	opcode, hdr := OP(b[0]).split()
+	b = b[1:]
+	if hdr.Tagged {
+		hdr.ID = binary.BigEndian.Uint32(b)
+		b = b[4:]
+	}
+
+	env := Envelope{Header: hdr, Op: opcode}
+	if hdr.Legacy {
+		env.BodyLen = uint32(binary.BigEndian.Uint16(b))
+		return env
+	}
+
+	env.BodyLen = binary.BigEndian.Uint32(b)
+	return env
+}
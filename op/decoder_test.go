@@ -0,0 +1,180 @@
+package op_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+func TestDecoder(t *testing.T) {
+	stream := new(bytes.Buffer)
+	keys := make([]op.Key, 0, 64)
+
+	for i := 0; i < 64; i++ {
+		key := op.Key(fmt.Sprintf("key-%d", i))
+		hdr := op.Header{Legacy: i%2 == 0, Tagged: i%3 == 0, ID: uint32(i)}
+		req := op.ReadRequest{Header: hdr, Key: &key}
+
+		if _, err := req.WriteEnvelope(stream); err != nil {
+			t.Fatalf("Failed to write envelope : %s\n", err.Error())
+		}
+
+		if _, err := req.WriteTo(stream); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+
+		keys = append(keys, key)
+	}
+
+	// same stream, fed in chunks of varying size
+	for _, chunkSize := range []int{1, 2, 3, 7, 64, stream.Len()} {
+		dec := op.NewDecoder(1 << 10)
+		raw := stream.Bytes()
+		decoded := 0
+
+		for len(raw) > 0 {
+			n := chunkSize
+			if n > len(raw) {
+				n = len(raw)
+			}
+
+			// chunk gets overwritten, as network read buffer would be
+			chunk := append([]byte{}, raw[:n]...)
+			raw = raw[n:]
+			dec.Feed(chunk)
+
+			for {
+				frame, ok, err := dec.Next()
+				if err != nil {
+					t.Fatalf("Failed to decode : %s\n", err.Error())
+				}
+
+				if !ok {
+					break
+				}
+
+				req := op.ReadRequest{Header: frame.Header}
+				if err := op.ReadBody(&req, frame.Body); err != nil {
+					t.Fatalf("Failed to read body : %s\n", err.Error())
+				}
+
+				if frame.Tagged != (decoded%3 == 0) || (frame.Tagged && frame.ID != uint32(decoded)) {
+					t.Fatalf("[chunk %d] Bad request ID in frame %d\n", chunkSize, decoded)
+				}
+
+				if frame.Op != op.READ || *req.Key != keys[decoded] {
+					t.Fatalf("[chunk %d] Expected frame %d to be READ `%s`\n", chunkSize, decoded, keys[decoded])
+				}
+
+				decoded++
+			}
+
+			for i := range chunk {
+				chunk[i] = 0
+			}
+		}
+
+		if decoded != len(keys) {
+			t.Fatalf("[chunk %d] Expected %d frames, decoded %d\n", chunkSize, len(keys), decoded)
+		}
+
+		if dec.Buffered() != 0 {
+			t.Fatalf("[chunk %d] Expected no buffered bytes\n", chunkSize)
+		}
+	}
+}
+
+func TestDecoderTooLarge(t *testing.T) {
+	dec := op.NewDecoder(16)
+	dec.Feed([]byte{128 | byte(op.WRITE), 0, 0, 1, 0})
+
+	frame, ok, err := dec.Next()
+
+	var opErr *op.Error
+	if ok || !errors.As(err, &opErr) || opErr.Code != op.TooLarge {
+		t.Fatalf("Expected too large error, received %v\n", err)
+	}
+
+	if frame.Op != op.WRITE || frame.BodyLen != 256 {
+		t.Fatalf("Expected envelope of rejected frame, received %+v\n", frame.Envelope)
+	}
+}
+
+func TestDecoderStage(t *testing.T) {
+	key := op.Key("key")
+	req := op.ReadRequest{Header: op.Header{Tagged: true, ID: 7}, Key: &key}
+	buf := new(bytes.Buffer)
+	req.WriteEnvelope(buf)
+	envLen := buf.Len()
+	req.WriteTo(buf)
+	raw := buf.Bytes()
+
+	dec := op.NewDecoder(1 << 10)
+	for i, b := range raw {
+		expected := op.AwaitingEnvelope
+		if i == 0 {
+			expected = op.AwaitingFrame
+		} else if i >= envLen {
+			expected = op.AwaitingBody
+		}
+
+		if stage := dec.Stage(); stage != expected {
+			t.Fatalf("Expected stage %d after %d bytes, found %d\n", expected, i, stage)
+		}
+
+		dec.Feed([]byte{b})
+		if _, ok, _ := dec.Next(); ok != (i == len(raw)-1) {
+			t.Fatalf("Unexpected frame after %d bytes\n", i+1)
+		}
+	}
+
+	if _, ok, _ := dec.Next(); ok {
+		t.Fatalf("Expected no more frames\n")
+	}
+
+	if stage := dec.Stage(); stage != op.AwaitingFrame {
+		t.Fatalf("Expected to await next frame, found %d\n", stage)
+	}
+}
+
+func TestDecoderRetain(t *testing.T) {
+	keys := []op.Key{"a", "b", "c"}
+	buf := new(bytes.Buffer)
+	for i := range keys {
+		req := op.ReadRequest{Key: &keys[i]}
+		req.WriteEnvelope(buf)
+		req.WriteTo(buf)
+	}
+
+	chunk := buf.Bytes()
+	dec := op.NewDecoder(1 << 10)
+	dec.Feed(chunk)
+	if _, ok, _ := dec.Next(); !ok {
+		t.Fatalf("Expected first frame\n")
+	}
+
+	// chunk is reused, while rest of its frames are yet to be decoded
+	dec.Retain()
+	for i := range chunk {
+		chunk[i] = 0
+	}
+
+	for _, key := range keys[1:] {
+		frame, ok, _ := dec.Next()
+		if !ok {
+			t.Fatalf("Expected frame of `%s`\n", key)
+		}
+
+		req := op.ReadRequest{Header: frame.Header}
+		if err := op.ReadBody(&req, frame.Body); err != nil || *req.Key != key {
+			t.Fatalf("Expected READ `%s`, received %v\n", key, err)
+		}
+	}
+
+	if _, ok, _ := dec.Next(); ok || dec.Buffered() != 0 {
+		t.Fatalf("Expected no more frames\n")
+	}
+}
@@ -0,0 +1,94 @@
+package op
+
+import (
+	"errors"
+	"io"
+)
+
+type DeleteRequest struct {
+	Header
+	Key *Key
+}
+
+func (d *DeleteRequest) Len() int {
+	return d.Key.len()
+}
+
+func (d *DeleteRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	return writeEnvelope(w, d.Header, DELETE, d.lenSize()+d.Len())
+}
+
+func (d *DeleteRequest) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+
+	n, err := d.writeLen(w, d.Key.len())
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	n, err = d.Key.writeTo(w)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	return total, nil
+}
+
+func (d *DeleteRequest) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+
+	keySize, n, err := d.readLen(r)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	key := new(Key)
+	n, err = key.readFrom(r, int64(keySize))
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	d.Key = key
+	return total, nil
+}
+
+// DeleteResponse is sent back for a DELETE request, reporting whether
+// the key was present in the store before it got removed.
+//
+// It's a RESPONSE frame with empty value, where missing key is denoted
+// using `StatusNotFound`
+type DeleteResponse struct {
+	Existed bool
+}
+
+func (d *DeleteResponse) WriteTo(w io.Writer) (int64, error) {
+	return d.writeFrame(w, Header{})
+}
+
+func (d *DeleteResponse) writeFrame(w io.Writer, hdr Header) (int64, error) {
+	if d.Existed {
+		return StatusOK.writeFrame(w, hdr)
+	}
+
+	return StatusNotFound.writeFrame(w, hdr)
+}
+
+func (d *DeleteResponse) ReadFrom(r io.Reader) (int64, error) {
+	val := new(Value)
+	n, err := val.ReadFrom(r)
+	if errors.Is(err, ErrNotFound) {
+		d.Existed = false
+		return n, nil
+	}
+
+	if err != nil {
+		return n, err
+	}
+
+	d.Existed = true
+	return n, nil
+}
@@ -0,0 +1,107 @@
+package op_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+func TestDeleteRequest(t *testing.T) {
+	key := op.Key("hello")
+	delReq1 := op.DeleteRequest{Key: &key}
+	stream := new(bytes.Buffer)
+
+	if _, err := delReq1.WriteEnvelope(stream); err != nil {
+		t.Fatalf("Failed to write envelope : %s\n", err.Error())
+	}
+
+	if _, err := delReq1.WriteTo(stream); err != nil {
+		t.Fatalf("Failed to write : %s\n", err.Error())
+	}
+
+	delReq2 := new(op.DeleteRequest)
+
+	env, err := op.ReadEnvelope(stream)
+	if err != nil {
+		t.Fatalf("Failed to read envelope : %s\n", err.Error())
+	}
+
+	if env.Op != op.DELETE {
+		t.Fatalf("Expected DELETE opcode\n")
+	}
+
+	if _, err := delReq2.ReadFrom(stream); err != nil {
+		t.Fatalf("Failed to read : %s\n", err.Error())
+	}
+
+	if int(env.BodyLen) != delReq2.Len()+4 {
+		t.Fatalf("Bad length denotation in envelope\n")
+	}
+
+	if string(*delReq1.Key) != string(*delReq2.Key) {
+		t.Fatalf("Bad write to/ read from stream\n")
+	}
+}
+
+func TestDeleteResponse(t *testing.T) {
+	for _, existed := range []bool{true, false} {
+		resp1 := op.DeleteResponse{Existed: existed}
+		stream := new(bytes.Buffer)
+
+		if _, err := resp1.WriteTo(stream); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+
+		resp2 := new(op.DeleteResponse)
+		if _, err := resp2.ReadFrom(stream); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if resp1.Existed != resp2.Existed {
+			t.Fatalf("Expected existed = %v, received %v\n", resp1.Existed, resp2.Existed)
+		}
+	}
+}
+
+func TestTaggedDeleteRequest(t *testing.T) {
+	for _, legacy := range []bool{true, false} {
+		key := op.Key("hello")
+		req1 := op.DeleteRequest{Header: op.Header{Legacy: legacy, Tagged: true, ID: 1 << 20}, Key: &key}
+		stream := new(bytes.Buffer)
+
+		if _, err := req1.WriteEnvelope(stream); err != nil {
+			t.Fatalf("Failed to write envelope : %s\n", err.Error())
+		}
+
+		if _, err := req1.WriteTo(stream); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+
+		env, err := op.ReadEnvelope(stream)
+		if err != nil {
+			t.Fatalf("Failed to read envelope : %s\n", err.Error())
+		}
+
+		if env.Op != op.DELETE {
+			t.Fatalf("Expected DELETE opcode\n")
+		}
+
+		if env.Header != req1.Header {
+			t.Fatalf("Expected header %+v, received %+v\n", req1.Header, env.Header)
+		}
+
+		req2 := op.DeleteRequest{Header: env.Header}
+		if int(env.BodyLen) != stream.Len() {
+			t.Fatalf("Bad length denotation in envelope\n")
+		}
+
+		if _, err := req2.ReadFrom(stream); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if string(*req1.Key) != string(*req2.Key) {
+			t.Fatalf("Bad write to/ read from stream\n")
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package op
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+type ErrorCode uint8
+
+const (
+	BadOpcode   ErrorCode = iota + 1 // request opcode is not known to server
+	Malformed                        // request body couldn't be decoded
+	TooLarge                         // request body is larger than allowed
+	Internal                         // server failed to serve valid request
+	OutOfMemory                      // store is full & can't evict any key
+	NotNumeric                       // value of key isn't a 64-bit integer
+	Overflow                         // integer result doesn't fit in 64 bits
+	NotAllowed                       // request isn't allowed in push mode
+	Overloaded                       // connection can't be served any further
+	WrongType                        // key holds value of another type
+)
+
+func (e ErrorCode) String() string {
+	switch e {
+	case BadOpcode:
+		return "bad opcode"
+	case Malformed:
+		return "malformed request"
+	case TooLarge:
+		return "request too large"
+	case Internal:
+		return "internal error"
+	case OutOfMemory:
+		return "out of memory"
+	case NotNumeric:
+		return "not numeric"
+	case Overflow:
+		return "integer overflow"
+	case NotAllowed:
+		return "not allowed"
+	case Overloaded:
+		return "overloaded"
+	case WrongType:
+		return "wrong type"
+	default:
+		return fmt.Sprintf("error code %d", uint8(e))
+	}
+}
+
+// Error is sent back by server in place of a response, when it fails
+// to serve some request. Clients receive it as a Go error, when reading
+// response.
+type Error struct {
+	Code    ErrorCode
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s : %s", e.Code, e.Message)
+}
+
+func (e *Error) WriteTo(w io.Writer) (int64, error) {
+	return e.writeFrame(w, Header{})
+}
+
+func (e *Error) writeFrame(w io.Writer, hdr Header) (int64, error) {
+	var total int64
+
+	n, err := hdr.writeOpcode(w, ERROR)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	if err := binary.Write(w, binary.BigEndian, e.Code); err != nil {
+		return total, err
+	}
+
+	total += 1
+	msg := e.Message
+	if hdr.Legacy && len(msg) > 255 {
+		msg = msg[:255]
+	}
+
+	n, err = hdr.writeLen(w, len(msg))
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	m, err := io.WriteString(w, msg)
+	if err != nil {
+		return total, err
+	}
+
+	total += int64(m)
+	return total, nil
+}
+
+func (e *Error) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+
+	opcode, hdr, n, err := readOpcode(r)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	if opcode != ERROR {
+		return total, errors.New("bad opcode")
+	}
+
+	n, err = e.readFrom(r, hdr)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	return total, nil
+}
+
+// readFrom reads error frame, after opcode & request ID
+func (e *Error) readFrom(r io.Reader, hdr Header) (int64, error) {
+	var (
+		total int64
+		code  ErrorCode
+	)
+
+	if err := binary.Read(r, binary.BigEndian, &code); err != nil {
+		return total, err
+	}
+
+	total += 1
+	msgLen, n, err := hdr.readLen(r)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	msg, err := readBytes(r, int64(msgLen))
+	if err != nil {
+		return total, err
+	}
+
+	total += int64(msgLen)
+	e.Code = code
+	e.Message = string(msg)
+	return total, nil
+}
+
+// ReadBody decodes request body into `req`, where whole body must be
+// consumed. On failure it returns error, which can be sent back to client.
+func ReadBody(req io.ReaderFrom, body []byte) *Error {
+	r := bytes.NewReader(body)
+	if _, err := req.ReadFrom(r); err != nil {
+		return &Error{Code: Malformed, Message: err.Error()}
+	}
+
+	if r.Len() != 0 {
+		return &Error{Code: Malformed, Message: fmt.Sprintf("%d trailing byte(s) in body", r.Len())}
+	}
+
+	return nil
+}
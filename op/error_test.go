@@ -0,0 +1,70 @@
+package op_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+func TestError(t *testing.T) {
+	err1 := op.Error{Code: op.Malformed, Message: "bad key length"}
+	stream := new(bytes.Buffer)
+
+	if _, err := err1.WriteTo(stream); err != nil {
+		t.Fatalf("Failed to write : %s\n", err.Error())
+	}
+
+	err2 := new(op.Error)
+	if _, err := err2.ReadFrom(stream); err != nil {
+		t.Fatalf("Failed to read : %s\n", err.Error())
+	}
+
+	if err1 != *err2 {
+		t.Fatalf("Expected `%s`, received `%s`\n", &err1, err2)
+	}
+}
+
+func TestValueReadError(t *testing.T) {
+	err1 := op.Error{Code: op.BadOpcode, Message: "opcode 127"}
+	stream := new(bytes.Buffer)
+
+	if _, err := err1.WriteTo(stream); err != nil {
+		t.Fatalf("Failed to write : %s\n", err.Error())
+	}
+
+	val := new(op.Value)
+	_, err := val.ReadFrom(stream)
+
+	var err2 *op.Error
+	if !errors.As(err, &err2) {
+		t.Fatalf("Expected to receive op.Error, received %v\n", err)
+	}
+
+	if err1 != *err2 {
+		t.Fatalf("Expected `%s`, received `%s`\n", &err1, err2)
+	}
+}
+
+func TestReadBody(t *testing.T) {
+	key := op.Key("hello")
+	readReq := op.ReadRequest{Key: &key}
+	stream := new(bytes.Buffer)
+
+	if _, err := readReq.WriteTo(stream); err != nil {
+		t.Fatalf("Failed to write : %s\n", err.Error())
+	}
+
+	if err := op.ReadBody(new(op.ReadRequest), stream.Bytes()); err != nil {
+		t.Fatalf("Failed to read body : %s\n", err.Error())
+	}
+
+	if err := op.ReadBody(new(op.ReadRequest), append(stream.Bytes(), 0)); err == nil || err.Code != op.Malformed {
+		t.Fatalf("Expected trailing bytes to be rejected\n")
+	}
+
+	if err := op.ReadBody(new(op.ReadRequest), stream.Bytes()[:3]); err == nil || err.Code != op.Malformed {
+		t.Fatalf("Expected truncated body to be rejected\n")
+	}
+}
@@ -0,0 +1,264 @@
+package op
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// EventKind tells how key got changed
+type EventKind uint8
+
+const (
+	KeyWritten EventKind = iota + 1 // value of key got written
+	KeyDeleted                      // key got deleted
+	KeyExpired                      // key got removed, as its TTL ran out
+	KeyEvicted                      // key got evicted, to make room
+)
+
+func (e EventKind) String() string {
+	switch e {
+	case KeyWritten:
+		return "written"
+	case KeyDeleted:
+		return "deleted"
+	case KeyExpired:
+		return "expired"
+	case KeyEvicted:
+		return "evicted"
+	default:
+		return fmt.Sprintf("event kind %d", uint8(e))
+	}
+}
+
+// Pattern matches key equal to `Key` or, with `Prefix` set, every key
+// starting with it
+type Pattern struct {
+	Key    Key
+	Prefix bool
+}
+
+// Match tells whether key is matched by pattern
+func (p Pattern) Match(key Key) bool {
+	if p.Prefix {
+		return len(key) >= len(p.Key) && key[:len(p.Key)] == p.Key
+	}
+
+	return key == p.Key
+}
+
+// KeyspaceRequest makes connection watch changes of keys matching
+// patterns, switching it into push mode, where `Event` frames are sent
+// to it without any request. With `Unsubscribe` set, it's sent as
+// KUNSUBSCRIBE & stops watching patterns instead, where no patterns
+// denote all of them.
+//
+// Body holds number of patterns as uint32, followed by prefix flag byte &
+// length prefixed key of each. It's responded to with
+// `SubscribeResponse`.
+type KeyspaceRequest struct {
+	Header
+	Patterns    []Pattern
+	Unsubscribe bool
+}
+
+func (k *KeyspaceRequest) Len() int {
+	total := 0
+	for i := range k.Patterns {
+		total += k.Patterns[i].Key.len()
+	}
+
+	return total
+}
+
+func (k *KeyspaceRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	bodyLen := 4 + len(k.Patterns)*(1+k.lenSize()) + k.Len()
+	if k.Unsubscribe {
+		return writeEnvelope(w, k.Header, KUNSUBSCRIBE, bodyLen)
+	}
+
+	return writeEnvelope(w, k.Header, KSUBSCRIBE, bodyLen)
+}
+
+func (k *KeyspaceRequest) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+
+	n, err := writeCount(w, len(k.Patterns))
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	for i := range k.Patterns {
+		var prefix uint8
+		if k.Patterns[i].Prefix {
+			prefix = 1
+		}
+
+		if err := binary.Write(w, binary.BigEndian, prefix); err != nil {
+			return total, err
+		}
+
+		total += 1
+		n, err := k.writeLen(w, k.Patterns[i].Key.len())
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		n, err = k.Patterns[i].Key.writeTo(w)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+	}
+
+	return total, nil
+}
+
+func (k *KeyspaceRequest) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+
+	count, n, err := readCount(r)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	patterns := make([]Pattern, 0)
+	for i := uint32(0); i < count; i++ {
+		var prefix uint8
+		if err := binary.Read(r, binary.BigEndian, &prefix); err != nil {
+			return total, err
+		}
+
+		total += 1
+		if prefix > 1 {
+			return total, errors.New("bad prefix flag")
+		}
+
+		keySize, n, err := k.readLen(r)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		p := Pattern{Prefix: prefix == 1}
+		n, err = p.Key.readFrom(r, int64(keySize))
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		patterns = append(patterns, p)
+	}
+
+	k.Patterns = patterns
+	return total, nil
+}
+
+// Event is pushed to connections watching key, for every change of it,
+// applied by store.
+//
+// It's framed like a request, in wide framing, without request ID, where
+// body holds kind byte, version of key as uint64 & length prefixed key.
+// Version is drawn for every change, removals included, so that events
+// of a key are ordered by it.
+type Event struct {
+	Key     Key
+	Kind    EventKind
+	Version uint64
+}
+
+func (e *Event) WriteTo(w io.Writer) (int64, error) {
+	var hdr Header
+
+	total, err := writeEnvelope(w, hdr, EVENT, 1+8+hdr.lenSize()+e.Key.len())
+	if err != nil {
+		return total, err
+	}
+
+	if err := binary.Write(w, binary.BigEndian, e.Kind); err != nil {
+		return total, err
+	}
+
+	total += 1
+	if err := binary.Write(w, binary.BigEndian, e.Version); err != nil {
+		return total, err
+	}
+
+	total += 8
+	n, err := hdr.writeLen(w, e.Key.len())
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	n, err = e.Key.writeTo(w)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	return total, nil
+}
+
+func (e *Event) ReadFrom(r io.Reader) (int64, error) {
+	env, err := ReadEnvelope(r)
+	if err != nil {
+		return 0, err
+	}
+
+	total := int64(env.opcode(env.Op).EnvelopeLen())
+	if env.Op != EVENT {
+		return total, errors.New("bad opcode")
+	}
+
+	body, err := readBytes(r, int64(env.BodyLen))
+	if err != nil {
+		return total, err
+	}
+
+	total += int64(env.BodyLen)
+	var ev Event
+	rd := bytes.NewReader(body)
+	if err := binary.Read(rd, binary.BigEndian, &ev.Kind); err != nil {
+		return total, err
+	}
+
+	if err := binary.Read(rd, binary.BigEndian, &ev.Version); err != nil {
+		return total, err
+	}
+
+	keySize, _, err := env.readLen(rd)
+	if err != nil {
+		return total, err
+	}
+
+	if _, err := ev.Key.readFrom(rd, int64(keySize)); err != nil {
+		return total, err
+	}
+
+	if rd.Len() != 0 {
+		return total, errors.New("trailing bytes in event")
+	}
+
+	*e = ev
+	return total, nil
+}
+
+// IsEvent tells whether next frame in stream is a pushed event, without
+// consuming it
+func IsEvent(r *bufio.Reader) (bool, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return false, err
+	}
+
+	opcode, _ := OP(b[0]).split()
+	return opcode == EVENT, nil
+}
@@ -0,0 +1,78 @@
+package op_test
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+func TestKeyspaceRequest(t *testing.T) {
+	for _, req1 := range []op.KeyspaceRequest{
+		{Patterns: []op.Pattern{{Key: "config/app"}, {Key: "config/", Prefix: true}}},
+		{Header: op.Header{Legacy: true}, Patterns: []op.Pattern{{Key: "a", Prefix: true}}, Unsubscribe: true},
+		{Patterns: []op.Pattern{}, Unsubscribe: true},
+	} {
+		env, body := encode(t, &req1)
+		if (env.Op == op.KUNSUBSCRIBE) != req1.Unsubscribe || (env.Op != op.KSUBSCRIBE && env.Op != op.KUNSUBSCRIBE) {
+			t.Fatalf("Expected KSUBSCRIBE or KUNSUBSCRIBE as per %+v, received %d\n", req1, env.Op)
+		}
+
+		req2 := &op.KeyspaceRequest{Header: env.Header, Unsubscribe: env.Op == op.KUNSUBSCRIBE}
+		if err := op.ReadBody(req2, body); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if !reflect.DeepEqual(req1, *req2) {
+			t.Fatalf("Expected %+v, received %+v\n", req1, *req2)
+		}
+	}
+}
+
+func TestPatternMatch(t *testing.T) {
+	for _, tc := range []struct {
+		pattern op.Pattern
+		key     op.Key
+		match   bool
+	}{
+		{op.Pattern{Key: "a/b"}, "a/b", true},
+		{op.Pattern{Key: "a/b"}, "a/bc", false},
+		{op.Pattern{Key: "a/", Prefix: true}, "a/b", true},
+		{op.Pattern{Key: "a/", Prefix: true}, "a/", true},
+		{op.Pattern{Key: "a/", Prefix: true}, "a", false},
+		{op.Pattern{Key: "", Prefix: true}, "anything", true},
+	} {
+		if tc.pattern.Match(tc.key) != tc.match {
+			t.Fatalf("Expected %+v matching `%s` to be %v\n", tc.pattern, tc.key, tc.match)
+		}
+	}
+}
+
+func TestEvent(t *testing.T) {
+	stream := new(bytes.Buffer)
+	ev1 := op.Event{Key: "config/app", Kind: op.KeyExpired, Version: 1 << 40}
+	ev1.WriteTo(stream)
+
+	resp := op.SubscribeResponse{Count: 1}
+	resp.WriteTo(stream)
+
+	r := bufio.NewReader(stream)
+	if ok, err := op.IsEvent(r); err != nil || !ok {
+		t.Fatalf("Expected event, received %v [%v]\n", ok, err)
+	}
+
+	ev2 := new(op.Event)
+	if _, err := ev2.ReadFrom(r); err != nil || *ev2 != ev1 {
+		t.Fatalf("Expected %+v, received %+v [%v]\n", ev1, *ev2, err)
+	}
+
+	if ok, err := op.IsEvent(r); err != nil || ok {
+		t.Fatalf("Expected response, received %v [%v]\n", ok, err)
+	}
+
+	if _, err := resp.ReadFrom(r); err != nil || resp.Count != 1 {
+		t.Fatalf("Expected %+v, received %+v [%v]\n", op.SubscribeResponse{Count: 1}, resp, err)
+	}
+}
@@ -0,0 +1,76 @@
+package op
+
+import (
+	"io"
+	"time"
+)
+
+// ExpireRequest sets time to live of an existing key, replacing earlier
+// one, if any. Zero TTL makes key expire right away.
+//
+// It's responded to with `StatusOK`, when key exists, otherwise with
+// `StatusNotFound`.
+type ExpireRequest struct {
+	Header
+	Key *Key
+	TTL time.Duration
+}
+
+func (e *ExpireRequest) Len() int {
+	return e.Key.len()
+}
+
+func (e *ExpireRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	return writeEnvelope(w, e.Header, EXPIRE, e.lenSize()+e.Len()+8)
+}
+
+func (e *ExpireRequest) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+
+	n, err := e.writeLen(w, e.Key.len())
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	n, err = e.Key.writeTo(w)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	n, err = writeTTL(w, e.TTL)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	return total, nil
+}
+
+func (e *ExpireRequest) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+
+	keySize, n, err := e.readLen(r)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	key := new(Key)
+	n, err = key.readFrom(r, int64(keySize))
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	ttl, n, err := readTTL(r)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	e.Key = key
+	e.TTL = ttl
+	return total, nil
+}
@@ -0,0 +1,52 @@
+package op_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+func TestExpireRequest(t *testing.T) {
+	for _, legacy := range []bool{true, false} {
+		key := op.Key("hello")
+		req1 := op.ExpireRequest{Header: op.Header{Legacy: legacy}, Key: &key, TTL: 1500 * time.Microsecond}
+		stream := new(bytes.Buffer)
+
+		if _, err := req1.WriteEnvelope(stream); err != nil {
+			t.Fatalf("Failed to write envelope : %s\n", err.Error())
+		}
+
+		if _, err := req1.WriteTo(stream); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+
+		env, err := op.ReadEnvelope(stream)
+		if err != nil {
+			t.Fatalf("Failed to read envelope : %s\n", err.Error())
+		}
+
+		if env.Op != op.EXPIRE {
+			t.Fatalf("Expected EXPIRE opcode\n")
+		}
+
+		if int(env.BodyLen) != stream.Len() {
+			t.Fatalf("Bad length denotation in envelope\n")
+		}
+
+		req2 := op.ExpireRequest{Header: env.Header}
+		if err := op.ReadBody(&req2, stream.Bytes()); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if *req1.Key != *req2.Key {
+			t.Fatalf("Bad write to/ read from stream\n")
+		}
+
+		// fraction of millisecond is rounded up
+		if req2.TTL != 2*time.Millisecond {
+			t.Fatalf("Expected TTL of 2ms, received %s\n", req2.TTL)
+		}
+	}
+}
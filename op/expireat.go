@@ -0,0 +1,76 @@
+package op
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// ExpireAtRequest makes key expire at given point of time, denoted as
+// unix milliseconds on the wire. Unlike `ExpireRequest`, it stays
+// correct when replayed later, so append-only file records expiry
+// using it.
+type ExpireAtRequest struct {
+	Header
+	Key      *Key
+	Deadline time.Time
+}
+
+func (e *ExpireAtRequest) Len() int {
+	return e.Key.len()
+}
+
+func (e *ExpireAtRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	return writeEnvelope(w, e.Header, EXPIREAT, e.lenSize()+e.Len()+8)
+}
+
+func (e *ExpireAtRequest) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+
+	n, err := e.writeLen(w, e.Key.len())
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	n, err = e.Key.writeTo(w)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	ms := e.Deadline.UnixNano() / int64(time.Millisecond)
+	if err := binary.Write(w, binary.BigEndian, ms); err != nil {
+		return total, err
+	}
+
+	total += 8
+	return total, nil
+}
+
+func (e *ExpireAtRequest) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+
+	keySize, n, err := e.readLen(r)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	key := new(Key)
+	n, err = key.readFrom(r, int64(keySize))
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	var ms int64
+	if err := binary.Read(r, binary.BigEndian, &ms); err != nil {
+		return total, err
+	}
+
+	total += 8
+	e.Key = key
+	e.Deadline = time.Unix(0, ms*int64(time.Millisecond))
+	return total, nil
+}
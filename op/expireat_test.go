@@ -0,0 +1,45 @@
+package op_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+func TestExpireAtRequest(t *testing.T) {
+	key := op.Key("hello")
+	req1 := op.ExpireAtRequest{Key: &key, Deadline: time.Unix(1700000000, 123*int64(time.Millisecond))}
+	stream := new(bytes.Buffer)
+
+	if _, err := req1.WriteEnvelope(stream); err != nil {
+		t.Fatalf("Failed to write envelope : %s\n", err.Error())
+	}
+
+	if _, err := req1.WriteTo(stream); err != nil {
+		t.Fatalf("Failed to write : %s\n", err.Error())
+	}
+
+	env, err := op.ReadEnvelope(stream)
+	if err != nil {
+		t.Fatalf("Failed to read envelope : %s\n", err.Error())
+	}
+
+	if env.Op != op.EXPIREAT {
+		t.Fatalf("Expected EXPIREAT opcode\n")
+	}
+
+	if int(env.BodyLen) != stream.Len() {
+		t.Fatalf("Bad length denotation in envelope\n")
+	}
+
+	req2 := new(op.ExpireAtRequest)
+	if err := op.ReadBody(req2, stream.Bytes()); err != nil {
+		t.Fatalf("Failed to read : %s\n", err.Error())
+	}
+
+	if *req1.Key != *req2.Key || !req1.Deadline.Equal(req2.Deadline) {
+		t.Fatalf("Expected %s, received %s\n", req1.Deadline, req2.Deadline)
+	}
+}
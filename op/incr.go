@@ -0,0 +1,115 @@
+package op
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// IncrRequest adds `Delta` to value of key, which is treated as signed
+// 64-bit decimal integer, where missing key counts as zero. With
+// `Decrement` set, it's sent as DECRBY & `Delta` is subtracted instead,
+// otherwise as INCRBY. Time to live of key is kept as is.
+//
+// Body holds length prefixed key, followed by delta as int64. It's
+// responded to with `IncrResponse`, or with `NotNumeric` error, when
+// value of key isn't an integer.
+type IncrRequest struct {
+	Header
+	Key       *Key
+	Delta     int64
+	Decrement bool
+}
+
+func (i *IncrRequest) Len() int {
+	return i.Key.len()
+}
+
+func (i *IncrRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	if i.Decrement {
+		return writeEnvelope(w, i.Header, DECRBY, i.lenSize()+i.Len()+8)
+	}
+
+	return writeEnvelope(w, i.Header, INCRBY, i.lenSize()+i.Len()+8)
+}
+
+func (i *IncrRequest) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+
+	n, err := i.writeLen(w, i.Key.len())
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	n, err = i.Key.writeTo(w)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	if err := binary.Write(w, binary.BigEndian, i.Delta); err != nil {
+		return total, err
+	}
+
+	total += 8
+	return total, nil
+}
+
+func (i *IncrRequest) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+
+	keySize, n, err := i.readLen(r)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	key := new(Key)
+	n, err = key.readFrom(r, int64(keySize))
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	if err := binary.Read(r, binary.BigEndian, &i.Delta); err != nil {
+		return total, err
+	}
+
+	total += 8
+	i.Key = key
+	return total, nil
+}
+
+// IncrResponse is sent back for INCRBY & DECRBY requests.
+//
+// It's a RESPONSE frame, whose value is new value of key, in decimal,
+// same as it's stored.
+type IncrResponse struct {
+	Value int64
+}
+
+func (i *IncrResponse) WriteTo(w io.Writer) (int64, error) {
+	return i.writeFrame(w, Header{})
+}
+
+func (i *IncrResponse) writeFrame(w io.Writer, hdr Header) (int64, error) {
+	return writeResponse(w, hdr, StatusOK, strconv.AppendInt(nil, i.Value, 10))
+}
+
+func (i *IncrResponse) ReadFrom(r io.Reader) (int64, error) {
+	val := new(Value)
+	n, err := val.ReadFrom(r)
+	if err != nil {
+		return n, err
+	}
+
+	v, err := strconv.ParseInt(string(*val), 10, 64)
+	if err != nil {
+		return n, errors.New("bad integer value")
+	}
+
+	i.Value = v
+	return n, nil
+}
@@ -0,0 +1,58 @@
+package op_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+func TestIncrRequest(t *testing.T) {
+	key := op.Key("hits")
+	for _, req1 := range []op.IncrRequest{
+		{Key: &key, Delta: 1},
+		{Key: &key, Delta: math.MinInt64, Decrement: true},
+		{Header: op.Header{Legacy: true}, Key: &key, Delta: -7},
+	} {
+		env, body := encode(t, &req1)
+		if (env.Op == op.DECRBY) != req1.Decrement || (env.Op != op.INCRBY && env.Op != op.DECRBY) {
+			t.Fatalf("Expected INCRBY or DECRBY as per %+v, received %d\n", req1, env.Op)
+		}
+
+		req2 := &op.IncrRequest{Header: env.Header, Decrement: env.Op == op.DECRBY}
+		if err := op.ReadBody(req2, body); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if *req2.Key != key || req2.Delta != req1.Delta || req2.Decrement != req1.Decrement {
+			t.Fatalf("Expected %+v, received %+v\n", req1, req2)
+		}
+	}
+}
+
+func TestIncrResponse(t *testing.T) {
+	for _, resp1 := range []op.IncrResponse{{Value: 0}, {Value: math.MaxInt64}, {Value: -42}} {
+		stream := new(bytes.Buffer)
+		if _, err := resp1.WriteTo(stream); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+
+		resp2 := new(op.IncrResponse)
+		if _, err := resp2.ReadFrom(stream); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if resp1 != *resp2 {
+			t.Fatalf("Expected %+v, received %+v\n", resp1, *resp2)
+		}
+	}
+
+	// non-numeric value isn't taken as counter
+	stream := new(bytes.Buffer)
+	val := op.Value("x")
+	val.WriteTo(stream)
+	if _, err := new(op.IncrResponse).ReadFrom(stream); err == nil {
+		t.Fatalf("Expected non-numeric value to be rejected\n")
+	}
+}
@@ -14,8 +14,8 @@ func (k *Key) writeTo(w io.Writer) (int64, error) {
 }
 
 func (k *Key) readFrom(r io.Reader, n int64) (int64, error) {
-	buf := make([]byte, n)
-	if _, err := r.Read(buf); err != nil {
+	buf, err := readBytes(r, n)
+	if err != nil {
 		return 0, err
 	}
 
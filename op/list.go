@@ -0,0 +1,380 @@
+package op
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// List is value of list type, holding items in order from head to tail.
+// It's kept in store encoded as a `Value`, using `Encode`, which is also
+// how it's sent back for LRANGE requests.
+type List []Value
+
+// Encode encodes list as number of items as uint32, followed by uint32
+// length prefixed items
+func (l List) Encode() Value {
+	size := 4
+	for i := range l {
+		size += 4 + len(l[i])
+	}
+
+	buf := make([]byte, size)
+	binary.BigEndian.PutUint32(buf, uint32(len(l)))
+	off := 4
+	for i := range l {
+		binary.BigEndian.PutUint32(buf[off:], uint32(len(l[i])))
+		off += 4 + copy(buf[off+4:], l[i])
+	}
+
+	return buf
+}
+
+// DecodeList decodes list encoded using `Encode`. Items share memory of
+// encoded value.
+func DecodeList(v Value) (List, error) {
+	if len(v) < 4 {
+		return nil, errors.New("bad list length")
+	}
+
+	count := binary.BigEndian.Uint32(v)
+	v = v[4:]
+
+	// every item takes at least 4 bytes, which bounds preallocation
+	capacity := len(v) / 4
+	if uint64(count) < uint64(capacity) {
+		capacity = int(count)
+	}
+
+	l := make(List, 0, capacity)
+	for i := uint32(0); i < count; i++ {
+		if len(v) < 4 {
+			return nil, errors.New("bad item length")
+		}
+
+		size := binary.BigEndian.Uint32(v)
+		v = v[4:]
+		if uint32(len(v)) < size {
+			return nil, errors.New("truncated item")
+		}
+
+		l = append(l, v[:size:size])
+		v = v[size:]
+	}
+
+	if len(v) != 0 {
+		return nil, errors.New("trailing bytes in list")
+	}
+
+	return l, nil
+}
+
+// PushRequest pushes values to head of list, one after another, when
+// sent as LPUSH, with `Left` set, otherwise to its tail, as RPUSH.
+// Missing key is created as empty list, keeping no TTL.
+//
+// Body holds length prefixed key, followed by number of values as uint32
+// & length prefixed values. It's responded to with `LenResponse`, or
+// with `WrongType` error, when key holds a value, which isn't a list.
+type PushRequest struct {
+	ReadRequest
+	Values []Value
+	Left   bool
+}
+
+func (p *PushRequest) Len() int {
+	total := p.Key.len()
+	for i := range p.Values {
+		total += p.Values[i].Len()
+	}
+
+	return total
+}
+
+func (p *PushRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	bodyLen := 4 + (1+len(p.Values))*p.lenSize() + p.Len()
+	if p.Left {
+		return writeEnvelope(w, p.Header, LPUSH, bodyLen)
+	}
+
+	return writeEnvelope(w, p.Header, RPUSH, bodyLen)
+}
+
+func (p *PushRequest) WriteTo(w io.Writer) (int64, error) {
+	total, err := p.ReadRequest.WriteTo(w)
+	if err != nil {
+		return total, err
+	}
+
+	n, err := writeCount(w, len(p.Values))
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	for i := range p.Values {
+		n, err := p.writeLen(w, p.Values[i].Len())
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		n, err = p.Values[i].writeTo(w)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+	}
+
+	return total, nil
+}
+
+func (p *PushRequest) ReadFrom(r io.Reader) (int64, error) {
+	total, err := p.ReadRequest.ReadFrom(r)
+	if err != nil {
+		return total, err
+	}
+
+	count, n, err := readCount(r)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	values := make([]Value, 0)
+	for i := uint32(0); i < count; i++ {
+		valSize, n, err := p.readLen(r)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		var v Value
+		n, err = v.readFrom(r, int64(valSize))
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		values = append(values, v)
+	}
+
+	p.Values = values
+	return total, nil
+}
+
+// PopRequest removes & returns head of list, when sent as LPOP, with
+// `Left` set, otherwise its tail, as RPOP. List left empty is deleted.
+// It's encoded like READ request.
+//
+// It's responded to with popped value, `StatusNotFound`, when key is
+// missing, or with `WrongType` error, when key isn't a list.
+type PopRequest struct {
+	ReadRequest
+	Left bool
+}
+
+func (p *PopRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	if p.Left {
+		return writeEnvelope(w, p.Header, LPOP, p.lenSize()+p.Len())
+	}
+
+	return writeEnvelope(w, p.Header, RPOP, p.lenSize()+p.Len())
+}
+
+// LenRequest reads number of items in list. It's encoded like READ
+// request, but sent as LLEN.
+//
+// It's responded to with `LenResponse`, where missing key counts as
+// empty list, or with `WrongType` error, when key isn't a list.
+type LenRequest struct {
+	ReadRequest
+}
+
+func (l *LenRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	return writeEnvelope(w, l.Header, LLEN, l.lenSize()+l.Len())
+}
+
+// RangeRequest reads items of list, from index `Start` to `Stop`, both
+// inclusive. Negative index counts from tail of list, where -1 is its
+// last item. Indices out of list are clamped to it.
+//
+// Body holds length prefixed key, followed by start & stop as int64.
+// It's responded to with `ListResponse`, or with `WrongType` error, when
+// key isn't a list.
+type RangeRequest struct {
+	ReadRequest
+	Start int64
+	Stop  int64
+}
+
+func (r *RangeRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	return writeEnvelope(w, r.Header, LRANGE, r.lenSize()+r.Len()+16)
+}
+
+func (r *RangeRequest) WriteTo(w io.Writer) (int64, error) {
+	total, err := r.ReadRequest.WriteTo(w)
+	if err != nil {
+		return total, err
+	}
+
+	if err := binary.Write(w, binary.BigEndian, [2]int64{r.Start, r.Stop}); err != nil {
+		return total, err
+	}
+
+	total += 16
+	return total, nil
+}
+
+func (r *RangeRequest) ReadFrom(rd io.Reader) (int64, error) {
+	total, err := r.ReadRequest.ReadFrom(rd)
+	if err != nil {
+		return total, err
+	}
+
+	var bounds [2]int64
+	if err := binary.Read(rd, binary.BigEndian, &bounds); err != nil {
+		return total, err
+	}
+
+	total += 16
+	r.Start, r.Stop = bounds[0], bounds[1]
+	return total, nil
+}
+
+// Bounds returns indices of items in range, for list of given length,
+// as a half open interval, which is empty when no item falls in range
+func (r *RangeRequest) Bounds(length int) (int, int) {
+	start, stop := r.Start, r.Stop
+	if start < 0 {
+		start += int64(length)
+	}
+
+	if stop < 0 {
+		stop += int64(length)
+	}
+
+	if start < 0 {
+		start = 0
+	}
+
+	if stop >= int64(length) {
+		stop = int64(length) - 1
+	}
+
+	if start > stop {
+		return 0, 0
+	}
+
+	return int(start), int(stop) + 1
+}
+
+// BlockingPopRequest removes & returns tail of list, like RPOP, but when
+// list is empty, connection waits for a value to be pushed, for at most
+// `Timeout`, where zero timeout waits for ever. No other request of
+// connection is served, while it's waiting. Clients waiting on same key
+// are served in order they started waiting.
+//
+// Body holds length prefixed key, followed by timeout in milliseconds as
+// uint64. It's responded to like RPOP, where `StatusNotFound` denotes
+// timeout ran out.
+type BlockingPopRequest struct {
+	ReadRequest
+	Timeout time.Duration
+}
+
+func (b *BlockingPopRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	return writeEnvelope(w, b.Header, BRPOP, b.lenSize()+b.Len()+8)
+}
+
+func (b *BlockingPopRequest) WriteTo(w io.Writer) (int64, error) {
+	total, err := b.ReadRequest.WriteTo(w)
+	if err != nil {
+		return total, err
+	}
+
+	n, err := writeTTL(w, b.Timeout)
+	return total + n, err
+}
+
+func (b *BlockingPopRequest) ReadFrom(r io.Reader) (int64, error) {
+	total, err := b.ReadRequest.ReadFrom(r)
+	if err != nil {
+		return total, err
+	}
+
+	timeout, n, err := readTTL(r)
+	if err != nil {
+		return total + n, err
+	}
+
+	b.Timeout = timeout
+	return total + n, nil
+}
+
+// LenResponse is sent back for LPUSH, RPUSH & LLEN requests, holding
+// number of items in list.
+//
+// It's a RESPONSE frame, whose value is that number as uint32.
+type LenResponse struct {
+	Len uint32
+}
+
+func (l *LenResponse) WriteTo(w io.Writer) (int64, error) {
+	return l.writeFrame(w, Header{})
+}
+
+func (l *LenResponse) writeFrame(w io.Writer, hdr Header) (int64, error) {
+	val := make([]byte, 4)
+	binary.BigEndian.PutUint32(val, l.Len)
+	return writeResponse(w, hdr, StatusOK, val)
+}
+
+func (l *LenResponse) ReadFrom(r io.Reader) (int64, error) {
+	val := new(Value)
+	n, err := val.ReadFrom(r)
+	if err != nil {
+		return n, err
+	}
+
+	if val.Len() != 4 {
+		return n, errors.New("bad length")
+	}
+
+	l.Len = binary.BigEndian.Uint32(*val)
+	return n, nil
+}
+
+// ListResponse is sent back for LRANGE request.
+//
+// It's a RESPONSE frame, whose value is `Items`, as encoded by
+// `List.Encode`.
+type ListResponse struct {
+	Items List
+}
+
+func (l *ListResponse) WriteTo(w io.Writer) (int64, error) {
+	return l.writeFrame(w, Header{})
+}
+
+func (l *ListResponse) writeFrame(w io.Writer, hdr Header) (int64, error) {
+	return writeResponse(w, hdr, StatusOK, l.Items.Encode())
+}
+
+func (l *ListResponse) ReadFrom(r io.Reader) (int64, error) {
+	val := new(Value)
+	n, err := val.ReadFrom(r)
+	if err != nil {
+		return n, err
+	}
+
+	items, err := DecodeList(*val)
+	if err != nil {
+		return n, err
+	}
+
+	l.Items = items
+	return n, nil
+}
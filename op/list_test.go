@@ -0,0 +1,125 @@
+package op_test
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+func TestList(t *testing.T) {
+	for _, l1 := range []op.List{{}, {op.Value("a")}, {op.Value(""), op.Value("hello"), op.Value("world")}} {
+		l2, err := op.DecodeList(l1.Encode())
+		if err != nil || len(l2) != len(l1) {
+			t.Fatalf("Expected %q, received %q [%v]\n", l1, l2, err)
+		}
+
+		for i := range l1 {
+			if !bytes.Equal(l1[i], l2[i]) {
+				t.Fatalf("Expected %q, received %q\n", l1, l2)
+			}
+		}
+	}
+
+	encoded := op.List{op.Value("hello")}.Encode()
+	for _, bad := range []op.Value{nil, encoded[:len(encoded)-1], append(encoded, 0), {0xff, 0xff, 0xff, 0xff}} {
+		if _, err := op.DecodeList(bad); err == nil {
+			t.Fatalf("Expected %v to be rejected\n", bad)
+		}
+	}
+}
+
+func TestPushRequest(t *testing.T) {
+	key := op.Key("jobs")
+	for _, req1 := range []op.PushRequest{
+		{ReadRequest: op.ReadRequest{Key: &key}, Values: []op.Value{op.Value("a"), op.Value("b")}, Left: true},
+		{ReadRequest: op.ReadRequest{Header: op.Header{Legacy: true}, Key: &key}, Values: []op.Value{op.Value("c")}},
+	} {
+		env, body := encode(t, &req1)
+		if (env.Op == op.LPUSH) != req1.Left || (env.Op != op.LPUSH && env.Op != op.RPUSH) {
+			t.Fatalf("Expected LPUSH or RPUSH as per %+v, received %d\n", req1, env.Op)
+		}
+
+		req2 := &op.PushRequest{ReadRequest: op.ReadRequest{Header: env.Header}, Left: env.Op == op.LPUSH}
+		if err := op.ReadBody(req2, body); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if !reflect.DeepEqual(req1, *req2) {
+			t.Fatalf("Expected %+v, received %+v\n", req1, *req2)
+		}
+	}
+}
+
+func TestListRequests(t *testing.T) {
+	key := op.Key("jobs")
+	read := op.ReadRequest{Key: &key}
+
+	for _, c := range []struct {
+		req    request
+		opcode op.OP
+		read   io.ReaderFrom
+	}{
+		{&op.PopRequest{ReadRequest: read, Left: true}, op.LPOP, &op.PopRequest{Left: true}},
+		{&op.PopRequest{ReadRequest: read}, op.RPOP, &op.PopRequest{}},
+		{&op.LenRequest{ReadRequest: read}, op.LLEN, &op.LenRequest{}},
+		{&op.RangeRequest{ReadRequest: read, Start: -3, Stop: math.MaxInt64}, op.LRANGE, &op.RangeRequest{}},
+		{&op.BlockingPopRequest{ReadRequest: read, Timeout: 1500 * time.Millisecond}, op.BRPOP, &op.BlockingPopRequest{}},
+	} {
+		env, body := encode(t, c.req)
+		if env.Op != c.opcode {
+			t.Fatalf("Expected opcode %d, received %d\n", c.opcode, env.Op)
+		}
+
+		if err := op.ReadBody(c.read, body); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if !reflect.DeepEqual(c.req, c.read) {
+			t.Fatalf("Expected %+v, received %+v\n", c.req, c.read)
+		}
+	}
+}
+
+func TestRangeBounds(t *testing.T) {
+	for _, c := range []struct {
+		start, stop int64
+		length      int
+		from, to    int
+	}{
+		{0, -1, 5, 0, 5},
+		{1, 2, 5, 1, 3},
+		{-2, -1, 5, 3, 5},
+		{-10, 10, 5, 0, 5},
+		{0, math.MaxInt64, 5, 0, 5},
+		{math.MinInt64, -1, 5, 0, 5},
+		{3, 1, 5, 0, 0},
+		{5, 10, 5, 0, 0},
+		{0, -1, 0, 0, 0},
+	} {
+		req := op.RangeRequest{Start: c.start, Stop: c.stop}
+		if from, to := req.Bounds(c.length); from != c.from || to != c.to {
+			t.Fatalf("Expected [%d, %d) for %d..%d of %d, received [%d, %d)\n", c.from, c.to, c.start, c.stop, c.length, from, to)
+		}
+	}
+}
+
+func TestListResponses(t *testing.T) {
+	stream := new(bytes.Buffer)
+	len1, list1 := op.LenResponse{Len: 3}, op.ListResponse{Items: op.List{op.Value("a"), op.Value("b")}}
+	len1.WriteTo(stream)
+	list1.WriteTo(stream)
+
+	len2, list2 := new(op.LenResponse), new(op.ListResponse)
+	if _, err := len2.ReadFrom(stream); err != nil || *len2 != len1 {
+		t.Fatalf("Expected %+v, received %+v [%v]\n", len1, *len2, err)
+	}
+
+	if _, err := list2.ReadFrom(stream); err != nil || !reflect.DeepEqual(list1, *list2) {
+		t.Fatalf("Expected %+v, received %+v [%v]\n", list1, *list2, err)
+	}
+}
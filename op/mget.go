@@ -0,0 +1,236 @@
+package op
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// writeCount writes number of entries in multi-key request or response
+func writeCount(w io.Writer, n int) (int64, error) {
+	if err := binary.Write(w, binary.BigEndian, uint32(n)); err != nil {
+		return 0, err
+	}
+
+	return 4, nil
+}
+
+func readCount(r io.Reader) (uint32, int64, error) {
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return 0, 0, err
+	}
+
+	return n, 4, nil
+}
+
+// MGetRequest reads values of many keys in one round trip.
+//
+// Body holds number of keys as uint32, followed by length prefixed keys.
+type MGetRequest struct {
+	Header
+	Keys []Key
+}
+
+func (m *MGetRequest) Len() int {
+	total := 0
+	for i := range m.Keys {
+		total += m.Keys[i].len()
+	}
+
+	return total
+}
+
+func (m *MGetRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	return writeEnvelope(w, m.Header, MGET, 4+len(m.Keys)*m.lenSize()+m.Len())
+}
+
+func (m *MGetRequest) WriteTo(w io.Writer) (int64, error) {
+	return m.writeKeys(w, m.Keys)
+}
+
+func (m *MGetRequest) ReadFrom(r io.Reader) (int64, error) {
+	keys, n, err := m.readKeys(r)
+	if err != nil {
+		return n, err
+	}
+
+	m.Keys = keys
+	return n, nil
+}
+
+// writeKeys writes number of keys as uint32, followed by length prefixed
+// keys
+func (h Header) writeKeys(w io.Writer, keys []Key) (int64, error) {
+	var total int64
+
+	n, err := writeCount(w, len(keys))
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	for i := range keys {
+		n, err := h.writeLen(w, keys[i].len())
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		n, err = keys[i].writeTo(w)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+	}
+
+	return total, nil
+}
+
+func (h Header) readKeys(r io.Reader) ([]Key, int64, error) {
+	var total int64
+
+	count, n, err := readCount(r)
+	if err != nil {
+		return nil, total, err
+	}
+
+	total += n
+	keys := make([]Key, 0)
+	for i := uint32(0); i < count; i++ {
+		keySize, n, err := h.readLen(r)
+		if err != nil {
+			return nil, total, err
+		}
+
+		total += n
+		var key Key
+		n, err = key.readFrom(r, int64(keySize))
+		if err != nil {
+			return nil, total, err
+		}
+
+		total += n
+		keys = append(keys, key)
+	}
+
+	return keys, total, nil
+}
+
+// Lookup is result of reading one key of MGET request
+type Lookup struct {
+	Found bool
+	Value Value
+}
+
+// MGetResponse is sent back for a MGET request, holding one lookup per
+// requested key, in order of keys.
+//
+// It's a RESPONSE frame, whose value is number of lookups as uint32,
+// followed by status of each lookup. `StatusOK` is followed by length
+// prefixed value, while `StatusNotFound` stands alone.
+type MGetResponse struct {
+	Lookups []Lookup
+}
+
+func (m *MGetResponse) WriteTo(w io.Writer) (int64, error) {
+	return m.writeFrame(w, Header{})
+}
+
+func (m *MGetResponse) writeFrame(w io.Writer, hdr Header) (int64, error) {
+	val, err := writeLookups(hdr, m.Lookups)
+	if err != nil {
+		return 0, err
+	}
+
+	return writeResponse(w, hdr, StatusOK, val)
+}
+
+func (m *MGetResponse) ReadFrom(r io.Reader) (int64, error) {
+	val := new(Value)
+	hdr, status, n, err := val.readFrame(r)
+	if err != nil {
+		return n, err
+	}
+
+	if status != StatusOK {
+		return n, errors.New("bad status")
+	}
+
+	lookups, err := readLookups(hdr, *val)
+	if err != nil {
+		return n, err
+	}
+
+	m.Lookups = lookups
+	return n, nil
+}
+
+// writeLookups encodes number of lookups as uint32, followed by status
+// of each lookup. `StatusOK` is followed by length prefixed value, while
+// `StatusNotFound` stands alone.
+func writeLookups(hdr Header, lookups []Lookup) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	writeCount(buf, len(lookups))
+
+	for i := range lookups {
+		if !lookups[i].Found {
+			buf.WriteByte(byte(StatusNotFound))
+			continue
+		}
+
+		buf.WriteByte(byte(StatusOK))
+		if _, err := hdr.writeLen(buf, lookups[i].Value.Len()); err != nil {
+			return nil, err
+		}
+
+		buf.Write(lookups[i].Value)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func readLookups(hdr Header, val []byte) ([]Lookup, error) {
+	body := bytes.NewReader(val)
+	count, _, err := readCount(body)
+	if err != nil {
+		return nil, err
+	}
+
+	lookups := make([]Lookup, 0)
+	for i := uint32(0); i < count; i++ {
+		var status Status
+		if err := binary.Read(body, binary.BigEndian, &status); err != nil {
+			return nil, err
+		}
+
+		if status == StatusNotFound {
+			lookups = append(lookups, Lookup{})
+			continue
+		}
+
+		if status != StatusOK {
+			return nil, errors.New("bad status")
+		}
+
+		valLen, _, err := hdr.readLen(body)
+		if err != nil {
+			return nil, err
+		}
+
+		var v Value
+		if _, err := v.readFrom(body, int64(valLen)); err != nil {
+			return nil, err
+		}
+
+		lookups = append(lookups, Lookup{Found: true, Value: v})
+	}
+
+	if body.Len() != 0 {
+		return nil, errors.New("trailing bytes in response")
+	}
+
+	return lookups, nil
+}
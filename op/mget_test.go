@@ -0,0 +1,77 @@
+package op_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+func TestMGetRequest(t *testing.T) {
+	for _, hdr := range []op.Header{{}, {Legacy: true}, {Tagged: true, ID: 3}} {
+		req1 := op.MGetRequest{Header: hdr, Keys: []op.Key{"a", "", "hello"}}
+		stream := new(bytes.Buffer)
+
+		if _, err := req1.WriteEnvelope(stream); err != nil {
+			t.Fatalf("Failed to write envelope : %s\n", err.Error())
+		}
+
+		if _, err := req1.WriteTo(stream); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+
+		env, err := op.ReadEnvelope(stream)
+		if err != nil {
+			t.Fatalf("Failed to read envelope : %s\n", err.Error())
+		}
+
+		if env.Op != op.MGET || int(env.BodyLen) != stream.Len() {
+			t.Fatalf("Bad length denotation in envelope\n")
+		}
+
+		req2 := &op.MGetRequest{Header: env.Header}
+		if err := op.ReadBody(req2, stream.Bytes()); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if !reflect.DeepEqual(req1, *req2) {
+			t.Fatalf("Expected %+v, received %+v\n", req1, *req2)
+		}
+	}
+
+	// declared count larger than keys present
+	if err := op.ReadBody(new(op.MGetRequest), []byte{0, 0, 0, 2, 0, 0, 0, 0}); err == nil {
+		t.Fatalf("Expected truncated body to be rejected\n")
+	}
+}
+
+func TestMGetResponse(t *testing.T) {
+	for _, hdr := range []op.Header{{}, {Legacy: true}} {
+		resp1 := op.MGetResponse{Lookups: []op.Lookup{
+			{Found: true, Value: op.Value("1")},
+			{Found: false},
+			{Found: true, Value: op.Value{}},
+		}}
+		stream := new(bytes.Buffer)
+
+		if _, err := op.WriteResponse(stream, hdr, &resp1); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+
+		resp2 := new(op.MGetResponse)
+		if _, err := resp2.ReadFrom(stream); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if len(resp2.Lookups) != 3 {
+			t.Fatalf("Expected 3 lookups, received %d\n", len(resp2.Lookups))
+		}
+
+		for i := range resp1.Lookups {
+			if resp1.Lookups[i].Found != resp2.Lookups[i].Found || !bytes.Equal(resp1.Lookups[i].Value, resp2.Lookups[i].Value) {
+				t.Fatalf("Expected %+v, received %+v\n", resp1.Lookups[i], resp2.Lookups[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package op
+
+import (
+	"io"
+)
+
+// Pair is one key & value, written by MSET request
+type Pair struct {
+	Key   Key
+	Value Value
+}
+
+// MSetRequest writes many keys in one round trip, which are applied
+// atomically. When a key is repeated, its last value is kept.
+//
+// Body holds number of pairs as uint32, followed by length prefixed key
+// & value of each pair. It's responded to with `StatusOK`.
+type MSetRequest struct {
+	Header
+	Pairs []Pair
+}
+
+func (m *MSetRequest) Len() int {
+	total := 0
+	for i := range m.Pairs {
+		total += m.Pairs[i].Key.len() + m.Pairs[i].Value.Len()
+	}
+
+	return total
+}
+
+func (m *MSetRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	return writeEnvelope(w, m.Header, MSET, 4+2*len(m.Pairs)*m.lenSize()+m.Len())
+}
+
+func (m *MSetRequest) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+
+	n, err := writeCount(w, len(m.Pairs))
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	for i := range m.Pairs {
+		n, err := m.writeLen(w, m.Pairs[i].Key.len())
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		n, err = m.Pairs[i].Key.writeTo(w)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		n, err = m.writeLen(w, m.Pairs[i].Value.Len())
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		n, err = m.Pairs[i].Value.writeTo(w)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+	}
+
+	return total, nil
+}
+
+func (m *MSetRequest) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+
+	count, n, err := readCount(r)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	pairs := make([]Pair, 0)
+	for i := uint32(0); i < count; i++ {
+		var pair Pair
+
+		keySize, n, err := m.readLen(r)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		n, err = pair.Key.readFrom(r, int64(keySize))
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		valSize, n, err := m.readLen(r)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		n, err = pair.Value.readFrom(r, int64(valSize))
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		pairs = append(pairs, pair)
+	}
+
+	m.Pairs = pairs
+	return total, nil
+}
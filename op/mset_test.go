@@ -0,0 +1,46 @@
+package op_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+func TestMSetRequest(t *testing.T) {
+	for _, hdr := range []op.Header{{}, {Legacy: true}, {Tagged: true, ID: 3}} {
+		req1 := op.MSetRequest{Header: hdr, Pairs: []op.Pair{
+			{Key: "a", Value: op.Value("1")},
+			{Key: "b", Value: op.Value{}},
+			{Key: "a", Value: op.Value("2")},
+		}}
+		stream := new(bytes.Buffer)
+
+		if _, err := req1.WriteEnvelope(stream); err != nil {
+			t.Fatalf("Failed to write envelope : %s\n", err.Error())
+		}
+
+		if _, err := req1.WriteTo(stream); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+
+		env, err := op.ReadEnvelope(stream)
+		if err != nil {
+			t.Fatalf("Failed to read envelope : %s\n", err.Error())
+		}
+
+		if env.Op != op.MSET || int(env.BodyLen) != stream.Len() {
+			t.Fatalf("Bad length denotation in envelope\n")
+		}
+
+		req2 := &op.MSetRequest{Header: env.Header}
+		if err := op.ReadBody(req2, stream.Bytes()); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if !reflect.DeepEqual(req1, *req2) {
+			t.Fatalf("Expected %+v, received %+v\n", req1, *req2)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package op
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// Watch makes transaction abort, unless key is still at `Version`, as
+// read using GETV, where zero denotes missing key
+type Watch struct {
+	Key     Key
+	Version uint64
+}
+
+// TxOp is one operation of transaction, where `Op` is one of READ, WRITE
+// or DELETE. `Value` & `TTL` are used only by WRITE.
+type TxOp struct {
+	Op    OP
+	Key   Key
+	Value Value
+	TTL   time.Duration
+}
+
+// TxRequest applies operations atomically, in order, after checking
+// watched keys haven't changed. A READ sees writes of operations before
+// it, while other clients see either all or none of them.
+//
+// Body holds number of watches as uint32, followed by length prefixed
+// key & uint64 version of each watch. Then comes number of operations as
+// uint32, followed by opcode byte & length prefixed key of each, where
+// WRITE also carries length prefixed value & TTL. It's responded to with
+// `TxResponse`.
+type TxRequest struct {
+	Header
+	Watches []Watch
+	Ops     []TxOp
+}
+
+func (t *TxRequest) Len() int {
+	total := 0
+	for i := range t.Watches {
+		total += t.Watches[i].Key.len()
+	}
+
+	for i := range t.Ops {
+		total += t.Ops[i].Key.len() + t.Ops[i].Value.Len()
+	}
+
+	return total
+}
+
+func (t *TxRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	bodyLen := 4 + len(t.Watches)*(t.lenSize()+8) + 4 + len(t.Ops)*(1+t.lenSize()) + t.Len()
+	for i := range t.Ops {
+		if t.Ops[i].Op == WRITE {
+			bodyLen += t.lenSize() + 8
+		}
+	}
+
+	return writeEnvelope(w, t.Header, EXEC, bodyLen)
+}
+
+func (t *TxRequest) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+
+	n, err := writeCount(w, len(t.Watches))
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	for i := range t.Watches {
+		n, err := t.writeKey(w, &t.Watches[i].Key)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		if err := binary.Write(w, binary.BigEndian, t.Watches[i].Version); err != nil {
+			return total, err
+		}
+
+		total += 8
+	}
+
+	n, err = writeCount(w, len(t.Ops))
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	for i := range t.Ops {
+		o := &t.Ops[i]
+		if !txOpcode(o.Op) {
+			return total, errors.New("bad transaction opcode")
+		}
+
+		n, err := o.Op.WriteTo(w)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		n, err = t.writeKey(w, &o.Key)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		if o.Op != WRITE {
+			continue
+		}
+
+		n, err = t.writeLen(w, o.Value.Len())
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		n, err = o.Value.writeTo(w)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		n, err = writeTTL(w, o.TTL)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+	}
+
+	return total, nil
+}
+
+func (t *TxRequest) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+
+	count, n, err := readCount(r)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	watches := make([]Watch, 0)
+	for i := uint32(0); i < count; i++ {
+		var watch Watch
+		n, err := t.readKey(r, &watch.Key)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		if err := binary.Read(r, binary.BigEndian, &watch.Version); err != nil {
+			return total, err
+		}
+
+		total += 8
+		watches = append(watches, watch)
+	}
+
+	count, n, err = readCount(r)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	ops := make([]TxOp, 0)
+	for i := uint32(0); i < count; i++ {
+		var o TxOp
+		n, err := o.Op.ReadFrom(r)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		if !txOpcode(o.Op) {
+			return total, errors.New("bad transaction opcode")
+		}
+
+		n, err = t.readKey(r, &o.Key)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		if o.Op == WRITE {
+			valSize, n, err := t.readLen(r)
+			if err != nil {
+				return total, err
+			}
+
+			total += n
+			n, err = o.Value.readFrom(r, int64(valSize))
+			if err != nil {
+				return total, err
+			}
+
+			total += n
+			o.TTL, n, err = readTTL(r)
+			if err != nil {
+				return total, err
+			}
+
+			total += n
+		}
+
+		ops = append(ops, o)
+	}
+
+	t.Watches = watches
+	t.Ops = ops
+	return total, nil
+}
+
+func (t *TxRequest) writeKey(w io.Writer, key *Key) (int64, error) {
+	n, err := t.writeLen(w, key.len())
+	if err != nil {
+		return n, err
+	}
+
+	m, err := key.writeTo(w)
+	return n + m, err
+}
+
+func (t *TxRequest) readKey(r io.Reader, key *Key) (int64, error) {
+	keySize, n, err := t.readLen(r)
+	if err != nil {
+		return n, err
+	}
+
+	m, err := key.readFrom(r, int64(keySize))
+	return n + m, err
+}
+
+// txOpcode tells whether opcode can be used in transaction
+func txOpcode(o OP) bool {
+	return o == READ || o == WRITE || o == DELETE
+}
+
+// TxResponse is sent back for a transaction, holding one result per
+// operation, in order, unless it's `Aborted` as some watched key changed.
+//
+// Result of READ tells whether key was found & its value, of DELETE
+// whether key existed, while WRITE is always found, with empty value.
+//
+// It's a RESPONSE frame, whose value is encoded like that of
+// `MGetResponse`. Aborted transaction is denoted using
+// `StatusNotApplied`, with empty value.
+type TxResponse struct {
+	Aborted bool
+	Results []Lookup
+}
+
+func (t *TxResponse) WriteTo(w io.Writer) (int64, error) {
+	return t.writeFrame(w, Header{})
+}
+
+func (t *TxResponse) writeFrame(w io.Writer, hdr Header) (int64, error) {
+	if t.Aborted {
+		return StatusNotApplied.writeFrame(w, hdr)
+	}
+
+	val, err := writeLookups(hdr, t.Results)
+	if err != nil {
+		return 0, err
+	}
+
+	return writeResponse(w, hdr, StatusOK, val)
+}
+
+func (t *TxResponse) ReadFrom(r io.Reader) (int64, error) {
+	val := new(Value)
+	hdr, status, n, err := val.readFrame(r)
+	if err != nil {
+		return n, err
+	}
+
+	if status == StatusNotApplied {
+		*t = TxResponse{Aborted: true}
+		return n, nil
+	}
+
+	if status != StatusOK {
+		return n, errors.New("bad status")
+	}
+
+	results, err := readLookups(hdr, *val)
+	if err != nil {
+		return n, err
+	}
+
+	*t = TxResponse{Results: results}
+	return n, nil
+}
@@ -0,0 +1,72 @@
+package op_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+func TestTxRequest(t *testing.T) {
+	for _, hdr := range []op.Header{{}, {Legacy: true}, {Tagged: true, ID: 7}} {
+		req1 := op.TxRequest{
+			Header:  hdr,
+			Watches: []op.Watch{{Key: "a", Version: 1 << 40}, {Key: "b"}},
+			Ops: []op.TxOp{
+				{Op: op.READ, Key: "a"},
+				{Op: op.WRITE, Key: "b", Value: op.Value("hello"), TTL: time.Second},
+				{Op: op.WRITE, Key: "c", Value: op.Value{}},
+				{Op: op.DELETE, Key: "a"},
+			},
+		}
+
+		env, body := encode(t, &req1)
+		if env.Op != op.EXEC {
+			t.Fatalf("Expected EXEC, received %d\n", env.Op)
+		}
+
+		req2 := &op.TxRequest{Header: env.Header}
+		if err := op.ReadBody(req2, body); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if !reflect.DeepEqual(req1, *req2) {
+			t.Fatalf("Expected %+v, received %+v\n", req1, *req2)
+		}
+	}
+
+	// only reads, writes & deletes make up transaction
+	req := op.TxRequest{Ops: []op.TxOp{{Op: op.SAVE, Key: "a"}}}
+	if _, err := req.WriteTo(new(bytes.Buffer)); err == nil {
+		t.Fatalf("Expected SAVE to be rejected\n")
+	}
+
+	body := []byte{0, 0, 0, 0, 0, 0, 0, 1, byte(op.SAVE), 0, 0, 0, 1, 'a'}
+	if err := op.ReadBody(new(op.TxRequest), body); err == nil || err.Code != op.Malformed {
+		t.Fatalf("Expected SAVE to be rejected as malformed, received %v\n", err)
+	}
+}
+
+func TestTxResponse(t *testing.T) {
+	for _, resp1 := range []op.TxResponse{
+		{Results: []op.Lookup{{Found: true, Value: op.Value("hello")}, {}, {Found: true, Value: op.Value{}}}},
+		{Results: []op.Lookup{}},
+		{Aborted: true},
+	} {
+		stream := new(bytes.Buffer)
+		if _, err := resp1.WriteTo(stream); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+
+		resp2 := new(op.TxResponse)
+		if _, err := resp2.ReadFrom(stream); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if !reflect.DeepEqual(resp1, *resp2) {
+			t.Fatalf("Expected %+v, received %+v\n", resp1, *resp2)
+		}
+	}
+}
@@ -2,17 +2,193 @@ package op
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
+	"math"
 )
 
 type OP uint8
 
 const (
-	READ     OP = iota + 1 // read request opcode
-	WRITE                  // write request opcode
-	RESPONSE               // response opcode
+	READ         OP = iota + 1 // read request opcode
+	WRITE                      // write request opcode
+	RESPONSE                   // response opcode
+	DELETE                     // delete request opcode
+	ERROR                      // error response opcode
+	EXPIRE                     // set time to live of key opcode
+	TTL                        // read time to live of key opcode
+	PERSIST                    // remove time to live of key opcode
+	EXPIREAT                   // set deadline of key opcode, used in append-only file
+	SAVE                       // write snapshot opcode
+	BGSAVE                     // write snapshot in background opcode
+	STATS                      // read server counters opcode
+	SCAN                       // list keys in order opcode
+	MGET                       // read many keys opcode
+	MSET                       // write many keys atomically opcode
+	GETV                       // read value & version of key opcode
+	CAS                        // compare & swap opcode
+	SETNX                      // write only if key is absent opcode
+	SETXX                      // write only if key is present opcode
+	INCRBY                     // add to integer value of key opcode
+	DECRBY                     // subtract from integer value of key opcode
+	EXEC                       // run transaction opcode
+	SUBSCRIBE                  // subscribe to channels opcode
+	UNSUBSCRIBE                // unsubscribe from channels opcode
+	PUBLISH                    // publish message on channel opcode
+	MESSAGE                    // pushed message opcode, sent without request
+	KSUBSCRIBE                 // watch changes of keys opcode
+	KUNSUBSCRIBE               // stop watching changes of keys opcode
+	EVENT                      // pushed change of key opcode, sent without request
+	LPUSH                      // push to head of list opcode
+	RPUSH                      // push to tail of list opcode
+	LPOP                       // pop from head of list opcode
+	RPOP                       // pop from tail of list opcode
+	LLEN                       // read length of list opcode
+	LRANGE                     // read range of list opcode
+	BRPOP                      // pop from tail of list, waiting for push opcode
 )
 
+// wide is set on opcode byte of frames, which use uint32 body, key &
+// value lengths. Frames without it follow legacy framing, where body
+// length is uint16 & key/ value lengths are uint8.
+const wide OP = 1 << 7
+
+// tagged is set on opcode byte of frames, carrying a request ID right
+// after opcode. Server echoes it back in response frame, so that client
+// can match responses, which may be sent in completion order, with its
+// requests.
+const tagged OP = 1 << 6
+
+// ErrTooLarge is returned when some length doesn't fit in the framing,
+// frame is being written in
+var ErrTooLarge = errors.New("too large for framing")
+
+// Header holds framing details of a request, which are mirrored in
+// response sent back for it
+type Header struct {
+	// Legacy framing, with uint16 body & uint8 key/ value lengths
+	Legacy bool
+	// Tagged frames carry request ID, which is denoted by `ID`
+	Tagged bool
+	ID     uint32
+}
+
+func (h Header) opcode(o OP) OP {
+	if h.Tagged {
+		o |= tagged
+	}
+
+	if h.Legacy {
+		return o
+	}
+
+	return o | wide
+}
+
+// writeOpcode writes opcode byte, followed by request ID for tagged frames
+func (h Header) writeOpcode(w io.Writer, o OP) (int64, error) {
+	if _, err := h.opcode(o).WriteTo(w); err != nil {
+		return 0, err
+	}
+
+	if !h.Tagged {
+		return 1, nil
+	}
+
+	if err := binary.Write(w, binary.BigEndian, h.ID); err != nil {
+		return 1, err
+	}
+
+	return 1 + 4, nil
+}
+
+// readOpcode reads opcode byte & request ID, if frame is tagged
+func readOpcode(r io.Reader) (OP, Header, int64, error) {
+	o := new(OP)
+	if _, err := o.ReadFrom(r); err != nil {
+		return 0, Header{}, 0, err
+	}
+
+	opcode, hdr := o.split()
+	if !hdr.Tagged {
+		return opcode, hdr, 1, nil
+	}
+
+	if err := binary.Read(r, binary.BigEndian, &hdr.ID); err != nil {
+		return opcode, hdr, 1, err
+	}
+
+	return opcode, hdr, 1 + 4, nil
+}
+
+// lenSize returns number of bytes used for denoting key/ value length
+func (h Header) lenSize() int {
+	if h.Legacy {
+		return 1
+	}
+
+	return 4
+}
+
+func (h Header) writeLen(w io.Writer, n int) (int64, error) {
+	if h.Legacy {
+		if n > math.MaxUint8 {
+			return 0, ErrTooLarge
+		}
+
+		if err := binary.Write(w, binary.BigEndian, uint8(n)); err != nil {
+			return 0, err
+		}
+
+		return 1, nil
+	}
+
+	if uint64(n) > math.MaxUint32 {
+		return 0, ErrTooLarge
+	}
+
+	if err := binary.Write(w, binary.BigEndian, uint32(n)); err != nil {
+		return 0, err
+	}
+
+	return 4, nil
+}
+
+func (h Header) readLen(r io.Reader) (uint32, int64, error) {
+	if h.Legacy {
+		var n uint8
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return 0, 0, err
+		}
+
+		return uint32(n), 1, nil
+	}
+
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return 0, 0, err
+	}
+
+	return n, 4, nil
+}
+
+// readBytes reads exactly n bytes. When reader knows how many bytes
+// are left in it, declared length is checked before allocating buffer,
+// so that a bogus length in a small body can't trigger huge allocation.
+func readBytes(r io.Reader, n int64) ([]byte, error) {
+	if rem, ok := r.(interface{ Len() int }); ok && int64(rem.Len()) < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
 func (o OP) WriteTo(w io.Writer) (int64, error) {
 	if err := binary.Write(w, binary.BigEndian, o); err != nil {
 		return 0, err
@@ -32,17 +208,99 @@ func (o *OP) ReadFrom(r io.Reader) (int64, error) {
 	return 1, nil
 }
 
-func ReadEnvelope(r io.Reader) (OP, uint16, error) {
-	op := new(OP)
+// split separates framing flags from opcode byte, read off the wire
+func (o OP) split() (OP, Header) {
+	return o &^ (wide | tagged), Header{Legacy: o&wide == 0, Tagged: o&tagged != 0}
+}
 
-	if _, err := op.ReadFrom(r); err != nil {
-		return 0, 0, err
+// EnvelopeLen returns size of whole envelope, given its first byte
+// i.e. opcode, as read off the wire
+func (o OP) EnvelopeLen() int {
+	n := 1
+	_, hdr := o.split()
+	if hdr.Tagged {
+		n += 4
+	}
+
+	if hdr.Legacy {
+		return n + 2
+	}
+
+	return n + 4
+}
+
+// Envelope is written before every request body
+type Envelope struct {
+	Header
+	Op      OP
+	BodyLen uint32
+}
+
+// CheckBodyLen ensures body length declared in envelope doesn't exceed
+// max, otherwise error to be sent back to client is returned
+func (e Envelope) CheckBodyLen(max uint32) *Error {
+	if e.BodyLen <= max {
+		return nil
+	}
+
+	return &Error{Code: TooLarge, Message: fmt.Sprintf("body of %d bytes, allowed %d", e.BodyLen, max)}
+}
+
+func writeEnvelope(w io.Writer, hdr Header, op OP, bodyLen int) (int64, error) {
+	var total int64
+
+	if hdr.Legacy && bodyLen > math.MaxUint16 {
+		return total, ErrTooLarge
+	}
+
+	if !hdr.Legacy && uint64(bodyLen) > math.MaxUint32 {
+		return total, ErrTooLarge
+	}
+
+	n, err := hdr.writeOpcode(w, op)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	if hdr.Legacy {
+		if err := binary.Write(w, binary.BigEndian, uint16(bodyLen)); err != nil {
+			return total, err
+		}
+
+		total += 2
+		return total, nil
+	}
+
+	if err := binary.Write(w, binary.BigEndian, uint32(bodyLen)); err != nil {
+		return total, err
+	}
+
+	total += 4
+	return total, nil
+}
+
+func ReadEnvelope(r io.Reader) (Envelope, error) {
+	opcode, hdr, _, err := readOpcode(r)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	env := Envelope{Header: hdr, Op: opcode}
+
+	if hdr.Legacy {
+		var bodyLength uint16
+		if err := binary.Read(r, binary.BigEndian, &bodyLength); err != nil {
+			return env, err
+		}
+
+		env.BodyLen = uint32(bodyLength)
+		return env, nil
 	}
 
-	var bodyLength uint16
-	if err := binary.Read(r, binary.BigEndian, &bodyLength); err != nil {
-		return *op, 0, err
+	if err := binary.Read(r, binary.BigEndian, &env.BodyLen); err != nil {
+		return env, err
 	}
 
-	return *op, bodyLength, nil
+	return env, nil
 }
@@ -0,0 +1,124 @@
+package op_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+func TestLegacyFraming(t *testing.T) {
+	key := op.Key("hello")
+	val := op.Value("world")
+	writeReq1 := op.WriteRequest{Header: op.Header{Legacy: true}, Key: &key, Value: &val}
+	stream := new(bytes.Buffer)
+
+	if _, err := writeReq1.WriteEnvelope(stream); err != nil {
+		t.Fatalf("Failed to write envelope : %s\n", err.Error())
+	}
+
+	if _, err := writeReq1.WriteTo(stream); err != nil {
+		t.Fatalf("Failed to write to stream : %s\n", err.Error())
+	}
+
+	// opcode, uint16 body length, uint8 key & value lengths
+	expected := []byte{byte(op.WRITE), 0, 12, 5, 'h', 'e', 'l', 'l', 'o', 5, 'w', 'o', 'r', 'l', 'd'}
+	if !bytes.Equal(stream.Bytes(), expected) {
+		t.Fatalf("Expected legacy frame % x, received % x\n", expected, stream.Bytes())
+	}
+
+	env, err := op.ReadEnvelope(stream)
+	if err != nil {
+		t.Fatalf("Failed to read envelope : %s\n", err.Error())
+	}
+
+	if !env.Legacy || env.Op != op.WRITE || env.BodyLen != 12 {
+		t.Fatalf("Bad envelope read : %+v\n", env)
+	}
+
+	writeReq2 := op.WriteRequest{Header: env.Header}
+	if _, err := writeReq2.ReadFrom(stream); err != nil {
+		t.Fatalf("Failed to read from stream : %s\n", err.Error())
+	}
+
+	if *writeReq2.Key != key || !bytes.Equal(*writeReq2.Value, val) {
+		t.Fatalf("Bad read to/ write from stream\n")
+	}
+}
+
+func TestLegacyTooLarge(t *testing.T) {
+	key := op.Key("hello")
+	val := op.Value(bytes.Repeat([]byte{'a'}, 256))
+	writeReq := op.WriteRequest{Header: op.Header{Legacy: true}, Key: &key, Value: &val}
+
+	if _, err := writeReq.WriteTo(new(bytes.Buffer)); !errors.Is(err, op.ErrTooLarge) {
+		t.Fatalf("Expected value to be rejected, received %v\n", err)
+	}
+
+	stream := new(bytes.Buffer)
+	if _, err := op.WriteResponse(stream, op.Header{Legacy: true}, &val); err != nil {
+		t.Fatalf("Failed to write response : %s\n", err.Error())
+	}
+
+	var opErr *op.Error
+	if _, err := new(op.Value).ReadFrom(stream); !errors.As(err, &opErr) || opErr.Code != op.TooLarge {
+		t.Fatalf("Expected too large error frame, received %v\n", err)
+	}
+}
+
+func TestLargeValue(t *testing.T) {
+	key := op.Key(bytes.Repeat([]byte{'k'}, 1<<10))
+	val := op.Value(bytes.Repeat([]byte{'v'}, 1<<18))
+	writeReq1 := op.WriteRequest{Key: &key, Value: &val}
+	stream := new(bytes.Buffer)
+
+	if _, err := writeReq1.WriteEnvelope(stream); err != nil {
+		t.Fatalf("Failed to write envelope : %s\n", err.Error())
+	}
+
+	if _, err := writeReq1.WriteTo(stream); err != nil {
+		t.Fatalf("Failed to write to stream : %s\n", err.Error())
+	}
+
+	env, err := op.ReadEnvelope(stream)
+	if err != nil {
+		t.Fatalf("Failed to read envelope : %s\n", err.Error())
+	}
+
+	if env.Legacy || int(env.BodyLen) != stream.Len() {
+		t.Fatalf("Bad envelope read : %+v\n", env)
+	}
+
+	writeReq2 := new(op.WriteRequest)
+	if err := op.ReadBody(writeReq2, stream.Bytes()); err != nil {
+		t.Fatalf("Failed to read body : %s\n", err.Error())
+	}
+
+	if *writeReq2.Key != key || !bytes.Equal(*writeReq2.Value, val) {
+		t.Fatalf("Bad read to/ write from stream\n")
+	}
+
+	stream.Reset()
+	if _, err := op.WriteResponse(stream, env.Header, writeReq2.Value); err != nil {
+		t.Fatalf("Failed to write response : %s\n", err.Error())
+	}
+
+	resp := new(op.Value)
+	if _, err := resp.ReadFrom(stream); err != nil {
+		t.Fatalf("Failed to read response : %s\n", err.Error())
+	}
+
+	if !bytes.Equal(*resp, val) {
+		t.Fatalf("Bad response read\n")
+	}
+}
+
+func TestBogusLength(t *testing.T) {
+	// key length claims 4 GiB, while body is only 5 bytes long
+	body := []byte{0xff, 0xff, 0xff, 0xff, 'a'}
+
+	if err := op.ReadBody(new(op.ReadRequest), body); err == nil || err.Code != op.Malformed {
+		t.Fatalf("Expected bogus key length to be rejected\n")
+	}
+}
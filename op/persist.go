@@ -0,0 +1,60 @@
+package op
+
+import (
+	"io"
+)
+
+// PersistRequest removes time to live of key, so that it never expires.
+//
+// It's responded to with `StatusOK`, when key exists, otherwise with
+// `StatusNotFound`.
+type PersistRequest struct {
+	Header
+	Key *Key
+}
+
+func (p *PersistRequest) Len() int {
+	return p.Key.len()
+}
+
+func (p *PersistRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	return writeEnvelope(w, p.Header, PERSIST, p.lenSize()+p.Len())
+}
+
+func (p *PersistRequest) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+
+	n, err := p.writeLen(w, p.Key.len())
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	n, err = p.Key.writeTo(w)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	return total, nil
+}
+
+func (p *PersistRequest) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+
+	keySize, n, err := p.readLen(r)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	key := new(Key)
+	n, err = key.readFrom(r, int64(keySize))
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	p.Key = key
+	return total, nil
+}
@@ -0,0 +1,45 @@
+package op_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+func TestPersistRequest(t *testing.T) {
+	key := op.Key("hello")
+	req1 := op.PersistRequest{Key: &key}
+	stream := new(bytes.Buffer)
+
+	if _, err := req1.WriteEnvelope(stream); err != nil {
+		t.Fatalf("Failed to write envelope : %s\n", err.Error())
+	}
+
+	if _, err := req1.WriteTo(stream); err != nil {
+		t.Fatalf("Failed to write : %s\n", err.Error())
+	}
+
+	req2 := new(op.PersistRequest)
+
+	env, err := op.ReadEnvelope(stream)
+	if err != nil {
+		t.Fatalf("Failed to read envelope : %s\n", err.Error())
+	}
+
+	if env.Op != op.PERSIST {
+		t.Fatalf("Expected PERSIST opcode\n")
+	}
+
+	if _, err := req2.ReadFrom(stream); err != nil {
+		t.Fatalf("Failed to read : %s\n", err.Error())
+	}
+
+	if int(env.BodyLen) != req2.Len()+4 {
+		t.Fatalf("Bad length denotation in envelope\n")
+	}
+
+	if *req1.Key != *req2.Key {
+		t.Fatalf("Bad write to/ read from stream\n")
+	}
+}
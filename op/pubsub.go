@@ -0,0 +1,286 @@
+package op
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// SubscribeRequest subscribes connection to channels, switching it into
+// push mode, where `Message` frames are sent to it without any request.
+// With `Unsubscribe` set, it's sent as UNSUBSCRIBE & removes
+// subscriptions instead, where no channels denote all of them. Once
+// connection isn't subscribed to any channel, nor watching any key, it
+// leaves push mode.
+//
+// Body holds number of channels as uint32, followed by length prefixed
+// channels. It's responded to with `SubscribeResponse`.
+type SubscribeRequest struct {
+	Header
+	Channels    []Key
+	Unsubscribe bool
+}
+
+func (s *SubscribeRequest) Len() int {
+	total := 0
+	for i := range s.Channels {
+		total += s.Channels[i].len()
+	}
+
+	return total
+}
+
+func (s *SubscribeRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	bodyLen := 4 + len(s.Channels)*s.lenSize() + s.Len()
+	if s.Unsubscribe {
+		return writeEnvelope(w, s.Header, UNSUBSCRIBE, bodyLen)
+	}
+
+	return writeEnvelope(w, s.Header, SUBSCRIBE, bodyLen)
+}
+
+func (s *SubscribeRequest) WriteTo(w io.Writer) (int64, error) {
+	return s.writeKeys(w, s.Channels)
+}
+
+func (s *SubscribeRequest) ReadFrom(r io.Reader) (int64, error) {
+	channels, n, err := s.readKeys(r)
+	if err != nil {
+		return n, err
+	}
+
+	s.Channels = channels
+	return n, nil
+}
+
+// SubscribeResponse is sent back for SUBSCRIBE, UNSUBSCRIBE, KSUBSCRIBE &
+// KUNSUBSCRIBE requests, holding number of channels & key patterns
+// connection is subscribed to afterwards.
+//
+// It's a RESPONSE frame, whose value is that number as uint32.
+type SubscribeResponse struct {
+	Count uint32
+}
+
+func (s *SubscribeResponse) WriteTo(w io.Writer) (int64, error) {
+	return s.writeFrame(w, Header{})
+}
+
+func (s *SubscribeResponse) writeFrame(w io.Writer, hdr Header) (int64, error) {
+	val := make([]byte, 4)
+	binary.BigEndian.PutUint32(val, s.Count)
+	return writeResponse(w, hdr, StatusOK, val)
+}
+
+func (s *SubscribeResponse) ReadFrom(r io.Reader) (int64, error) {
+	val := new(Value)
+	n, err := val.ReadFrom(r)
+	if err != nil {
+		return n, err
+	}
+
+	if val.Len() != 4 {
+		return n, errors.New("bad count length")
+	}
+
+	s.Count = binary.BigEndian.Uint32(*val)
+	return n, nil
+}
+
+// PublishRequest sends message to every connection subscribed to
+// channel.
+//
+// Body holds length prefixed channel & message. It's responded to with
+// `PublishResponse`.
+type PublishRequest struct {
+	Header
+	Channel *Key
+	Message *Value
+}
+
+func (p *PublishRequest) Len() int {
+	return p.Channel.len() + p.Message.Len()
+}
+
+func (p *PublishRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	return writeEnvelope(w, p.Header, PUBLISH, 2*p.lenSize()+p.Len())
+}
+
+func (p *PublishRequest) WriteTo(w io.Writer) (int64, error) {
+	return p.writePair(w, p.Channel, p.Message)
+}
+
+func (p *PublishRequest) ReadFrom(r io.Reader) (int64, error) {
+	channel, msg := new(Key), new(Value)
+	n, err := p.readPair(r, channel, msg)
+	if err != nil {
+		return n, err
+	}
+
+	p.Channel = channel
+	p.Message = msg
+	return n, nil
+}
+
+// PublishResponse is sent back for a PUBLISH request, holding number of
+// subscribers message got queued for. Subscribers, which had to drop it,
+// aren't counted.
+//
+// It's a RESPONSE frame, whose value is that number as uint32.
+type PublishResponse struct {
+	Receivers uint32
+}
+
+func (p *PublishResponse) WriteTo(w io.Writer) (int64, error) {
+	return p.writeFrame(w, Header{})
+}
+
+func (p *PublishResponse) writeFrame(w io.Writer, hdr Header) (int64, error) {
+	val := make([]byte, 4)
+	binary.BigEndian.PutUint32(val, p.Receivers)
+	return writeResponse(w, hdr, StatusOK, val)
+}
+
+func (p *PublishResponse) ReadFrom(r io.Reader) (int64, error) {
+	val := new(Value)
+	n, err := val.ReadFrom(r)
+	if err != nil {
+		return n, err
+	}
+
+	if val.Len() != 4 {
+		return n, errors.New("bad count length")
+	}
+
+	p.Receivers = binary.BigEndian.Uint32(*val)
+	return n, nil
+}
+
+// Message is pushed to subscribed connections, for every message
+// published on channel, they're subscribed to.
+//
+// It's framed like a request, in wide framing, without request ID, where
+// body holds length prefixed channel & payload.
+type Message struct {
+	Channel Key
+	Payload Value
+}
+
+func (m *Message) WriteTo(w io.Writer) (int64, error) {
+	var hdr Header
+
+	n, err := writeEnvelope(w, hdr, MESSAGE, 2*hdr.lenSize()+m.Channel.len()+m.Payload.Len())
+	if err != nil {
+		return n, err
+	}
+
+	m2, err := hdr.writePair(w, &m.Channel, &m.Payload)
+	return n + m2, err
+}
+
+func (m *Message) ReadFrom(r io.Reader) (int64, error) {
+	env, err := ReadEnvelope(r)
+	if err != nil {
+		return 0, err
+	}
+
+	total := int64(env.opcode(env.Op).EnvelopeLen())
+	if env.Op != MESSAGE {
+		return total, errors.New("bad opcode")
+	}
+
+	body, err := readBytes(r, int64(env.BodyLen))
+	if err != nil {
+		return total, err
+	}
+
+	total += int64(env.BodyLen)
+	var msg Message
+	rd := bytes.NewReader(body)
+	if _, err := env.readPair(rd, &msg.Channel, &msg.Payload); err != nil {
+		return total, err
+	}
+
+	if rd.Len() != 0 {
+		return total, errors.New("trailing bytes in message")
+	}
+
+	*m = msg
+	return total, nil
+}
+
+// IsMessage tells whether next frame in stream is a pushed message,
+// without consuming it, so that subscribed connection can tell messages
+// apart from responses
+func IsMessage(r *bufio.Reader) (bool, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return false, err
+	}
+
+	opcode, _ := OP(b[0]).split()
+	return opcode == MESSAGE, nil
+}
+
+// writePair writes length prefixed key & value
+func (h Header) writePair(w io.Writer, key *Key, val *Value) (int64, error) {
+	var total int64
+
+	n, err := h.writeLen(w, key.len())
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	n, err = key.writeTo(w)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	n, err = h.writeLen(w, val.Len())
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	n, err = val.writeTo(w)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	return total, nil
+}
+
+func (h Header) readPair(r io.Reader, key *Key, val *Value) (int64, error) {
+	var total int64
+
+	keySize, n, err := h.readLen(r)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	n, err = key.readFrom(r, int64(keySize))
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	valSize, n, err := h.readLen(r)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	n, err = val.readFrom(r, int64(valSize))
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	return total, nil
+}
@@ -0,0 +1,89 @@
+package op_test
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+func TestSubscribeRequest(t *testing.T) {
+	for _, req1 := range []op.SubscribeRequest{
+		{Channels: []op.Key{"news", "sports"}},
+		{Header: op.Header{Legacy: true}, Channels: []op.Key{"news"}, Unsubscribe: true},
+		{Channels: []op.Key{}, Unsubscribe: true},
+	} {
+		env, body := encode(t, &req1)
+		if (env.Op == op.UNSUBSCRIBE) != req1.Unsubscribe || (env.Op != op.SUBSCRIBE && env.Op != op.UNSUBSCRIBE) {
+			t.Fatalf("Expected SUBSCRIBE or UNSUBSCRIBE as per %+v, received %d\n", req1, env.Op)
+		}
+
+		req2 := &op.SubscribeRequest{Header: env.Header, Unsubscribe: env.Op == op.UNSUBSCRIBE}
+		if err := op.ReadBody(req2, body); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if !reflect.DeepEqual(req1, *req2) {
+			t.Fatalf("Expected %+v, received %+v\n", req1, *req2)
+		}
+	}
+}
+
+func TestPublishRequest(t *testing.T) {
+	channel, msg := op.Key("news"), op.Value("hello")
+	env, body := encode(t, &op.PublishRequest{Channel: &channel, Message: &msg})
+	if env.Op != op.PUBLISH {
+		t.Fatalf("Expected PUBLISH, received %d\n", env.Op)
+	}
+
+	req := &op.PublishRequest{Header: env.Header}
+	if err := op.ReadBody(req, body); err != nil || *req.Channel != channel || string(*req.Message) != string(msg) {
+		t.Fatalf("Expected `%s` on `%s`, received %+v [%v]\n", msg, channel, req, err)
+	}
+}
+
+func TestPubSubResponses(t *testing.T) {
+	stream := new(bytes.Buffer)
+	sub1, pub1 := op.SubscribeResponse{Count: 3}, op.PublishResponse{Receivers: 1 << 20}
+	sub1.WriteTo(stream)
+	pub1.WriteTo(stream)
+
+	sub2, pub2 := new(op.SubscribeResponse), new(op.PublishResponse)
+	if _, err := sub2.ReadFrom(stream); err != nil || *sub2 != sub1 {
+		t.Fatalf("Expected %+v, received %+v [%v]\n", sub1, *sub2, err)
+	}
+
+	if _, err := pub2.ReadFrom(stream); err != nil || *pub2 != pub1 {
+		t.Fatalf("Expected %+v, received %+v [%v]\n", pub1, *pub2, err)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	stream := new(bytes.Buffer)
+	msg1 := op.Message{Channel: "news", Payload: op.Value("hello")}
+	msg1.WriteTo(stream)
+
+	// message followed by response, as seen by subscribed connection
+	resp := op.SubscribeResponse{Count: 1}
+	resp.WriteTo(stream)
+
+	r := bufio.NewReader(stream)
+	if ok, err := op.IsMessage(r); err != nil || !ok {
+		t.Fatalf("Expected message, received %v [%v]\n", ok, err)
+	}
+
+	msg2 := new(op.Message)
+	if _, err := msg2.ReadFrom(r); err != nil || !reflect.DeepEqual(msg1, *msg2) {
+		t.Fatalf("Expected %+v, received %+v [%v]\n", msg1, *msg2, err)
+	}
+
+	if ok, err := op.IsMessage(r); err != nil || ok {
+		t.Fatalf("Expected response, received %v [%v]\n", ok, err)
+	}
+
+	if _, err := new(op.Message).ReadFrom(r); err == nil {
+		t.Fatalf("Expected response to be rejected as message\n")
+	}
+}
@@ -1,11 +1,11 @@
 package op
 
 import (
-	"encoding/binary"
 	"io"
 )
 
 type ReadRequest struct {
+	Header
 	Key *Key
 }
 
@@ -14,31 +14,19 @@ func (r *ReadRequest) Len() int {
 }
 
 func (r *ReadRequest) WriteEnvelope(w io.Writer) (int64, error) {
-	var total int64
-
-	op := READ
-	if _, err := op.WriteTo(w); err != nil {
-		return total, err
-	}
-
-	total += 1
-	if err := binary.Write(w, binary.BigEndian, uint16(r.Len()+1)); err != nil {
-		return total, err
-	}
-
-	total += 2
-	return total, nil
+	return writeEnvelope(w, r.Header, READ, r.lenSize()+r.Len())
 }
 
 func (r *ReadRequest) WriteTo(w io.Writer) (int64, error) {
 	var total int64
 
-	if err := binary.Write(w, binary.BigEndian, uint8(r.Key.len())); err != nil {
+	n, err := r.writeLen(w, r.Key.len())
+	if err != nil {
 		return total, err
 	}
 
-	total += 1
-	n, err := r.Key.writeTo(w)
+	total += n
+	n, err = r.Key.writeTo(w)
 	if err != nil {
 		return total, err
 	}
@@ -48,18 +36,16 @@ func (r *ReadRequest) WriteTo(w io.Writer) (int64, error) {
 }
 
 func (r *ReadRequest) ReadFrom(rd io.Reader) (int64, error) {
-	var (
-		total   int64
-		keySize uint8
-	)
+	var total int64
 
-	if err := binary.Read(rd, binary.BigEndian, &keySize); err != nil {
+	keySize, n, err := r.readLen(rd)
+	if err != nil {
 		return total, err
 	}
 
-	total += 1
+	total += n
 	key := new(Key)
-	n, err := key.readFrom(rd, int64(keySize))
+	n, err = key.readFrom(rd, int64(keySize))
 	if err != nil {
 		return total, err
 	}
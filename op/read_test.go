@@ -24,12 +24,12 @@ func TestReadRequest(t *testing.T) {
 
 	readReq2 := new(op.ReadRequest)
 
-	opcode, bodyLen, err := op.ReadEnvelope(stream)
+	env, err := op.ReadEnvelope(stream)
 	if err != nil {
 		t.Fatalf("Failed to read envelope : %s\n", err.Error())
 	}
 
-	if opcode != op.READ {
+	if env.Op != op.READ {
 		t.Fatalf("Expected READ opcode\n")
 	}
 
@@ -37,7 +37,7 @@ func TestReadRequest(t *testing.T) {
 		t.Fatalf("Failed to read : %s\n", err.Error())
 	}
 
-	if int(bodyLen) != readReq2.Len()+1 {
+	if int(env.BodyLen) != readReq2.Len()+4 {
 		t.Fatalf("Bad length denotation in envelope\n")
 	}
 
@@ -45,3 +45,45 @@ func TestReadRequest(t *testing.T) {
 		t.Fatalf("Bad write to/ read from stream\n")
 	}
 }
+
+func TestTaggedReadRequest(t *testing.T) {
+	for _, legacy := range []bool{true, false} {
+		key := op.Key("hello")
+		req1 := op.ReadRequest{Header: op.Header{Legacy: legacy, Tagged: true, ID: 1 << 20}, Key: &key}
+		stream := new(bytes.Buffer)
+
+		if _, err := req1.WriteEnvelope(stream); err != nil {
+			t.Fatalf("Failed to write envelope : %s\n", err.Error())
+		}
+
+		if _, err := req1.WriteTo(stream); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+
+		env, err := op.ReadEnvelope(stream)
+		if err != nil {
+			t.Fatalf("Failed to read envelope : %s\n", err.Error())
+		}
+
+		if env.Op != op.READ {
+			t.Fatalf("Expected READ opcode\n")
+		}
+
+		if env.Header != req1.Header {
+			t.Fatalf("Expected header %+v, received %+v\n", req1.Header, env.Header)
+		}
+
+		req2 := op.ReadRequest{Header: env.Header}
+		if int(env.BodyLen) != stream.Len() {
+			t.Fatalf("Bad length denotation in envelope\n")
+		}
+
+		if _, err := req2.ReadFrom(stream); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if string(*req1.Key) != string(*req2.Key) {
+			t.Fatalf("Bad write to/ read from stream\n")
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package op
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Response is implemented by every frame, server writes back for
+// some request
+type Response interface {
+	io.WriterTo
+	writeFrame(w io.Writer, hdr Header) (int64, error)
+}
+
+// WriteResponse writes response using framing of the request, it's
+// being sent for. If response doesn't fit in that framing, error frame
+// is written instead, so that client never receives a truncated frame.
+func WriteResponse(w io.Writer, hdr Header, resp Response) (int64, error) {
+	buf := new(bytes.Buffer)
+
+	if _, err := resp.writeFrame(buf, hdr); err != nil {
+		if !errors.Is(err, ErrTooLarge) {
+			return 0, err
+		}
+
+		buf.Reset()
+		e := Error{Code: TooLarge, Message: "response doesn't fit in legacy framing"}
+		if _, err := e.writeFrame(buf, hdr); err != nil {
+			return 0, err
+		}
+	}
+
+	return buf.WriteTo(w)
+}
+
+func writeResponse(w io.Writer, hdr Header, status Status, val []byte) (int64, error) {
+	var total int64
+
+	n, err := hdr.writeOpcode(w, RESPONSE)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	if err := binary.Write(w, binary.BigEndian, status); err != nil {
+		return total, err
+	}
+
+	total += 1
+	n, err = hdr.writeLen(w, len(val))
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	m, err := w.Write(val)
+	if err != nil {
+		return total, err
+	}
+
+	total += int64(m)
+	return total, nil
+}
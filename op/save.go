@@ -0,0 +1,32 @@
+package op
+
+import (
+	"io"
+)
+
+// SaveRequest asks server to write snapshot of whole store. With
+// `Background` set, it's sent as BGSAVE & responded to as soon as
+// snapshot is started, otherwise as SAVE, responded to once snapshot is
+// written.
+//
+// It has empty body & is responded to with `StatusOK`.
+type SaveRequest struct {
+	Header
+	Background bool
+}
+
+func (s *SaveRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	if s.Background {
+		return writeEnvelope(w, s.Header, BGSAVE, 0)
+	}
+
+	return writeEnvelope(w, s.Header, SAVE, 0)
+}
+
+func (s *SaveRequest) WriteTo(w io.Writer) (int64, error) {
+	return 0, nil
+}
+
+func (s *SaveRequest) ReadFrom(r io.Reader) (int64, error) {
+	return 0, nil
+}
@@ -0,0 +1,36 @@
+package op_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+func TestSaveRequest(t *testing.T) {
+	for opcode, background := range map[op.OP]bool{op.SAVE: false, op.BGSAVE: true} {
+		req := op.SaveRequest{Background: background}
+		stream := new(bytes.Buffer)
+
+		if _, err := req.WriteEnvelope(stream); err != nil {
+			t.Fatalf("Failed to write envelope : %s\n", err.Error())
+		}
+
+		if _, err := req.WriteTo(stream); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+
+		env, err := op.ReadEnvelope(stream)
+		if err != nil {
+			t.Fatalf("Failed to read envelope : %s\n", err.Error())
+		}
+
+		if env.Op != opcode || env.BodyLen != 0 || stream.Len() != 0 {
+			t.Fatalf("Expected empty %d request, received %+v\n", opcode, env)
+		}
+
+		if err := op.ReadBody(new(op.SaveRequest), []byte{1}); err == nil {
+			t.Fatalf("Expected non-empty body to be rejected\n")
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package op
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	// DefaultScanLimit is number of keys returned by SCAN request, which
+	// doesn't set limit
+	DefaultScanLimit = 100
+	// MaxScanLimit is most keys returned by one SCAN request, larger
+	// limits are lowered to it
+	MaxScanLimit = 10000
+)
+
+// ScanRequest lists keys in ascending order. Keys must have `Prefix` &
+// fall within [`Start`, `End`), where empty `End` denotes no upper bound.
+// Scan continues from `Cursor`, when it's set to one returned by
+// previous response.
+//
+// Body holds length prefixed prefix, start, end & cursor, followed by
+// limit as uint32, where zero denotes `DefaultScanLimit`.
+type ScanRequest struct {
+	Header
+	Prefix Key
+	Start  Key
+	End    Key
+	Cursor Key
+	Limit  uint32
+}
+
+func (s *ScanRequest) Len() int {
+	return s.Prefix.len() + s.Start.len() + s.End.len() + s.Cursor.len()
+}
+
+func (s *ScanRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	return writeEnvelope(w, s.Header, SCAN, 4*s.lenSize()+s.Len()+4)
+}
+
+func (s *ScanRequest) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+
+	for _, key := range []*Key{&s.Prefix, &s.Start, &s.End, &s.Cursor} {
+		n, err := s.writeLen(w, key.len())
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		n, err = key.writeTo(w)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+	}
+
+	if err := binary.Write(w, binary.BigEndian, s.Limit); err != nil {
+		return total, err
+	}
+
+	total += 4
+	return total, nil
+}
+
+func (s *ScanRequest) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+
+	for _, key := range []*Key{&s.Prefix, &s.Start, &s.End, &s.Cursor} {
+		keySize, n, err := s.readLen(r)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		n, err = key.readFrom(r, int64(keySize))
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+	}
+
+	if err := binary.Read(r, binary.BigEndian, &s.Limit); err != nil {
+		return total, err
+	}
+
+	total += 4
+	return total, nil
+}
+
+// Range returns bounds of keys to be listed, combining prefix, start,
+// end & cursor, where empty end denotes no upper bound
+func (s *ScanRequest) Range() (Key, Key) {
+	start, end := s.Start, s.End
+	if s.Prefix > start {
+		start = s.Prefix
+	}
+
+	if s.Cursor > start {
+		start = s.Cursor
+	}
+
+	if after, ok := prefixEnd(s.Prefix); ok && (end == "" || after < end) {
+		end = after
+	}
+
+	return start, end
+}
+
+// Count returns number of keys to be listed
+func (s *ScanRequest) Count() int {
+	if s.Limit == 0 {
+		return DefaultScanLimit
+	}
+
+	if s.Limit > MaxScanLimit {
+		return MaxScanLimit
+	}
+
+	return int(s.Limit)
+}
+
+// prefixEnd returns smallest key, which is larger than all keys having
+// prefix, with false when there's no such key
+func prefixEnd(prefix Key) (Key, bool) {
+	b := []byte(prefix)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 0xff {
+			b[i]++
+			return Key(b[:i+1]), true
+		}
+	}
+
+	return "", false
+}
+
+// scanChunkSize is most bytes of keys carried by one frame of wide
+// SCAN response, legacy frames carry at most 255 bytes
+const scanChunkSize = 1 << 16
+
+// ScanResponse is sent back for a SCAN request.
+//
+// Keys are streamed in zero or more RESPONSE frames with
+// `StatusPartial`, each holding length prefixed keys. They're followed
+// by a RESPONSE frame with `StatusOK`, whose value is cursor to continue
+// scan from, empty when scan is complete.
+type ScanResponse struct {
+	Keys   []Key
+	Cursor Key
+}
+
+func (s *ScanResponse) WriteTo(w io.Writer) (int64, error) {
+	return s.writeFrame(w, Header{})
+}
+
+func (s *ScanResponse) writeFrame(w io.Writer, hdr Header) (int64, error) {
+	var total int64
+
+	limit := scanChunkSize
+	if hdr.Legacy {
+		limit = 255
+	}
+
+	chunk := make([]byte, 0, limit)
+	flush := func() error {
+		n, err := writeResponse(w, hdr, StatusPartial, chunk)
+		total += n
+		chunk = chunk[:0]
+		return err
+	}
+
+	for _, key := range s.Keys {
+		size := hdr.lenSize() + key.len()
+		if len(chunk) != 0 && len(chunk)+size > limit {
+			if err := flush(); err != nil {
+				return total, err
+			}
+		}
+
+		if hdr.Legacy && size > limit {
+			return total, ErrTooLarge
+		}
+
+		if hdr.Legacy {
+			chunk = append(chunk, byte(key.len()))
+		} else {
+			chunk = append(chunk, 0, 0, 0, 0)
+			binary.BigEndian.PutUint32(chunk[len(chunk)-4:], uint32(key.len()))
+		}
+
+		chunk = append(chunk, key...)
+	}
+
+	if len(chunk) != 0 {
+		if err := flush(); err != nil {
+			return total, err
+		}
+	}
+
+	n, err := writeResponse(w, hdr, StatusOK, []byte(s.Cursor))
+	total += n
+	return total, err
+}
+
+func (s *ScanResponse) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+	keys := make([]Key, 0)
+
+	for {
+		val := new(Value)
+		hdr, status, n, err := val.readFrame(r)
+		total += n
+		if err != nil {
+			return total, err
+		}
+
+		switch status {
+		case StatusPartial:
+			for b := []byte(*val); len(b) != 0; {
+				if len(b) < hdr.lenSize() {
+					return total, errors.New("truncated key")
+				}
+
+				size := int(b[0])
+				if !hdr.Legacy {
+					size = int(binary.BigEndian.Uint32(b))
+				}
+
+				b = b[hdr.lenSize():]
+				if len(b) < size {
+					return total, errors.New("truncated key")
+				}
+
+				keys = append(keys, Key(b[:size]))
+				b = b[size:]
+			}
+
+		case StatusOK:
+			s.Keys = keys
+			s.Cursor = Key(*val)
+			return total, nil
+
+		default:
+			return total, errors.New("bad status")
+
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package op_test
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+func TestScanRequest(t *testing.T) {
+	for _, legacy := range []bool{true, false} {
+		req1 := op.ScanRequest{Header: op.Header{Legacy: legacy}, Prefix: "user:", Start: "a", End: "z", Cursor: "user:2", Limit: 10}
+		stream := new(bytes.Buffer)
+
+		if _, err := req1.WriteEnvelope(stream); err != nil {
+			t.Fatalf("Failed to write envelope : %s\n", err.Error())
+		}
+
+		if _, err := req1.WriteTo(stream); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+
+		env, err := op.ReadEnvelope(stream)
+		if err != nil {
+			t.Fatalf("Failed to read envelope : %s\n", err.Error())
+		}
+
+		if env.Op != op.SCAN || int(env.BodyLen) != stream.Len() {
+			t.Fatalf("Bad length denotation in envelope\n")
+		}
+
+		req2 := &op.ScanRequest{Header: env.Header}
+		if err := op.ReadBody(req2, stream.Bytes()); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if *req2 != req1 {
+			t.Fatalf("Expected %+v, received %+v\n", req1, *req2)
+		}
+	}
+}
+
+func TestScanRequestRange(t *testing.T) {
+	cases := []struct {
+		req        op.ScanRequest
+		start, end op.Key
+	}{
+		{req: op.ScanRequest{}, start: "", end: ""},
+		{req: op.ScanRequest{Prefix: "user:1"}, start: "user:1", end: "user:2"},
+		{req: op.ScanRequest{Prefix: "a\xff\xff"}, start: "a\xff\xff", end: "b"},
+		{req: op.ScanRequest{Prefix: "\xff"}, start: "\xff", end: ""},
+		{req: op.ScanRequest{Start: "b", End: "d"}, start: "b", end: "d"},
+		{req: op.ScanRequest{Prefix: "b", Start: "a", End: "bb"}, start: "b", end: "bb"},
+		{req: op.ScanRequest{Prefix: "b", Start: "b5", End: "d"}, start: "b5", end: "c"},
+		{req: op.ScanRequest{Start: "b", End: "d", Cursor: "c"}, start: "c", end: "d"},
+	}
+
+	for _, c := range cases {
+		if start, end := c.req.Range(); start != c.start || end != c.end {
+			t.Fatalf("Expected [%q, %q) for %+v, received [%q, %q)\n", c.start, c.end, c.req, start, end)
+		}
+	}
+
+	for limit, count := range map[uint32]int{0: op.DefaultScanLimit, 7: 7, op.MaxScanLimit + 1: op.MaxScanLimit} {
+		req := op.ScanRequest{Limit: limit}
+		if req.Count() != count {
+			t.Fatalf("Expected limit %d to list %d keys, received %d\n", limit, count, req.Count())
+		}
+	}
+}
+
+func TestScanResponse(t *testing.T) {
+	many := make([]op.Key, 0)
+	for i := 0; i < 6000; i++ {
+		many = append(many, op.Key(fmt.Sprintf("key-%05d", i)))
+	}
+
+	cases := []struct {
+		hdr    op.Header
+		resp   op.ScanResponse
+		frames int
+	}{
+		{hdr: op.Header{}, resp: op.ScanResponse{Keys: []op.Key{}}, frames: 1},
+		{hdr: op.Header{}, resp: op.ScanResponse{Keys: []op.Key{"a", ""}, Cursor: "b"}, frames: 2},
+		// every key takes 4 + 9 bytes, filling 2 frames of 64KiB
+		{hdr: op.Header{}, resp: op.ScanResponse{Keys: many}, frames: 3},
+		// every key takes 1 + 9 bytes, 25 keys fit in a frame
+		{hdr: op.Header{Legacy: true}, resp: op.ScanResponse{Keys: many[:100], Cursor: many[100]}, frames: 5},
+		{hdr: op.Header{Tagged: true, ID: 7}, resp: op.ScanResponse{Keys: many[:10]}, frames: 2},
+	}
+
+	for i, c := range cases {
+		stream := new(bytes.Buffer)
+		if _, err := op.WriteResponse(stream, c.hdr, &c.resp); err != nil {
+			t.Fatalf("[%d] Failed to write : %s\n", i, err.Error())
+		}
+
+		// count frames, by reading them one by one
+		raw := bytes.NewReader(stream.Bytes())
+		frames := 0
+		for raw.Len() != 0 {
+			hdr, _, err := new(op.Value).ReadFrame(raw)
+			if err != nil && err.Error() != "bad status" {
+				t.Fatalf("[%d] Failed to read frame : %s\n", i, err.Error())
+			}
+
+			if hdr != c.hdr {
+				t.Fatalf("[%d] Expected header %+v, received %+v\n", i, c.hdr, hdr)
+			}
+
+			frames++
+		}
+
+		if frames != c.frames {
+			t.Fatalf("[%d] Expected %d frames, received %d\n", i, c.frames, frames)
+		}
+
+		resp := new(op.ScanResponse)
+		if _, err := resp.ReadFrom(stream); err != nil {
+			t.Fatalf("[%d] Failed to read : %s\n", i, err.Error())
+		}
+
+		if !reflect.DeepEqual(resp.Keys, c.resp.Keys) || resp.Cursor != c.resp.Cursor {
+			t.Fatalf("[%d] Expected %d keys & cursor %q, received %d keys & cursor %q\n", i, len(c.resp.Keys), c.resp.Cursor, len(resp.Keys), resp.Cursor)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package op
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// StatsRequest asks server for its counters. It has empty body.
+type StatsRequest struct {
+	Header
+}
+
+func (s *StatsRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	return writeEnvelope(w, s.Header, STATS, 0)
+}
+
+func (s *StatsRequest) WriteTo(w io.Writer) (int64, error) {
+	return 0, nil
+}
+
+func (s *StatsRequest) ReadFrom(r io.Reader) (int64, error) {
+	return 0, nil
+}
+
+// Counter is one named counter, reported by server
+type Counter struct {
+	Name  string
+	Value uint64
+}
+
+// StatsResponse is sent back for a STATS request.
+//
+// It's a RESPONSE frame, whose value is sequence of counters, each one
+// being uint8 length prefixed name, followed by uint64 value.
+type StatsResponse struct {
+	Counters []Counter
+}
+
+// Get returns value of named counter, with false when it's not reported
+func (s *StatsResponse) Get(name string) (uint64, bool) {
+	for _, c := range s.Counters {
+		if c.Name == name {
+			return c.Value, true
+		}
+	}
+
+	return 0, false
+}
+
+func (s *StatsResponse) WriteTo(w io.Writer) (int64, error) {
+	return s.writeFrame(w, Header{})
+}
+
+func (s *StatsResponse) writeFrame(w io.Writer, hdr Header) (int64, error) {
+	val := make([]byte, 0, len(s.Counters)*16)
+	for _, c := range s.Counters {
+		name := c.Name
+		if len(name) > 255 {
+			name = name[:255]
+		}
+
+		val = append(val, byte(len(name)))
+		val = append(val, name...)
+		val = append(val, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(val[len(val)-8:], c.Value)
+	}
+
+	return writeResponse(w, hdr, StatusOK, val)
+}
+
+func (s *StatsResponse) ReadFrom(r io.Reader) (int64, error) {
+	val := new(Value)
+	n, err := val.ReadFrom(r)
+	if err != nil {
+		return n, err
+	}
+
+	counters := make([]Counter, 0)
+	for b := []byte(*val); len(b) != 0; {
+		size := int(b[0])
+		if len(b) < 1+size+8 {
+			return n, errors.New("truncated counter")
+		}
+
+		counters = append(counters, Counter{Name: string(b[1 : 1+size]), Value: binary.BigEndian.Uint64(b[1+size:])})
+		b = b[1+size+8:]
+	}
+
+	s.Counters = counters
+	return n, nil
+}
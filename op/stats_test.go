@@ -0,0 +1,59 @@
+package op_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+func TestStatsRequest(t *testing.T) {
+	req := op.StatsRequest{}
+	stream := new(bytes.Buffer)
+
+	if _, err := req.WriteEnvelope(stream); err != nil {
+		t.Fatalf("Failed to write envelope : %s\n", err.Error())
+	}
+
+	env, err := op.ReadEnvelope(stream)
+	if err != nil {
+		t.Fatalf("Failed to read envelope : %s\n", err.Error())
+	}
+
+	if env.Op != op.STATS || env.BodyLen != 0 {
+		t.Fatalf("Expected empty STATS request, received %+v\n", env)
+	}
+}
+
+func TestStatsResponse(t *testing.T) {
+	for _, counters := range [][]op.Counter{
+		{},
+		{{Name: "keys", Value: 10}, {Name: "evicted_keys", Value: 1 << 40}, {Name: "", Value: 0}},
+	} {
+		resp1 := op.StatsResponse{Counters: counters}
+		stream := new(bytes.Buffer)
+
+		if _, err := resp1.WriteTo(stream); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+
+		resp2 := new(op.StatsResponse)
+		if _, err := resp2.ReadFrom(stream); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if !reflect.DeepEqual(resp1.Counters, resp2.Counters) {
+			t.Fatalf("Expected %+v, received %+v\n", resp1.Counters, resp2.Counters)
+		}
+	}
+
+	resp := op.StatsResponse{Counters: []op.Counter{{Name: "keys", Value: 3}}}
+	if v, ok := resp.Get("keys"); !ok || v != 3 {
+		t.Fatalf("Expected `keys` = 3, received %d\n", v)
+	}
+
+	if _, ok := resp.Get("missing"); ok {
+		t.Fatalf("Expected `missing` not to be found\n")
+	}
+}
@@ -0,0 +1,30 @@
+package op
+
+import (
+	"errors"
+	"io"
+)
+
+// Status is carried in every RESPONSE frame, right after opcode, so that
+// a missing key can be told apart from a key holding empty value
+type Status uint8
+
+const (
+	StatusOK         Status = iota + 1 // request served, value follows
+	StatusNotFound                     // requested key isn't present
+	StatusPartial                      // part of response, more frames follow
+	StatusNotApplied                   // condition of write didn't hold
+)
+
+// ErrNotFound is returned when reading a response with
+// `StatusNotFound` status
+var ErrNotFound = errors.New("key not found")
+
+// WriteTo writes a RESPONSE frame with this status & empty value
+func (s Status) WriteTo(w io.Writer) (int64, error) {
+	return s.writeFrame(w, Header{})
+}
+
+func (s Status) writeFrame(w io.Writer, hdr Header) (int64, error) {
+	return writeResponse(w, hdr, s, nil)
+}
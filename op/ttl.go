@@ -0,0 +1,145 @@
+package op
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// NoExpiry is time to live reported for key, which never expires
+const NoExpiry time.Duration = -1
+
+// writeTTL writes time to live as uint64 milliseconds. Fraction of
+// millisecond is rounded up, so that a non-zero TTL never becomes zero.
+func writeTTL(w io.Writer, ttl time.Duration) (int64, error) {
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	ms := uint64((ttl + time.Millisecond - 1) / time.Millisecond)
+	if err := binary.Write(w, binary.BigEndian, ms); err != nil {
+		return 0, err
+	}
+
+	return 8, nil
+}
+
+func readTTL(r io.Reader) (time.Duration, int64, error) {
+	var ms uint64
+	if err := binary.Read(r, binary.BigEndian, &ms); err != nil {
+		return 0, 0, err
+	}
+
+	if ms > uint64(1<<63-1)/uint64(time.Millisecond) {
+		return 0, 8, errors.New("ttl out of range")
+	}
+
+	return time.Duration(ms) * time.Millisecond, 8, nil
+}
+
+// TTLRequest asks for time left till key expires
+type TTLRequest struct {
+	Header
+	Key *Key
+}
+
+func (t *TTLRequest) Len() int {
+	return t.Key.len()
+}
+
+func (t *TTLRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	return writeEnvelope(w, t.Header, TTL, t.lenSize()+t.Len())
+}
+
+func (t *TTLRequest) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+
+	n, err := t.writeLen(w, t.Key.len())
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	n, err = t.Key.writeTo(w)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	return total, nil
+}
+
+func (t *TTLRequest) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+
+	keySize, n, err := t.readLen(r)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	key := new(Key)
+	n, err = key.readFrom(r, int64(keySize))
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	t.Key = key
+	return total, nil
+}
+
+// TTLResponse is sent back for a TTL request.
+//
+// It's a RESPONSE frame, whose value is time to live in milliseconds,
+// as int64, where -1 denotes key without expiry. Missing key is denoted
+// using `StatusNotFound`.
+type TTLResponse struct {
+	Found bool
+	TTL   time.Duration // `NoExpiry` for key without expiry
+}
+
+func (t *TTLResponse) WriteTo(w io.Writer) (int64, error) {
+	return t.writeFrame(w, Header{})
+}
+
+func (t *TTLResponse) writeFrame(w io.Writer, hdr Header) (int64, error) {
+	if !t.Found {
+		return StatusNotFound.writeFrame(w, hdr)
+	}
+
+	ms := int64(-1)
+	if t.TTL != NoExpiry {
+		ms = int64((t.TTL + time.Millisecond - 1) / time.Millisecond)
+	}
+
+	val := make([]byte, 8)
+	binary.BigEndian.PutUint64(val, uint64(ms))
+	return writeResponse(w, hdr, StatusOK, val)
+}
+
+func (t *TTLResponse) ReadFrom(r io.Reader) (int64, error) {
+	val := new(Value)
+	n, err := val.ReadFrom(r)
+	if errors.Is(err, ErrNotFound) {
+		t.Found = false
+		return n, nil
+	}
+
+	if err != nil {
+		return n, err
+	}
+
+	if val.Len() != 8 {
+		return n, errors.New("bad ttl length")
+	}
+
+	t.Found = true
+	t.TTL = NoExpiry
+	if ms := int64(binary.BigEndian.Uint64(*val)); ms >= 0 {
+		t.TTL = time.Duration(ms) * time.Millisecond
+	}
+
+	return n, nil
+}
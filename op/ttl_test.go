@@ -0,0 +1,71 @@
+package op_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+func TestTTLRequest(t *testing.T) {
+	key := op.Key("hello")
+	req1 := op.TTLRequest{Key: &key}
+	stream := new(bytes.Buffer)
+
+	if _, err := req1.WriteEnvelope(stream); err != nil {
+		t.Fatalf("Failed to write envelope : %s\n", err.Error())
+	}
+
+	if _, err := req1.WriteTo(stream); err != nil {
+		t.Fatalf("Failed to write : %s\n", err.Error())
+	}
+
+	req2 := new(op.TTLRequest)
+
+	env, err := op.ReadEnvelope(stream)
+	if err != nil {
+		t.Fatalf("Failed to read envelope : %s\n", err.Error())
+	}
+
+	if env.Op != op.TTL {
+		t.Fatalf("Expected TTL opcode\n")
+	}
+
+	if _, err := req2.ReadFrom(stream); err != nil {
+		t.Fatalf("Failed to read : %s\n", err.Error())
+	}
+
+	if int(env.BodyLen) != req2.Len()+4 {
+		t.Fatalf("Bad length denotation in envelope\n")
+	}
+
+	if *req1.Key != *req2.Key {
+		t.Fatalf("Bad write to/ read from stream\n")
+	}
+}
+
+func TestTTLResponse(t *testing.T) {
+	responses := []op.TTLResponse{
+		{Found: true, TTL: 3 * time.Second},
+		{Found: true, TTL: op.NoExpiry},
+		{Found: false},
+	}
+
+	for _, resp1 := range responses {
+		stream := new(bytes.Buffer)
+
+		if _, err := resp1.WriteTo(stream); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+
+		resp2 := new(op.TTLResponse)
+		if _, err := resp2.ReadFrom(stream); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if resp1 != *resp2 {
+			t.Fatalf("Expected %+v, received %+v\n", resp1, *resp2)
+		}
+	}
+}
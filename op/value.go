@@ -18,8 +18,8 @@ func (v *Value) writeTo(w io.Writer) (int64, error) {
 }
 
 func (v *Value) readFrom(r io.Reader, n int64) (int64, error) {
-	buf := make([]byte, n)
-	if _, err := r.Read(buf); err != nil {
+	buf, err := readBytes(r, n)
+	if err != nil {
 		return 0, err
 	}
 
@@ -28,51 +28,83 @@ func (v *Value) readFrom(r io.Reader, n int64) (int64, error) {
 }
 
 func (v *Value) WriteTo(w io.Writer) (int64, error) {
-	var total int64
+	return v.writeFrame(w, Header{})
+}
 
-	op := RESPONSE
-	if _, err := op.WriteTo(w); err != nil {
-		return total, err
-	}
+func (v *Value) writeFrame(w io.Writer, hdr Header) (int64, error) {
+	return writeResponse(w, hdr, StatusOK, *v)
+}
 
-	total += 1
-	if err := binary.Write(w, binary.BigEndian, uint8(v.Len())); err != nil {
-		return total, err
-	}
+// ReadFrom reads RESPONSE frame into value. Error frame is returned as
+// `*Error`, while missing key is denoted by `ErrNotFound`.
+func (v *Value) ReadFrom(r io.Reader) (int64, error) {
+	_, n, err := v.ReadFrame(r)
+	return n, err
+}
 
-	total += 1
-	n, err := v.writeTo(w)
+// ReadFrame works like `ReadFrom`, while also returning header of read
+// frame, so that tagged response can be matched with its request using
+// request ID
+func (v *Value) ReadFrame(r io.Reader) (Header, int64, error) {
+	hdr, status, n, err := v.readFrame(r)
 	if err != nil {
-		return total, err
+		return hdr, n, err
 	}
 
-	total += n
-	return total, nil
+	switch status {
+	case StatusOK:
+		return hdr, n, nil
+
+	case StatusNotFound:
+		return hdr, n, ErrNotFound
+
+	default:
+		return hdr, n, errors.New("bad status")
+
+	}
 }
 
-func (v *Value) ReadFrom(r io.Reader) (int64, error) {
+// readFrame reads RESPONSE frame into value, returning its status
+func (v *Value) readFrame(r io.Reader) (Header, Status, int64, error) {
 	var total int64
 
-	op := new(OP)
-	if _, err := op.ReadFrom(r); err != nil {
-		return total, err
+	opcode, hdr, n, err := readOpcode(r)
+	if err != nil {
+		return hdr, 0, total, err
 	}
 
-	total += 1
-	if *op != RESPONSE {
-		return total, errors.New("bad opcode")
+	total += n
+	if opcode == ERROR {
+		e := new(Error)
+		n, err := e.readFrom(r, hdr)
+		if err != nil {
+			return hdr, 0, total, err
+		}
+
+		total += n
+		return hdr, 0, total, e
 	}
 
-	var valLen uint8
-	if err := binary.Read(r, binary.BigEndian, &valLen); err != nil {
-		return total, err
+	if opcode != RESPONSE {
+		return hdr, 0, total, errors.New("bad opcode")
+	}
+
+	var status Status
+	if err := binary.Read(r, binary.BigEndian, &status); err != nil {
+		return hdr, 0, total, err
 	}
 
 	total += 1
+	valLen, n, err := hdr.readLen(r)
+	if err != nil {
+		return hdr, 0, total, err
+	}
+
+	total += n
 	if _, err := v.readFrom(r, int64(valLen)); err != nil {
-		return total, err
+		return hdr, 0, total, err
 	}
 
 	total += int64(valLen)
-	return total, nil
+	return hdr, status, total, nil
 }
@@ -0,0 +1,84 @@
+package op_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+func TestValue(t *testing.T) {
+	for _, v := range []string{"world", ""} {
+		val1 := op.Value(v)
+		stream := new(bytes.Buffer)
+
+		if _, err := val1.WriteTo(stream); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+
+		val2 := new(op.Value)
+		if _, err := val2.ReadFrom(stream); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if !bytes.Equal(val1, *val2) {
+			t.Fatalf("Expected `%s`, received `%s`\n", val1, *val2)
+		}
+	}
+}
+
+func TestValueNotFound(t *testing.T) {
+	stream := new(bytes.Buffer)
+
+	if _, err := op.StatusNotFound.WriteTo(stream); err != nil {
+		t.Fatalf("Failed to write : %s\n", err.Error())
+	}
+
+	val := new(op.Value)
+	if _, err := val.ReadFrom(stream); !errors.Is(err, op.ErrNotFound) {
+		t.Fatalf("Expected not found error, received %v\n", err)
+	}
+
+	if stream.Len() != 0 {
+		t.Fatalf("Expected whole frame to be consumed\n")
+	}
+}
+
+func TestTaggedResponse(t *testing.T) {
+	val := op.Value("world")
+	responses := []struct {
+		resp op.Response
+		err  error
+	}{
+		{resp: &val},
+		{resp: op.StatusNotFound, err: op.ErrNotFound},
+		{resp: &op.Error{Code: op.Malformed, Message: "bad key"}, err: &op.Error{Code: op.Malformed, Message: "bad key"}},
+	}
+
+	for _, legacy := range []bool{true, false} {
+		for i, r := range responses {
+			hdr := op.Header{Legacy: legacy, Tagged: true, ID: uint32(i + 1)}
+			stream := new(bytes.Buffer)
+
+			if _, err := op.WriteResponse(stream, hdr, r.resp); err != nil {
+				t.Fatalf("Failed to write : %s\n", err.Error())
+			}
+
+			read := new(op.Value)
+			readHdr, _, err := read.ReadFrame(stream)
+			if readHdr != hdr {
+				t.Fatalf("Expected header %+v, received %+v\n", hdr, readHdr)
+			}
+
+			if fmt.Sprint(err) != fmt.Sprint(r.err) {
+				t.Fatalf("Expected error %v, received %v\n", r.err, err)
+			}
+
+			if stream.Len() != 0 {
+				t.Fatalf("Expected whole frame to be consumed\n")
+			}
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package op
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// ReadVersionRequest reads value of key, along with its version. It's
+// encoded like READ request, but sent as GETV.
+type ReadVersionRequest struct {
+	ReadRequest
+}
+
+func (r *ReadVersionRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	return writeEnvelope(w, r.Header, GETV, r.lenSize()+r.Len())
+}
+
+// VersionedValue is sent back for a GETV request.
+//
+// It's a RESPONSE frame, whose value is version as uint64, followed by
+// value of key. Missing key is denoted using `StatusNotFound`.
+type VersionedValue struct {
+	Found   bool
+	Value   Value
+	Version uint64
+}
+
+func (v *VersionedValue) WriteTo(w io.Writer) (int64, error) {
+	return v.writeFrame(w, Header{})
+}
+
+func (v *VersionedValue) writeFrame(w io.Writer, hdr Header) (int64, error) {
+	if !v.Found {
+		return StatusNotFound.writeFrame(w, hdr)
+	}
+
+	val := make([]byte, 8, 8+len(v.Value))
+	binary.BigEndian.PutUint64(val, v.Version)
+	return writeResponse(w, hdr, StatusOK, append(val, v.Value...))
+}
+
+func (v *VersionedValue) ReadFrom(r io.Reader) (int64, error) {
+	val := new(Value)
+	n, err := val.ReadFrom(r)
+	if errors.Is(err, ErrNotFound) {
+		*v = VersionedValue{}
+		return n, nil
+	}
+
+	if err != nil {
+		return n, err
+	}
+
+	if val.Len() < 8 {
+		return n, errors.New("bad version length")
+	}
+
+	v.Found = true
+	v.Version = binary.BigEndian.Uint64(*val)
+	v.Value = (*val)[8:]
+	return n, nil
+}
+
+// CondWriteRequest writes value only when key is absent, sent as SETNX,
+// or only when it's present, sent as SETXX, when `IfExists` is set.
+// It's encoded like WRITE request, optionally carrying TTL.
+type CondWriteRequest struct {
+	WriteRequest
+	IfExists bool
+}
+
+func (c *CondWriteRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	if c.IfExists {
+		return writeEnvelope(w, c.Header, SETXX, c.bodyLen())
+	}
+
+	return writeEnvelope(w, c.Header, SETNX, c.bodyLen())
+}
+
+// CAS matching modes
+const (
+	casVersion uint8 = iota + 1
+	casValue
+)
+
+// CASRequest writes value only when current version of key equals
+// `Version` or, when `Expected` is set, current value equals it. Missing
+// key never matches.
+//
+// Body holds length prefixed key & value, followed by mode byte & either
+// uint64 version or length prefixed expected value.
+type CASRequest struct {
+	Header
+	Key      *Key
+	Value    *Value
+	Version  uint64
+	Expected *Value
+}
+
+func (c *CASRequest) Len() int {
+	total := c.Key.len() + c.Value.Len()
+	if c.Expected != nil {
+		return total + c.Expected.Len()
+	}
+
+	return total
+}
+
+func (c *CASRequest) WriteEnvelope(w io.Writer) (int64, error) {
+	bodyLen := 2*c.lenSize() + c.Len() + 1
+	if c.Expected != nil {
+		bodyLen += c.lenSize()
+	} else {
+		bodyLen += 8
+	}
+
+	return writeEnvelope(w, c.Header, CAS, bodyLen)
+}
+
+func (c *CASRequest) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+
+	n, err := c.writeLen(w, c.Key.len())
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	n, err = c.Key.writeTo(w)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	n, err = c.writeLen(w, c.Value.Len())
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	n, err = c.Value.writeTo(w)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	if c.Expected == nil {
+		if err := binary.Write(w, binary.BigEndian, casVersion); err != nil {
+			return total, err
+		}
+
+		total += 1
+		if err := binary.Write(w, binary.BigEndian, c.Version); err != nil {
+			return total, err
+		}
+
+		total += 8
+		return total, nil
+	}
+
+	if err := binary.Write(w, binary.BigEndian, casValue); err != nil {
+		return total, err
+	}
+
+	total += 1
+	n, err = c.writeLen(w, c.Expected.Len())
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	n, err = c.Expected.writeTo(w)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	return total, nil
+}
+
+func (c *CASRequest) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+
+	keyLength, n, err := c.readLen(r)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	key := new(Key)
+	n, err = key.readFrom(r, int64(keyLength))
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	valLength, n, err := c.readLen(r)
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	val := new(Value)
+	n, err = val.readFrom(r, int64(valLength))
+	if err != nil {
+		return total, err
+	}
+
+	total += n
+	var mode uint8
+	if err := binary.Read(r, binary.BigEndian, &mode); err != nil {
+		return total, err
+	}
+
+	total += 1
+	switch mode {
+	case casVersion:
+		if err := binary.Read(r, binary.BigEndian, &c.Version); err != nil {
+			return total, err
+		}
+
+		total += 8
+		c.Expected = nil
+
+	case casValue:
+		expLength, n, err := c.readLen(r)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		expected := new(Value)
+		n, err = expected.readFrom(r, int64(expLength))
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		c.Expected = expected
+
+	default:
+		return total, errors.New("bad cas mode")
+
+	}
+
+	c.Key = key
+	c.Value = val
+	return total, nil
+}
+
+// WriteResult is sent back for conditional writes, i.e. CAS, SETNX &
+// SETXX.
+//
+// It's a RESPONSE frame, with `StatusOK` when value got written or
+// `StatusNotApplied` otherwise. Its value is version of key as uint64,
+// after write when applied, else current one, zero for missing key.
+type WriteResult struct {
+	Applied bool
+	Version uint64
+}
+
+func (w *WriteResult) WriteTo(wr io.Writer) (int64, error) {
+	return w.writeFrame(wr, Header{})
+}
+
+func (w *WriteResult) writeFrame(wr io.Writer, hdr Header) (int64, error) {
+	val := make([]byte, 8)
+	binary.BigEndian.PutUint64(val, w.Version)
+	if w.Applied {
+		return writeResponse(wr, hdr, StatusOK, val)
+	}
+
+	return writeResponse(wr, hdr, StatusNotApplied, val)
+}
+
+func (w *WriteResult) ReadFrom(r io.Reader) (int64, error) {
+	val := new(Value)
+	_, status, n, err := val.readFrame(r)
+	if err != nil {
+		return n, err
+	}
+
+	if status != StatusOK && status != StatusNotApplied {
+		return n, errors.New("bad status")
+	}
+
+	if val.Len() != 8 {
+		return n, errors.New("bad version length")
+	}
+
+	w.Applied = status == StatusOK
+	w.Version = binary.BigEndian.Uint64(*val)
+	return n, nil
+}
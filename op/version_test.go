@@ -0,0 +1,143 @@
+package op_test
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+type request interface {
+	WriteEnvelope(io.Writer) (int64, error)
+	io.WriterTo
+}
+
+// encode writes request & returns envelope & body read back
+func encode(t *testing.T, req request) (op.Envelope, []byte) {
+	stream := new(bytes.Buffer)
+	if _, err := req.WriteEnvelope(stream); err != nil {
+		t.Fatalf("Failed to write envelope : %s\n", err.Error())
+	}
+
+	if _, err := req.WriteTo(stream); err != nil {
+		t.Fatalf("Failed to write : %s\n", err.Error())
+	}
+
+	env, err := op.ReadEnvelope(stream)
+	if err != nil {
+		t.Fatalf("Failed to read envelope : %s\n", err.Error())
+	}
+
+	if int(env.BodyLen) != stream.Len() {
+		t.Fatalf("Bad length denotation in envelope\n")
+	}
+
+	return env, stream.Bytes()
+}
+
+func TestReadVersionRequest(t *testing.T) {
+	key := op.Key("hello")
+	env, body := encode(t, &op.ReadVersionRequest{ReadRequest: op.ReadRequest{Key: &key}})
+	if env.Op != op.GETV {
+		t.Fatalf("Expected GETV, received %d\n", env.Op)
+	}
+
+	req := &op.ReadVersionRequest{ReadRequest: op.ReadRequest{Header: env.Header}}
+	if err := op.ReadBody(req, body); err != nil || *req.Key != key {
+		t.Fatalf("Expected key `%s`, received %+v [%v]\n", key, req, err)
+	}
+}
+
+func TestVersionedValue(t *testing.T) {
+	for _, resp1 := range []op.VersionedValue{
+		{Found: true, Value: op.Value("hello"), Version: 1 << 60},
+		{Found: true, Value: op.Value{}, Version: 1},
+		{Found: false},
+	} {
+		stream := new(bytes.Buffer)
+		if _, err := resp1.WriteTo(stream); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+
+		resp2 := new(op.VersionedValue)
+		if _, err := resp2.ReadFrom(stream); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if resp1.Found != resp2.Found || resp1.Version != resp2.Version || !bytes.Equal(resp1.Value, resp2.Value) {
+			t.Fatalf("Expected %+v, received %+v\n", resp1, *resp2)
+		}
+	}
+}
+
+func TestCondWriteRequest(t *testing.T) {
+	for opcode, ifExists := range map[op.OP]bool{op.SETNX: false, op.SETXX: true} {
+		key, val := op.Key("lock"), op.Value("owner")
+		req1 := op.CondWriteRequest{WriteRequest: op.WriteRequest{Key: &key, Value: &val, TTL: 3 * time.Second}, IfExists: ifExists}
+
+		env, body := encode(t, &req1)
+		if env.Op != opcode {
+			t.Fatalf("Expected opcode %d, received %d\n", opcode, env.Op)
+		}
+
+		req2 := &op.CondWriteRequest{WriteRequest: op.WriteRequest{Header: env.Header}}
+		if err := op.ReadBody(req2, body); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if *req2.Key != key || !bytes.Equal(*req2.Value, val) || req2.TTL != req1.TTL {
+			t.Fatalf("Expected %+v, received %+v\n", req1, *req2)
+		}
+	}
+}
+
+func TestCASRequest(t *testing.T) {
+	key, val, expected := op.Key("counter"), op.Value("2"), op.Value("1")
+
+	for _, hdr := range []op.Header{{}, {Legacy: true}} {
+		for _, req1 := range []op.CASRequest{
+			{Header: hdr, Key: &key, Value: &val, Version: 42},
+			{Header: hdr, Key: &key, Value: &val, Expected: &expected},
+		} {
+			env, body := encode(t, &req1)
+			if env.Op != op.CAS {
+				t.Fatalf("Expected CAS, received %d\n", env.Op)
+			}
+
+			req2 := &op.CASRequest{Header: env.Header}
+			if err := op.ReadBody(req2, body); err != nil {
+				t.Fatalf("Failed to read : %s\n", err.Error())
+			}
+
+			if !reflect.DeepEqual(req1, *req2) {
+				t.Fatalf("Expected %+v, received %+v\n", req1, *req2)
+			}
+		}
+	}
+
+	// unknown mode byte
+	if err := op.ReadBody(new(op.CASRequest), []byte{0, 0, 0, 1, 'a', 0, 0, 0, 0, 9}); err == nil {
+		t.Fatalf("Expected bad mode to be rejected\n")
+	}
+}
+
+func TestWriteResult(t *testing.T) {
+	for _, resp1 := range []op.WriteResult{{Applied: true, Version: 7}, {Applied: false, Version: 3}, {}} {
+		stream := new(bytes.Buffer)
+		if _, err := resp1.WriteTo(stream); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+
+		resp2 := new(op.WriteResult)
+		if _, err := resp2.ReadFrom(stream); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if resp1 != *resp2 {
+			t.Fatalf("Expected %+v, received %+v\n", resp1, *resp2)
+		}
+	}
+}
@@ -1,13 +1,19 @@
 package op
 
 import (
-	"encoding/binary"
+	"errors"
 	"io"
+	"time"
 )
 
+// WriteRequest stores value against key. When TTL is non-zero, it's
+// written after value & key expires after it, otherwise key is kept till
+// it's deleted.
 type WriteRequest struct {
+	Header
 	Key   *Key
 	Value *Value
+	TTL   time.Duration
 }
 
 func (w *WriteRequest) Len() int {
@@ -15,46 +21,54 @@ func (w *WriteRequest) Len() int {
 }
 
 func (w *WriteRequest) WriteEnvelope(wr io.Writer) (int64, error) {
-	var total int64
-
-	op := WRITE
-	if _, err := op.WriteTo(wr); err != nil {
-		return total, err
-	}
+	return writeEnvelope(wr, w.Header, WRITE, w.bodyLen())
+}
 
-	total += 1
-	if err := binary.Write(wr, binary.BigEndian, uint16(w.Len()+2)); err != nil {
-		return total, err
+func (w *WriteRequest) bodyLen() int {
+	bodyLen := 2*w.lenSize() + w.Len()
+	if w.TTL > 0 {
+		bodyLen += 8
 	}
 
-	total += 2
-	return total, nil
+	return bodyLen
 }
 
 func (w *WriteRequest) WriteTo(wr io.Writer) (int64, error) {
 	var total int64
 
-	if err := binary.Write(wr, binary.BigEndian, uint8(w.Key.len())); err != nil {
+	n, err := w.writeLen(wr, w.Key.len())
+	if err != nil {
 		return total, err
 	}
 
-	total += 1
-	n, err := w.Key.writeTo(wr)
+	total += n
+	n, err = w.Key.writeTo(wr)
 	if err != nil {
 		return total, err
 	}
 
 	total += n
-	if err := binary.Write(wr, binary.BigEndian, uint8(w.Value.Len())); err != nil {
+	n, err = w.writeLen(wr, w.Value.Len())
+	if err != nil {
 		return total, err
 	}
 
-	total += 1
+	total += n
 	n, err = w.Value.writeTo(wr)
 	if err != nil {
 		return total, err
 	}
 
+	total += n
+	if w.TTL <= 0 {
+		return total, nil
+	}
+
+	n, err = writeTTL(wr, w.TTL)
+	if err != nil {
+		return total, err
+	}
+
 	total += n
 	return total, nil
 }
@@ -62,33 +76,41 @@ func (w *WriteRequest) WriteTo(wr io.Writer) (int64, error) {
 func (w *WriteRequest) ReadFrom(r io.Reader) (int64, error) {
 	var total int64
 
-	var keyLength uint8
-	if err := binary.Read(r, binary.BigEndian, &keyLength); err != nil {
+	keyLength, n, err := w.readLen(r)
+	if err != nil {
 		return total, err
 	}
 
-	total += 1
+	total += n
 	key := new(Key)
-	n, err := key.readFrom(r, int64(keyLength))
+	n, err = key.readFrom(r, int64(keyLength))
 	if err != nil {
 		return total, err
 	}
 
 	total += n
-	var valLength uint8
-	if err := binary.Read(r, binary.BigEndian, &valLength); err != nil {
+	valLength, n, err := w.readLen(r)
+	if err != nil {
 		return total, err
 	}
 
-	total += 1
+	total += n
 	val := new(Value)
 	n, err = val.readFrom(r, int64(valLength))
 	if err != nil {
 		return total, err
 	}
 
+	total += n
+	// TTL is optional, so reaching end of body right after value is fine
+	ttl, n, err := readTTL(r)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return total, err
+	}
+
 	total += n
 	w.Key = key
 	w.Value = val
+	w.TTL = ttl
 	return total, nil
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/itzmeanjan/tseep/op"
 )
@@ -24,12 +25,12 @@ func TestWriteRequest(t *testing.T) {
 
 	writeRequest2 := new(op.WriteRequest)
 
-	opcode, bodyLen, err := op.ReadEnvelope(stream)
+	env, err := op.ReadEnvelope(stream)
 	if err != nil {
 		t.Fatalf("Failed to read envelope : %s\n", err.Error())
 	}
 
-	if opcode != op.WRITE {
+	if env.Op != op.WRITE {
 		t.Fatalf("Expected WRITE opcode\n")
 	}
 
@@ -37,7 +38,7 @@ func TestWriteRequest(t *testing.T) {
 		t.Fatalf("Failed to read from stream : %s\n", err.Error())
 	}
 
-	if int(bodyLen) != writeRequest2.Len()+2 {
+	if int(env.BodyLen) != writeRequest2.Len()+8 {
 		t.Fatalf("Bad length denoted in envelope\n")
 	}
 
@@ -49,3 +50,86 @@ func TestWriteRequest(t *testing.T) {
 		t.Fatalf("[Value] Bad read to/ write from stream\n")
 	}
 }
+
+func TestTaggedWriteRequest(t *testing.T) {
+	for _, legacy := range []bool{true, false} {
+		key := op.Key("hello")
+		val := op.Value("world")
+		req1 := op.WriteRequest{Header: op.Header{Legacy: legacy, Tagged: true, ID: 1 << 20}, Key: &key, Value: &val}
+		stream := new(bytes.Buffer)
+
+		if _, err := req1.WriteEnvelope(stream); err != nil {
+			t.Fatalf("Failed to write envelope : %s\n", err.Error())
+		}
+
+		if _, err := req1.WriteTo(stream); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+
+		env, err := op.ReadEnvelope(stream)
+		if err != nil {
+			t.Fatalf("Failed to read envelope : %s\n", err.Error())
+		}
+
+		if env.Op != op.WRITE {
+			t.Fatalf("Expected WRITE opcode\n")
+		}
+
+		if env.Header != req1.Header {
+			t.Fatalf("Expected header %+v, received %+v\n", req1.Header, env.Header)
+		}
+
+		req2 := op.WriteRequest{Header: env.Header}
+		if int(env.BodyLen) != stream.Len() {
+			t.Fatalf("Bad length denotation in envelope\n")
+		}
+
+		if _, err := req2.ReadFrom(stream); err != nil {
+			t.Fatalf("Failed to read : %s\n", err.Error())
+		}
+
+		if string(*req1.Key) != string(*req2.Key) || !bytes.Equal(*req1.Value, *req2.Value) {
+			t.Fatalf("Bad write to/ read from stream\n")
+		}
+	}
+}
+
+func TestWriteRequestTTL(t *testing.T) {
+	for _, ttl := range []time.Duration{0, time.Minute} {
+		key := op.Key("hello")
+		val := op.Value("world")
+		req1 := op.WriteRequest{Key: &key, Value: &val, TTL: ttl}
+		stream := new(bytes.Buffer)
+
+		if _, err := req1.WriteEnvelope(stream); err != nil {
+			t.Fatalf("Failed to write envelope : %s\n", err.Error())
+		}
+
+		if _, err := req1.WriteTo(stream); err != nil {
+			t.Fatalf("Failed to write to stream : %s\n", err.Error())
+		}
+
+		env, err := op.ReadEnvelope(stream)
+		if err != nil {
+			t.Fatalf("Failed to read envelope : %s\n", err.Error())
+		}
+
+		if int(env.BodyLen) != stream.Len() {
+			t.Fatalf("Bad length denoted in envelope\n")
+		}
+
+		req2 := op.WriteRequest{Header: env.Header}
+		if err := op.ReadBody(&req2, stream.Bytes()); err != nil {
+			t.Fatalf("Failed to read body : %s\n", err.Error())
+		}
+
+		if req2.TTL != ttl || !bytes.Equal(*req1.Value, *req2.Value) {
+			t.Fatalf("Expected TTL %s, received %s\n", ttl, req2.TTL)
+		}
+	}
+
+	// TTL must be either absent or complete
+	if err := op.ReadBody(&op.WriteRequest{}, []byte{0, 0, 0, 1, 'k', 0, 0, 0, 0, 0, 0, 1}); err == nil || err.Code != op.Malformed {
+		t.Fatalf("Expected malformed error, received %v\n", err)
+	}
+}
@@ -0,0 +1,600 @@
+package persist
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+// FsyncPolicy tells how often append-only file is flushed to disk
+type FsyncPolicy uint8
+
+const (
+	FsyncAlways   FsyncPolicy = iota + 1 // after every record
+	FsyncEverySec                        // once every second, in background
+	FsyncNever                           // whenever operating system decides
+)
+
+func (f FsyncPolicy) String() string {
+	switch f {
+	case FsyncAlways:
+		return "always"
+	case FsyncEverySec:
+		return "everysec"
+	case FsyncNever:
+		return "never"
+	default:
+		return fmt.Sprintf("fsync policy %d", uint8(f))
+	}
+}
+
+func ParseFsyncPolicy(s string) (FsyncPolicy, error) {
+	for _, f := range []FsyncPolicy{FsyncAlways, FsyncEverySec, FsyncNever} {
+		if s == f.String() {
+			return f, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown fsync policy `%s`", s)
+}
+
+// record is a request frame, appended to file
+type record interface {
+	WriteEnvelope(io.Writer) (int64, error)
+	io.WriterTo
+}
+
+// AOF is a store, which appends every mutation applied to wrapped store
+// to a file, using op wire encoding, so that it survives restarts.
+//
+// Written value is recorded as WRITE, deleted key as DELETE. Key with
+// TTL gets an additional EXPIREAT record, holding its deadline.
+//
+// While a snapshot is being taken, records are appended to `<path>.next`
+// instead, which replaces file at path once snapshot is written, as
+// snapshot covers everything recorded before it was started.
+type AOF struct {
+	inner   store.Store
+	clock   expiry.Clock
+	policy  FsyncPolicy
+	path    string
+	lock    *sync.Mutex // guards file & following fields
+	file    *os.File
+	buf     *bytes.Buffer
+	dirty   bool // records written since last fsync
+	rotated bool // records are being appended to `<path>.next`
+	done    chan struct{}
+	synced  chan struct{}
+}
+
+// nextSuffix is appended to path of file, which receives records while
+// snapshot is being taken
+const nextSuffix = ".next"
+
+// OpenAOF replays records found in file at path into inner store &
+// returns store, which keeps appending to that file. A torn final
+// record, left behind by a crash, is dropped from file. Records left in
+// `<path>.next` by a crash amid snapshot are replayed too & moved to end
+// of file at path.
+func OpenAOF(path string, policy FsyncPolicy, inner store.Store, clock expiry.Clock) (*AOF, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	good, err := replay(file, inner, clock)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if err := file.Truncate(good); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if _, err := file.Seek(good, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if err := mergeNext(file, path+nextSuffix, inner, clock); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	a := AOF{
+		inner:  inner,
+		clock:  clock,
+		policy: policy,
+		path:   path,
+		lock:   &sync.Mutex{},
+		file:   file,
+		buf:    new(bytes.Buffer),
+		done:   make(chan struct{}),
+		synced: make(chan struct{}),
+	}
+
+	// evicted keys are recorded as deleted, so that they don't come back
+	// on restart
+	if ev, ok := inner.(store.Evicter); ok {
+		ev.OnEvict(func(key op.Key) {
+			a.append(&op.DeleteRequest{Key: &key})
+		})
+	}
+
+	if policy == FsyncEverySec {
+		go a.syncEverySec()
+	} else {
+		close(a.synced)
+	}
+
+	return &a, nil
+}
+
+// countingReader counts bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// replay applies all complete records to store, returning offset till
+// which file holds complete records
+func replay(file *os.File, st store.Store, clock expiry.Clock) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	r := &countingReader{r: bufio.NewReader(file)}
+	var good int64
+
+	for {
+		env, err := op.ReadEnvelope(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return 0, err
+			}
+
+			if r.n != good {
+				log.Printf("Dropping torn record at offset %d of append-only file\n", good)
+			}
+
+			return good, nil
+		}
+
+		if int64(env.BodyLen) > info.Size()-r.n {
+			log.Printf("Dropping torn record at offset %d of append-only file\n", good)
+			return good, nil
+		}
+
+		body := make([]byte, env.BodyLen)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return 0, err
+		}
+
+		if err := apply(st, clock, env, body); err != nil {
+			return 0, fmt.Errorf("bad record at offset %d of append-only file : %w", good, err)
+		}
+
+		good = r.n
+	}
+}
+
+// mergeNext replays complete records of file at path & appends them to
+// file, removing former afterwards
+func mergeNext(file *os.File, path string, st store.Store, clock expiry.Clock) error {
+	next, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+	defer next.Close()
+
+	good, err := replay(next, st, clock)
+	if err != nil {
+		return err
+	}
+
+	if _, err := next.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if _, err := io.CopyN(file, next, good); err != nil {
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// apply applies one record to store
+func apply(st store.Store, clock expiry.Clock, env op.Envelope, body []byte) error {
+	switch env.Op {
+	case op.WRITE:
+		wReq := &op.WriteRequest{Header: env.Header}
+		if err := op.ReadBody(wReq, body); err != nil {
+			return err
+		}
+
+		st.Set(*wReq.Key, store.Entry{Value: *wReq.Value, TTL: wReq.TTL})
+		return nil
+
+	case op.DELETE:
+		dReq := &op.DeleteRequest{Header: env.Header}
+		if err := op.ReadBody(dReq, body); err != nil {
+			return err
+		}
+
+		st.Delete(*dReq.Key)
+		return nil
+
+	case op.RPUSH:
+		pReq := &op.PushRequest{ReadRequest: op.ReadRequest{Header: env.Header}}
+		if err := op.ReadBody(pReq, body); err != nil {
+			return err
+		}
+
+		_, err := st.Modify(*pReq.Key, func(entry store.Entry, ok bool) (store.Entry, error) {
+			var items op.List
+			if ok && entry.Type == store.List {
+				l, err := op.DecodeList(entry.Value)
+				if err != nil {
+					return entry, err
+				}
+
+				items = l
+			}
+
+			entry.Value, entry.Type = append(items, pReq.Values...).Encode(), store.List
+			return entry, nil
+		})
+		return err
+
+	case op.EXPIREAT:
+		eReq := &op.ExpireAtRequest{Header: env.Header}
+		if err := op.ReadBody(eReq, body); err != nil {
+			return err
+		}
+
+		ttl := eReq.Deadline.Sub(clock.Now())
+		st.Update(*eReq.Key, func(entry store.Entry, ok bool) (store.Entry, bool) {
+			entry.TTL = ttl
+			return entry, ok && ttl > 0
+		})
+		return nil
+
+	default:
+		return fmt.Errorf("unexpected opcode %d", env.Op)
+
+	}
+}
+
+func (a *AOF) Get(key op.Key) (store.Entry, bool) {
+	return a.inner.Get(key)
+}
+
+func (a *AOF) Set(key op.Key, entry store.Entry) {
+	// record is appended while key is locked in inner store, so that
+	// records of same key are in order they were applied
+	a.inner.Update(key, func(store.Entry, bool) (store.Entry, bool) {
+		a.appendSet(key, entry)
+		return entry, true
+	})
+}
+
+func (a *AOF) Delete(key op.Key) bool {
+	var existed bool
+	a.inner.Update(key, func(entry store.Entry, ok bool) (store.Entry, bool) {
+		existed = ok
+		if ok {
+			a.append(&op.DeleteRequest{Key: &key})
+		}
+
+		return entry, false
+	})
+
+	return existed
+}
+
+func (a *AOF) Update(key op.Key, fn func(store.Entry, bool) (store.Entry, bool)) {
+	a.inner.Update(key, func(entry store.Entry, ok bool) (store.Entry, bool) {
+		updated, keep := fn(entry, ok)
+		if keep {
+			a.appendSet(key, updated)
+		} else if ok {
+			a.append(&op.DeleteRequest{Key: &key})
+		}
+
+		return updated, keep
+	})
+}
+
+func (a *AOF) SetIf(key op.Key, entry store.Entry, cond func(store.Entry, bool) bool) (store.Entry, bool) {
+	return a.inner.SetIf(key, entry, func(current store.Entry, ok bool) bool {
+		if !cond(current, ok) {
+			return false
+		}
+
+		a.appendSet(key, entry)
+		return true
+	})
+}
+
+func (a *AOF) Modify(key op.Key, fn func(store.Entry, bool) (store.Entry, error)) (store.Entry, error) {
+	return a.inner.Modify(key, func(current store.Entry, ok bool) (store.Entry, error) {
+		entry, err := fn(current, ok)
+		if err != nil {
+			return entry, err
+		}
+
+		a.appendSet(key, entry)
+		return entry, nil
+	})
+}
+
+// UpdateMany appends records of all keys using single write, while all
+// of them are locked
+func (a *AOF) UpdateMany(keys []op.Key, fn func([]store.Entry, []bool)) {
+	a.inner.UpdateMany(keys, func(entries []store.Entry, exist []bool) {
+		existed := append([]bool{}, exist...)
+		fn(entries, exist)
+
+		records := make([]record, 0, len(keys))
+		for i := range keys {
+			if exist[i] {
+				records = append(records, a.setRecords(keys[i], entries[i])...)
+			} else if existed[i] {
+				records = append(records, &op.DeleteRequest{Key: &keys[i]})
+			}
+		}
+
+		if len(records) != 0 {
+			a.append(records...)
+		}
+	})
+}
+
+func (a *AOF) ModifyMany(keys []op.Key, fn func([]store.Entry, []bool, []bool) error) error {
+	return a.inner.ModifyMany(keys, func(entries []store.Entry, exist []bool, dirty []bool) error {
+		existed := append([]bool{}, exist...)
+		if err := fn(entries, exist, dirty); err != nil {
+			return err
+		}
+
+		records := make([]record, 0, len(keys))
+		for i := range keys {
+			if !dirty[i] {
+				continue
+			}
+
+			if exist[i] {
+				records = append(records, a.setRecords(keys[i], entries[i])...)
+			} else if existed[i] {
+				records = append(records, &op.DeleteRequest{Key: &keys[i]})
+			}
+		}
+
+		if len(records) != 0 {
+			a.append(records...)
+		}
+
+		return nil
+	})
+}
+
+func (a *AOF) Scan(fn func(op.Key, store.Entry) bool) {
+	a.inner.Scan(fn)
+}
+
+func (a *AOF) Range(start, end op.Key, limit int) []op.Key {
+	return a.inner.Range(start, end, limit)
+}
+
+func (a *AOF) Len() int {
+	return a.inner.Len()
+}
+
+func (a *AOF) Reserve(key op.Key, size int) error {
+	return a.inner.Reserve(key, size)
+}
+
+func (a *AOF) ReserveMany(sizes map[op.Key]int, keep []op.Key) error {
+	return a.inner.ReserveMany(sizes, keep)
+}
+
+func (a *AOF) Memory() store.MemoryStats {
+	return a.inner.Memory()
+}
+
+// Sweep sweeps inner store, when it needs sweeping. Keys reclaimed this
+// way aren't recorded, as their EXPIREAT record already removes them,
+// when replayed.
+func (a *AOF) Sweep() bool {
+	if sw, ok := a.inner.(store.Sweeper); ok {
+		return sw.Sweep()
+	}
+
+	return false
+}
+
+// OnChange passes fn to inner store, when it can tell about changes
+func (a *AOF) OnChange(fn func(op.Event)) {
+	if n, ok := a.inner.(store.Notifier); ok {
+		n.OnChange(fn)
+	}
+}
+
+// Close flushes appended records to disk & closes file
+func (a *AOF) Close() error {
+	close(a.done)
+	<-a.synced
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if err := a.file.Sync(); err != nil {
+		a.file.Close()
+		return err
+	}
+
+	return a.file.Close()
+}
+
+// rotate makes further records go to `<path>.next`, so that snapshot
+// taken afterwards covers all records of file at path. It's no-op when
+// earlier rotation wasn't settled, because snapshot failed.
+func (a *AOF) rotate() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.rotated {
+		return nil
+	}
+
+	next, err := os.OpenFile(a.path+nextSuffix, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if err := a.file.Sync(); err != nil {
+		next.Close()
+		return err
+	}
+
+	a.file.Close()
+	a.file = next
+	a.dirty = false
+	a.rotated = true
+	return nil
+}
+
+// settle replaces file at path with `<path>.next`, once snapshot covering
+// former is on disk
+func (a *AOF) settle() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if err := os.Rename(a.path+nextSuffix, a.path); err != nil {
+		return err
+	}
+
+	a.rotated = false
+	return syncDir(filepath.Dir(a.path))
+}
+
+func (a *AOF) appendSet(key op.Key, entry store.Entry) {
+	a.append(a.setRecords(key, entry)...)
+}
+
+// setRecords returns records, which set entry of key on replay. List is
+// recorded as deletion of key, followed by push of all its items, while
+// entry expiring right away is recorded as deletion only.
+func (a *AOF) setRecords(key op.Key, entry store.Entry) []record {
+	if entry.TTL < 0 {
+		return []record{&op.DeleteRequest{Key: &key}}
+	}
+
+	val := entry.Value
+	records := []record{&op.WriteRequest{Key: &key, Value: &val}}
+	if entry.Type == store.List {
+		items, err := op.DecodeList(entry.Value)
+		if err != nil {
+			log.Printf("Failed to decode list of `%s` : %s\n", key, err.Error())
+		}
+
+		records = []record{&op.DeleteRequest{Key: &key}, &op.PushRequest{ReadRequest: op.ReadRequest{Key: &key}, Values: items}}
+	}
+
+	if entry.TTL > 0 {
+		records = append(records, &op.ExpireAtRequest{Key: &key, Deadline: a.clock.Now().Add(entry.TTL)})
+	}
+
+	return records
+}
+
+// append writes records to file, using single write. As store interface
+// has no way to report failure, it's only logged.
+func (a *AOF) append(records ...record) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.buf.Reset()
+	for _, rec := range records {
+		if _, err := rec.WriteEnvelope(a.buf); err != nil {
+			log.Printf("Failed to encode append-only file record : %s\n", err.Error())
+			return
+		}
+
+		if _, err := rec.WriteTo(a.buf); err != nil {
+			log.Printf("Failed to encode append-only file record : %s\n", err.Error())
+			return
+		}
+	}
+
+	if _, err := a.file.Write(a.buf.Bytes()); err != nil {
+		log.Printf("Failed to append to append-only file : %s\n", err.Error())
+		return
+	}
+
+	a.dirty = true
+	if a.policy != FsyncAlways {
+		return
+	}
+
+	if err := a.file.Sync(); err != nil {
+		log.Printf("Failed to fsync append-only file : %s\n", err.Error())
+		return
+	}
+
+	a.dirty = false
+}
+
+func (a *AOF) syncEverySec() {
+	defer close(a.synced)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.done:
+			return
+
+		case <-ticker.C:
+			a.lock.Lock()
+			if a.dirty {
+				if err := a.file.Sync(); err != nil {
+					log.Printf("Failed to fsync append-only file : %s\n", err.Error())
+				} else {
+					a.dirty = false
+				}
+			}
+			a.lock.Unlock()
+
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package persist_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/persist"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+func openAOF(t *testing.T, path string, policy persist.FsyncPolicy, clock expiry.Clock) (*persist.AOF, *store.Map) {
+	inner := store.NewMap(clock)
+	aof, err := persist.OpenAOF(path, policy, inner, clock)
+	if err != nil {
+		t.Fatalf("Failed to open append-only file : %s\n", err.Error())
+	}
+
+	return aof, inner
+}
+
+func expectValue(t *testing.T, st store.Store, key op.Key, val string) {
+	entry, ok := st.Get(key)
+	if !ok || string(entry.Value) != val {
+		t.Fatalf("Expected `%s` = `%s`, received %+v [found %v]\n", key, val, entry, ok)
+	}
+}
+
+func TestAOF(t *testing.T) {
+	for _, policy := range []persist.FsyncPolicy{persist.FsyncAlways, persist.FsyncEverySec, persist.FsyncNever} {
+		path := filepath.Join(t.TempDir(), "tseep.aof")
+		clock := expiry.NewManualClock(time.Unix(1000, 0))
+
+		aof, _ := openAOF(t, path, policy, clock)
+		aof.Set("a", store.Entry{Value: op.Value("1")})
+		aof.Set("b", store.Entry{Value: op.Value("2"), TTL: time.Hour})
+		aof.Set("c", store.Entry{Value: op.Value("3"), TTL: time.Second})
+		aof.Set("d", store.Entry{Value: op.Value("4")})
+		aof.Update("a", func(entry store.Entry, ok bool) (store.Entry, bool) {
+			entry.Value = op.Value("11")
+			return entry, ok
+		})
+
+		if !aof.Delete("d") || aof.Delete("e") {
+			t.Fatalf("[%s] Expected only `d` to be deleted\n", policy)
+		}
+
+		// expiring right away is recorded as deletion
+		aof.Set("f", store.Entry{Value: op.Value("6")})
+		aof.Update("f", func(entry store.Entry, ok bool) (store.Entry, bool) {
+			entry.TTL = -1
+			return entry, ok
+		})
+
+		if err := aof.Close(); err != nil {
+			t.Fatalf("[%s] Failed to close : %s\n", policy, err.Error())
+		}
+
+		// restart happens 30 minutes later
+		clock.Advance(30 * time.Minute)
+		aof, inner := openAOF(t, path, policy, clock)
+
+		expectValue(t, inner, "a", "11")
+		expectValue(t, inner, "b", "2")
+		if entry, _ := inner.Get("b"); entry.TTL != 30*time.Minute {
+			t.Fatalf("[%s] Expected TTL of 30m, received %s\n", policy, entry.TTL)
+		}
+
+		for _, key := range []op.Key{"c", "d", "f"} {
+			if _, ok := inner.Get(key); ok {
+				t.Fatalf("[%s] Expected `%s` to be missing\n", policy, key)
+			}
+		}
+
+		if err := aof.Close(); err != nil {
+			t.Fatalf("[%s] Failed to close : %s\n", policy, err.Error())
+		}
+	}
+}
+
+func TestAOFTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tseep.aof")
+	clock := expiry.NewManualClock(time.Unix(1000, 0))
+
+	aof, _ := openAOF(t, path, persist.FsyncAlways, clock)
+	aof.Set("a", store.Entry{Value: op.Value("1")})
+	aof.Set("b", store.Entry{Value: op.Value("2")})
+	if err := aof.Close(); err != nil {
+		t.Fatalf("Failed to close : %s\n", err.Error())
+	}
+
+	full, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read : %s\n", err.Error())
+	}
+
+	// record of `b` is 1 + 4 + 4 + 1 + 4 + 1 bytes, only its first few
+	// bytes survive crash
+	good := int64(len(full) - 15)
+	for _, size := range []int64{good + 1, good + 3, good + 8} {
+		if err := os.WriteFile(path, full[:size], 0644); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+
+		aof, inner := openAOF(t, path, persist.FsyncAlways, clock)
+		expectValue(t, inner, "a", "1")
+		if _, ok := inner.Get("b"); ok {
+			t.Fatalf("Expected torn record of `b` to be dropped\n")
+		}
+
+		if info, _ := os.Stat(path); info.Size() != good {
+			t.Fatalf("Expected file to be truncated to %d bytes, found %d\n", good, info.Size())
+		}
+
+		if err := aof.Close(); err != nil {
+			t.Fatalf("Failed to close : %s\n", err.Error())
+		}
+	}
+
+	// records appended after recovery must be readable on next start
+	aof, _ = openAOF(t, path, persist.FsyncAlways, clock)
+	aof.Set("c", store.Entry{Value: op.Value("3")})
+	if err := aof.Close(); err != nil {
+		t.Fatalf("Failed to close : %s\n", err.Error())
+	}
+
+	aof, inner := openAOF(t, path, persist.FsyncAlways, clock)
+	expectValue(t, inner, "a", "1")
+	expectValue(t, inner, "c", "3")
+	aof.Close()
+}
+
+func TestAOFCorruptRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tseep.aof")
+
+	// READ isn't a mutation, so it never shows up in a healthy file
+	if err := os.WriteFile(path, []byte{byte(op.READ), 0, 2, 1, 'a'}, 0644); err != nil {
+		t.Fatalf("Failed to write : %s\n", err.Error())
+	}
+
+	if _, err := persist.OpenAOF(path, persist.FsyncNever, store.NewMap(expiry.System), expiry.System); err == nil {
+		t.Fatalf("Expected corrupt file to be rejected\n")
+	}
+}
+
+func TestParseFsyncPolicy(t *testing.T) {
+	for _, policy := range []persist.FsyncPolicy{persist.FsyncAlways, persist.FsyncEverySec, persist.FsyncNever} {
+		parsed, err := persist.ParseFsyncPolicy(policy.String())
+		if err != nil || parsed != policy {
+			t.Fatalf("Expected `%s`, received `%s`\n", policy, parsed)
+		}
+	}
+
+	if _, err := persist.ParseFsyncPolicy("sometimes"); err == nil {
+		t.Fatalf("Expected unknown policy to be rejected\n")
+	}
+}
+
+func TestAOFEviction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tseep.aof")
+	clock := expiry.NewManualClock(time.Unix(1000, 0))
+
+	aof, err := persist.OpenAOF(path, persist.FsyncAlways, store.NewMap(clock, store.WithMaxMemory(4, store.AllKeysLRU)), clock)
+	if err != nil {
+		t.Fatalf("Failed to open append-only file : %s\n", err.Error())
+	}
+
+	for _, key := range []op.Key{"a", "b", "c"} {
+		clock.Advance(time.Second)
+		if err := aof.Reserve(key, 2); err != nil {
+			t.Fatalf("Failed to reserve : %s\n", err.Error())
+		}
+
+		aof.Set(key, store.Entry{Value: op.Value("1")})
+	}
+
+	if err := aof.Close(); err != nil {
+		t.Fatalf("Failed to close : %s\n", err.Error())
+	}
+
+	// eviction of `a` is recorded, so it doesn't come back
+	aof, inner := openAOF(t, path, persist.FsyncAlways, clock)
+	defer aof.Close()
+
+	if _, ok := inner.Get("a"); ok {
+		t.Fatalf("Expected evicted `a` to be missing\n")
+	}
+
+	expectValue(t, inner, "b", "1")
+	expectValue(t, inner, "c", "1")
+}
+
+func TestAOFUpdateMany(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tseep.aof")
+	clock := expiry.NewManualClock(time.Unix(1000, 0))
+
+	aof, _ := openAOF(t, path, persist.FsyncAlways, clock)
+	aof.Set("a", store.Entry{Value: op.Value("1")})
+	aof.UpdateMany([]op.Key{"a", "b", "c"}, func(entries []store.Entry, exist []bool) {
+		exist[0] = false
+		entries[1], exist[1] = store.Entry{Value: op.Value("2"), TTL: time.Hour}, true
+		entries[2], exist[2] = store.Entry{Value: op.Value("3")}, true
+	})
+
+	if err := aof.Close(); err != nil {
+		t.Fatalf("Failed to close : %s\n", err.Error())
+	}
+
+	aof, inner := openAOF(t, path, persist.FsyncAlways, clock)
+	defer aof.Close()
+
+	if _, ok := inner.Get("a"); ok {
+		t.Fatalf("Expected `a` to be deleted\n")
+	}
+
+	expectValue(t, inner, "b", "2")
+	expectValue(t, inner, "c", "3")
+	if entry, _ := inner.Get("b"); entry.TTL != time.Hour {
+		t.Fatalf("Expected TTL of 1h, received %s\n", entry.TTL)
+	}
+}
+
+func TestAOFSetIf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tseep.aof")
+	clock := expiry.NewManualClock(time.Unix(1000, 0))
+
+	aof, _ := openAOF(t, path, persist.FsyncAlways, clock)
+	absent := func(_ store.Entry, ok bool) bool { return !ok }
+	aof.SetIf("a", store.Entry{Value: op.Value("1")}, absent)
+	aof.SetIf("a", store.Entry{Value: op.Value("2")}, absent)
+	if err := aof.Close(); err != nil {
+		t.Fatalf("Failed to close : %s\n", err.Error())
+	}
+
+	// skipped write isn't recorded
+	aof, inner := openAOF(t, path, persist.FsyncAlways, clock)
+	defer aof.Close()
+
+	expectValue(t, inner, "a", "1")
+}
+
+func TestAOFModify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tseep.aof")
+	clock := expiry.NewManualClock(time.Unix(1000, 0))
+
+	aof, _ := openAOF(t, path, persist.FsyncAlways, clock)
+	for _, val := range []string{"1", "2"} {
+		val := val
+		aof.Modify("a", func(entry store.Entry, ok bool) (store.Entry, error) {
+			entry.Value = op.Value(val)
+			return entry, nil
+		})
+	}
+
+	aof.Modify("a", func(entry store.Entry, ok bool) (store.Entry, error) {
+		return store.Entry{Value: op.Value("3")}, errors.New("skip")
+	})
+
+	if err := aof.Close(); err != nil {
+		t.Fatalf("Failed to close : %s\n", err.Error())
+	}
+
+	// failed modification isn't recorded
+	aof, inner := openAOF(t, path, persist.FsyncAlways, clock)
+	defer aof.Close()
+
+	expectValue(t, inner, "a", "2")
+}
+
+func TestAOFModifyMany(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tseep.aof")
+	clock := expiry.NewManualClock(time.Unix(1000, 0))
+
+	aof, _ := openAOF(t, path, persist.FsyncAlways, clock)
+	aof.Set("a", store.Entry{Value: op.Value("1")})
+	aof.Set("b", store.Entry{Value: op.Value("2")})
+
+	keys := []op.Key{"a", "b", "c"}
+	aof.ModifyMany(keys, func(entries []store.Entry, exist []bool, dirty []bool) error {
+		exist[0], dirty[0] = false, true
+		entries[1].Value = op.Value("x")
+		entries[2], exist[2], dirty[2] = store.Entry{Value: op.Value("3"), TTL: time.Hour}, true, true
+		return nil
+	})
+
+	aof.ModifyMany(keys, func(entries []store.Entry, exist []bool, dirty []bool) error {
+		exist[1], dirty[1] = false, true
+		return errors.New("skip")
+	})
+
+	if err := aof.Close(); err != nil {
+		t.Fatalf("Failed to close : %s\n", err.Error())
+	}
+
+	// only dirty keys of successful modification are recorded
+	aof, inner := openAOF(t, path, persist.FsyncAlways, clock)
+	defer aof.Close()
+
+	if _, ok := inner.Get("a"); ok {
+		t.Fatalf("Expected `a` to be deleted\n")
+	}
+
+	expectValue(t, inner, "b", "2")
+	expectValue(t, inner, "c", "3")
+	if entry, _ := inner.Get("c"); entry.TTL != time.Hour {
+		t.Fatalf("Expected TTL of 1h, received %s\n", entry.TTL)
+	}
+}
+
+func TestAOFOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tseep.aof")
+	clock := expiry.NewManualClock(time.Unix(1000, 0))
+
+	aof, _ := openAOF(t, path, persist.FsyncAlways, clock)
+	defer aof.Close()
+
+	// changes are reported by inner store, once they're applied
+	var events []op.Event
+	aof.OnChange(func(ev op.Event) {
+		events = append(events, ev)
+	})
+
+	aof.Set("a", store.Entry{Value: op.Value("1")})
+	aof.Delete("a")
+	if len(events) != 2 || events[0].Kind != op.KeyWritten || events[1].Kind != op.KeyDeleted {
+		t.Fatalf("Expected `a` to be written & deleted, received %+v\n", events)
+	}
+
+	// writing negative TTL makes key expire right away
+	aof.Set("b", store.Entry{Value: op.Value("2")})
+	aof.Update("b", func(entry store.Entry, ok bool) (store.Entry, bool) {
+		entry.TTL = -1
+		return entry, ok
+	})
+	if len(events) != 4 || events[3].Kind != op.KeyExpired {
+		t.Fatalf("Expected `b` to expire, received %+v\n", events)
+	}
+}
+
+func TestAOFList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tseep.aof")
+	clock := expiry.NewManualClock(time.Unix(1000, 0))
+
+	aof, _ := openAOF(t, path, persist.FsyncAlways, clock)
+	aof.Set("jobs", store.Entry{Value: op.Value("overwritten")})
+	for _, items := range []op.List{{op.Value("a")}, {op.Value("a"), op.Value("b")}, {op.Value("b")}} {
+		items := items
+		aof.Modify("jobs", func(entry store.Entry, ok bool) (store.Entry, error) {
+			return store.Entry{Value: items.Encode(), Type: store.List, TTL: time.Hour}, nil
+		})
+	}
+
+	if err := aof.Close(); err != nil {
+		t.Fatalf("Failed to close : %s\n", err.Error())
+	}
+
+	// list is replayed with its latest items, type & TTL
+	aof, inner := openAOF(t, path, persist.FsyncAlways, clock)
+	defer aof.Close()
+
+	entry, ok := inner.Get("jobs")
+	if !ok || entry.Type != store.List || entry.TTL != time.Hour {
+		t.Fatalf("Expected `jobs` to be list with TTL of 1h, found %+v\n", entry)
+	}
+
+	if items, err := op.DecodeList(entry.Value); err != nil || len(items) != 1 || string(items[0]) != "b" {
+		t.Fatalf("Expected `jobs` to hold `b`, found %q [%v]\n", items, err)
+	}
+}
@@ -0,0 +1,265 @@
+package persist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+// Snapshot file starts with a fixed size header, followed by entries.
+//
+//	magic    [4]byte  "TSNP"
+//	version  uint16
+//	count    uint64   number of entries
+//	checksum uint32   CRC-32 (Castagnoli) of all bytes after header
+//
+// Each entry is uvarint length prefixed key & value, followed by varint
+// deadline of key in unix milliseconds, zero for key without TTL, &
+// type of value as a byte. Version 1 snapshots, whose entries don't have
+// type, hold byte strings only & are still loaded.
+const (
+	snapshotVersion    = 2
+	snapshotHeaderSize = 4 + 2 + 8 + 4
+)
+
+var (
+	snapshotMagic = [4]byte{'T', 'S', 'N', 'P'}
+	crcTable      = crc32.MakeTable(crc32.Castagnoli)
+)
+
+var (
+	ErrBadMagic           = errors.New("not a snapshot file")
+	ErrUnsupportedVersion = errors.New("unsupported snapshot version")
+	ErrChecksum           = errors.New("snapshot checksum mismatch")
+	ErrCorruptSnapshot    = errors.New("corrupt snapshot")
+)
+
+type snapshotEntry struct {
+	key      op.Key
+	value    op.Value
+	typ      store.Type
+	deadline int64
+}
+
+// WriteSnapshot writes all live entries of store to file at path. Store
+// is only locked while its entries are being collected, encoding & disk
+// writes happen afterwards. File is written under temporary name &
+// renamed into place once it's on disk, so path never holds a partial
+// snapshot.
+func WriteSnapshot(path string, st store.Store, clock expiry.Clock) error {
+	now := clock.Now()
+
+	var entries []snapshotEntry
+	st.Scan(func(key op.Key, entry store.Entry) bool {
+		var deadline int64
+		if entry.TTL > 0 {
+			deadline = now.Add(entry.TTL).UnixNano() / int64(time.Millisecond)
+		}
+
+		entries = append(entries, snapshotEntry{key: key, value: entry.Value, typ: entry.Type, deadline: deadline})
+		return true
+	})
+
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if err := writeSnapshot(file, entries); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return syncDir(filepath.Dir(path))
+}
+
+// writeSnapshot encodes entries after room left for header, which is
+// filled in once checksum of entries is known
+func writeSnapshot(file *os.File, entries []snapshotEntry) error {
+	if _, err := file.Seek(snapshotHeaderSize, io.SeekStart); err != nil {
+		return err
+	}
+
+	crc := crc32.New(crcTable)
+	w := bufio.NewWriter(io.MultiWriter(file, crc))
+	buf := make([]byte, binary.MaxVarintLen64)
+
+	for _, e := range entries {
+		n := binary.PutUvarint(buf, uint64(len(e.key)))
+		w.Write(buf[:n])
+		w.WriteString(string(e.key))
+
+		n = binary.PutUvarint(buf, uint64(len(e.value)))
+		w.Write(buf[:n])
+		w.Write(e.value)
+
+		n = binary.PutVarint(buf, e.deadline)
+		w.Write(buf[:n])
+		w.WriteByte(byte(e.typ))
+	}
+
+	// bufio writer keeps first error, which is reported here
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	header := make([]byte, snapshotHeaderSize)
+	copy(header, snapshotMagic[:])
+	binary.BigEndian.PutUint16(header[4:], snapshotVersion)
+	binary.BigEndian.PutUint64(header[6:], uint64(len(entries)))
+	binary.BigEndian.PutUint32(header[14:], crc.Sum32())
+
+	if _, err := file.WriteAt(header, 0); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// LoadSnapshot sets all entries found in snapshot file at path, which
+// haven't expired yet, into store. Whole file is verified before store
+// is touched, so a corrupt snapshot is rejected without being half
+// loaded. Missing file is taken as empty snapshot.
+func LoadSnapshot(path string, st store.Store, clock expiry.Clock) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	entries, err := decodeSnapshot(data)
+	if err != nil {
+		return err
+	}
+
+	now := clock.Now()
+	for _, e := range entries {
+		var ttl time.Duration
+		if e.deadline != 0 {
+			ttl = time.Unix(0, e.deadline*int64(time.Millisecond)).Sub(now)
+			if ttl <= 0 {
+				continue
+			}
+		}
+
+		st.Set(e.key, store.Entry{Value: e.value, Type: e.typ, TTL: ttl})
+	}
+
+	return nil
+}
+
+func decodeSnapshot(data []byte) ([]snapshotEntry, error) {
+	if len(data) < snapshotHeaderSize || !bytes.Equal(data[:4], snapshotMagic[:]) {
+		return nil, ErrBadMagic
+	}
+
+	version := binary.BigEndian.Uint16(data[4:])
+	if version != 1 && version != snapshotVersion {
+		return nil, fmt.Errorf("%w : %d", ErrUnsupportedVersion, version)
+	}
+
+	count := binary.BigEndian.Uint64(data[6:])
+	body := data[snapshotHeaderSize:]
+	if crc32.Checksum(body, crcTable) != binary.BigEndian.Uint32(data[14:]) {
+		return nil, ErrChecksum
+	}
+
+	r := bytes.NewReader(body)
+	// every entry takes at least 3 bytes, which bounds preallocation
+	entries := make([]snapshotEntry, 0, min(count, uint64(len(body)/3)))
+
+	for i := uint64(0); i < count; i++ {
+		key, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+
+		deadline, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, ErrCorruptSnapshot
+		}
+
+		typ := store.String
+		if version > 1 {
+			b, err := r.ReadByte()
+			if err != nil || store.Type(b) > store.List {
+				return nil, ErrCorruptSnapshot
+			}
+
+			typ = store.Type(b)
+		}
+
+		entries = append(entries, snapshotEntry{key: op.Key(key), value: op.Value(value), typ: typ, deadline: deadline})
+	}
+
+	if r.Len() != 0 {
+		return nil, ErrCorruptSnapshot
+	}
+
+	return entries, nil
+}
+
+func readBytes(r *bytes.Reader) ([]byte, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil || size > uint64(r.Len()) {
+		return nil, ErrCorruptSnapshot
+	}
+
+	b := make([]byte, size)
+	r.Read(b)
+	return b, nil
+}
+
+func min(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// syncDir flushes directory entry changes, such as renames, to disk
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+
+	return d.Close()
+}
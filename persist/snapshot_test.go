@@ -0,0 +1,204 @@
+package persist_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/persist"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+func TestSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tseep.snap")
+	clock := expiry.NewManualClock(time.Unix(1000, 0))
+
+	st := store.NewSharded(4, clock)
+	for i := 0; i < 100; i++ {
+		st.Set(op.Key(fmt.Sprintf("key-%d", i)), store.Entry{Value: op.Value(fmt.Sprint(i))})
+	}
+	st.Set("short", store.Entry{Value: op.Value("s"), TTL: time.Second})
+	st.Set("long", store.Entry{Value: op.Value("l"), TTL: time.Hour})
+	st.Set("empty", store.Entry{Value: op.Value{}})
+	st.Set("list", store.Entry{Value: op.List{op.Value("a"), op.Value("b")}.Encode(), Type: store.List})
+
+	if err := persist.WriteSnapshot(path, st, clock); err != nil {
+		t.Fatalf("Failed to write snapshot : %s\n", err.Error())
+	}
+
+	// restart happens 30 minutes later
+	clock.Advance(30 * time.Minute)
+	loaded := store.NewMap(clock)
+	if err := persist.LoadSnapshot(path, loaded, clock); err != nil {
+		t.Fatalf("Failed to load snapshot : %s\n", err.Error())
+	}
+
+	if loaded.Len() != 103 {
+		t.Fatalf("Expected 103 keys, found %d\n", loaded.Len())
+	}
+
+	for i := 0; i < 100; i++ {
+		expectValue(t, loaded, op.Key(fmt.Sprintf("key-%d", i)), fmt.Sprint(i))
+	}
+	expectValue(t, loaded, "empty", "")
+	if entry, _ := loaded.Get("list"); entry.Type != store.List {
+		t.Fatalf("Expected `list` to keep its type, found %+v\n", entry)
+	}
+
+	if entry, _ := loaded.Get("long"); entry.TTL != 30*time.Minute {
+		t.Fatalf("Expected TTL of 30m, received %s\n", entry.TTL)
+	}
+
+	if _, ok := loaded.Get("short"); ok {
+		t.Fatalf("Expected `short` to be expired\n")
+	}
+
+	// missing file is an empty snapshot
+	if err := persist.LoadSnapshot(filepath.Join(t.TempDir(), "missing"), loaded, clock); err != nil {
+		t.Fatalf("Expected missing snapshot to be ignored, received %s\n", err.Error())
+	}
+}
+
+func TestSnapshotCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tseep.snap")
+	clock := expiry.NewManualClock(time.Unix(1000, 0))
+
+	st := store.NewMap(clock)
+	st.Set("a", store.Entry{Value: op.Value("1")})
+	st.Set("b", store.Entry{Value: op.Value("2"), TTL: time.Hour})
+
+	if err := persist.WriteSnapshot(path, st, clock); err != nil {
+		t.Fatalf("Failed to write snapshot : %s\n", err.Error())
+	}
+
+	full, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read : %s\n", err.Error())
+	}
+
+	corrupt := func(i int) []byte {
+		b := append([]byte{}, full...)
+		b[i] ^= 0xff
+		return b
+	}
+
+	cases := []struct {
+		data []byte
+		err  error
+	}{
+		{data: corrupt(0), err: persist.ErrBadMagic},
+		{data: full[:10], err: persist.ErrBadMagic},
+		{data: corrupt(5), err: persist.ErrUnsupportedVersion},
+		{data: corrupt(len(full) - 1), err: persist.ErrChecksum},
+		{data: full[:len(full)-1], err: persist.ErrChecksum},
+		{data: corrupt(17), err: persist.ErrChecksum},
+		{data: corrupt(13), err: persist.ErrCorruptSnapshot},
+	}
+
+	for i, c := range cases {
+		if err := os.WriteFile(path, c.data, 0644); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+
+		loaded := store.NewMap(clock)
+		if err := persist.LoadSnapshot(path, loaded, clock); !errors.Is(err, c.err) {
+			t.Fatalf("[%d] Expected `%v`, received `%v`\n", i, c.err, err)
+		}
+
+		if loaded.Len() != 0 {
+			t.Fatalf("[%d] Expected corrupt snapshot not to be loaded, found %d keys\n", i, loaded.Len())
+		}
+	}
+}
+
+func TestSnapshotterCompactsAOF(t *testing.T) {
+	dir := t.TempDir()
+	aofPath, snapPath := filepath.Join(dir, "tseep.aof"), filepath.Join(dir, "tseep.snap")
+	clock := expiry.NewManualClock(time.Unix(1000, 0))
+
+	aof, _ := openAOF(t, aofPath, persist.FsyncAlways, clock)
+	snapshotter := persist.NewSnapshotter(snapPath, aof, clock)
+	for i := 0; i < 100; i++ {
+		snapshotter.Set("counter", store.Entry{Value: op.Value(fmt.Sprint(i))})
+	}
+	snapshotter.Set("gone", store.Entry{Value: op.Value("x")})
+	snapshotter.Delete("gone")
+
+	if err := snapshotter.Save(); err != nil {
+		t.Fatalf("Failed to save : %s\n", err.Error())
+	}
+
+	if info, _ := os.Stat(aofPath); info.Size() != 0 {
+		t.Fatalf("Expected append-only file to be compacted, found %d bytes\n", info.Size())
+	}
+
+	snapshotter.Set("after", store.Entry{Value: op.Value("1")})
+	saved := make(chan error, 1)
+	go func() { saved <- snapshotter.Save() }()
+	snapshotter.Set("later", store.Entry{Value: op.Value("2")})
+	if err := <-saved; err != nil {
+		t.Fatalf("Failed to save in background : %s\n", err.Error())
+	}
+
+	snapshotter.Close()
+	if err := aof.Close(); err != nil {
+		t.Fatalf("Failed to close : %s\n", err.Error())
+	}
+
+	if _, err := os.Stat(aofPath + ".next"); !os.IsNotExist(err) {
+		t.Fatalf("Expected rotated file to be settled\n")
+	}
+
+	inner := store.NewMap(clock)
+	if err := persist.LoadSnapshot(snapPath, inner, clock); err != nil {
+		t.Fatalf("Failed to load snapshot : %s\n", err.Error())
+	}
+
+	aof, err := persist.OpenAOF(aofPath, persist.FsyncAlways, inner, clock)
+	if err != nil {
+		t.Fatalf("Failed to open append-only file : %s\n", err.Error())
+	}
+	defer aof.Close()
+
+	expectValue(t, inner, "counter", "99")
+	expectValue(t, inner, "after", "1")
+	expectValue(t, inner, "later", "2")
+	if _, ok := inner.Get("gone"); ok {
+		t.Fatalf("Expected `gone` to be missing\n")
+	}
+}
+
+func TestAOFMergesNext(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tseep.aof")
+	clock := expiry.NewManualClock(time.Unix(1000, 0))
+
+	// crash after rotation, before snapshot was written, leaves records
+	// split over both files
+	for p, key := range map[string]op.Key{path: "a", path + ".next": "b"} {
+		aof, _ := openAOF(t, p, persist.FsyncAlways, clock)
+		aof.Set(key, store.Entry{Value: op.Value(key)})
+		if err := aof.Close(); err != nil {
+			t.Fatalf("Failed to close : %s\n", err.Error())
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		aof, inner := openAOF(t, path, persist.FsyncAlways, clock)
+		expectValue(t, inner, "a", "a")
+		expectValue(t, inner, "b", "b")
+
+		if _, err := os.Stat(path + ".next"); !os.IsNotExist(err) {
+			t.Fatalf("Expected rotated file to be merged\n")
+		}
+
+		if err := aof.Close(); err != nil {
+			t.Fatalf("Failed to close : %s\n", err.Error())
+		}
+	}
+}
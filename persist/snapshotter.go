@@ -0,0 +1,73 @@
+package persist
+
+import (
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+// Snapshotter is a store, which can save snapshot of wrapped store on
+// demand. When wrapped store is an append-only file, it's compacted
+// after each snapshot, keeping only records appended since snapshot was
+// started.
+type Snapshotter struct {
+	store.Store
+	path  string
+	aof   *AOF
+	clock expiry.Clock
+	busy  chan struct{} // holds a token while snapshot is being saved
+}
+
+func NewSnapshotter(path string, st store.Store, clock expiry.Clock) *Snapshotter {
+	aof, _ := st.(*AOF)
+	return &Snapshotter{Store: st, path: path, aof: aof, clock: clock, busy: make(chan struct{}, 1)}
+}
+
+// Save saves snapshot, waiting for one being saved already to complete
+// first, if any
+func (s *Snapshotter) Save() error {
+	s.busy <- struct{}{}
+	defer func() { <-s.busy }()
+
+	return s.save()
+}
+
+func (s *Snapshotter) save() error {
+	if s.aof != nil {
+		if err := s.aof.rotate(); err != nil {
+			return err
+		}
+	}
+
+	if err := WriteSnapshot(s.path, s.Store, s.clock); err != nil {
+		return err
+	}
+
+	if s.aof != nil {
+		return s.aof.settle()
+	}
+
+	return nil
+}
+
+// Sweep sweeps wrapped store, when it needs sweeping
+func (s *Snapshotter) Sweep() bool {
+	if sw, ok := s.Store.(store.Sweeper); ok {
+		return sw.Sweep()
+	}
+
+	return false
+}
+
+// OnChange passes fn to wrapped store, when it can tell about changes
+func (s *Snapshotter) OnChange(fn func(op.Event)) {
+	if n, ok := s.Store.(store.Notifier); ok {
+		n.OnChange(fn)
+	}
+}
+
+// Close waits for snapshot being saved to complete, if any. No snapshot
+// can be saved afterwards.
+func (s *Snapshotter) Close() {
+	s.busy <- struct{}{}
+}
@@ -0,0 +1,381 @@
+package pubsub
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+// Policy tells what's done, when message is published for subscriber,
+// whose outbound queue is full
+type Policy uint8
+
+const (
+	DropMessage Policy = iota + 1 // message is dropped for that subscriber
+	Disconnect                    // subscriber's connection is closed
+)
+
+func (p Policy) String() string {
+	switch p {
+	case DropMessage:
+		return "drop"
+	case Disconnect:
+		return "disconnect"
+	default:
+		return fmt.Sprintf("push policy %d", uint8(p))
+	}
+}
+
+func ParsePolicy(s string) (Policy, error) {
+	for _, p := range []Policy{DropMessage, Disconnect} {
+		if s == p.String() {
+			return p, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown push policy `%s`", s)
+}
+
+// DefaultQueueSize is how many messages may be queued for subscriber,
+// unless set otherwise
+const DefaultQueueSize = 1024
+
+// QueueFull is error frame, which may be sent to subscriber before it's
+// disconnected, as its queue is full
+var QueueFull = func() []byte {
+	buf := new(bytes.Buffer)
+	e := op.Error{Code: op.Overloaded, Message: "push queue is full"}
+	e.WriteTo(buf)
+	return buf.Bytes()
+}()
+
+// Subscriber is pub/sub side of one connection. Messages published for
+// it are handed to connection, as encoded frames, till `size` of them
+// are yet to be written, after which its policy kicks in.
+type Subscriber struct {
+	size    int64
+	policy  Policy
+	send    func(frame []byte) bool
+	close   func()
+	once    sync.Once
+	pending int64  // frames handed to send, yet to be written
+	dropped uint64 // messages dropped, as queue was full
+
+	channels map[op.Key]struct{}     // guarded by lock of broker
+	patterns map[op.Pattern]struct{} // guarded by lock of broker
+}
+
+// NewSubscriber creates subscriber for connection, where send hands
+// frame over to connection, without blocking, reporting whether it
+// could be, while close disconnects it. Connection must call `Sent`,
+// once each handed frame is written.
+func NewSubscriber(size int, policy Policy, send func(frame []byte) bool, close func()) *Subscriber {
+	return &Subscriber{
+		size:     int64(size),
+		policy:   policy,
+		send:     send,
+		close:    close,
+		channels: make(map[op.Key]struct{}),
+		patterns: make(map[op.Pattern]struct{}),
+	}
+}
+
+// push hands frame over to connection, reporting whether it could be
+func (s *Subscriber) push(frame []byte) bool {
+	if atomic.AddInt64(&s.pending, 1) > s.size || !s.send(frame) {
+		atomic.AddInt64(&s.pending, -1)
+		atomic.AddUint64(&s.dropped, 1)
+
+		if s.policy == Disconnect {
+			s.once.Do(s.close)
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// Sent marks one handed frame as written, making room for another
+func (s *Subscriber) Sent() {
+	atomic.AddInt64(&s.pending, -1)
+}
+
+// Dropped returns number of messages, which couldn't be handed to
+// connection
+func (s *Subscriber) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// subscriptions returns number of channels & patterns subscriber is
+// subscribed to, while lock of broker is held
+func (s *Subscriber) subscriptions() int {
+	return len(s.channels) + len(s.patterns)
+}
+
+// Broker keeps track of subscribers of each channel & fans published
+// messages out to them. It also keeps track of subscribers watching
+// keys, fanning changes of store out to them.
+type Broker struct {
+	lock     sync.RWMutex
+	channels map[op.Key]map[*Subscriber]struct{}
+	keys     map[op.Key]map[*Subscriber]struct{} // watched keys
+	prefixes map[op.Key]map[*Subscriber]struct{} // watched prefixes
+	lengths  map[int]int                         // watched prefixes by length
+	watching int64                               // watched patterns, updated atomically
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		channels: make(map[op.Key]map[*Subscriber]struct{}),
+		keys:     make(map[op.Key]map[*Subscriber]struct{}),
+		prefixes: make(map[op.Key]map[*Subscriber]struct{}),
+		lengths:  make(map[int]int),
+	}
+}
+
+// Subscribe adds subscriber to channels, returning number of channels &
+// patterns it's subscribed to afterwards
+func (b *Broker) Subscribe(sub *Subscriber, channels []op.Key) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, channel := range channels {
+		subs, ok := b.channels[channel]
+		if !ok {
+			subs = make(map[*Subscriber]struct{})
+			b.channels[channel] = subs
+		}
+
+		subs[sub] = struct{}{}
+		sub.channels[channel] = struct{}{}
+	}
+
+	return sub.subscriptions()
+}
+
+// Unsubscribe removes subscriber from channels, where no channels denote
+// all of them, returning number of channels & patterns it's still
+// subscribed to
+func (b *Broker) Unsubscribe(sub *Subscriber, channels []op.Key) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if len(channels) == 0 {
+		for channel := range sub.channels {
+			b.leave(sub, channel)
+		}
+
+		return sub.subscriptions()
+	}
+
+	for _, channel := range channels {
+		b.leave(sub, channel)
+	}
+
+	return sub.subscriptions()
+}
+
+// Watch makes subscriber watch changes of keys matching patterns,
+// returning number of channels & patterns it's subscribed to afterwards
+func (b *Broker) Watch(sub *Subscriber, patterns []op.Pattern) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, pattern := range patterns {
+		if _, ok := sub.patterns[pattern]; ok {
+			continue
+		}
+
+		watchers := b.keys
+		if pattern.Prefix {
+			watchers = b.prefixes
+		}
+
+		subs, ok := watchers[pattern.Key]
+		if !ok {
+			subs = make(map[*Subscriber]struct{})
+			watchers[pattern.Key] = subs
+			if pattern.Prefix {
+				b.lengths[len(pattern.Key)]++
+			}
+		}
+
+		subs[sub] = struct{}{}
+		sub.patterns[pattern] = struct{}{}
+		atomic.AddInt64(&b.watching, 1)
+	}
+
+	return sub.subscriptions()
+}
+
+// Unwatch stops subscriber from watching patterns, where no patterns
+// denote all of them, returning number of channels & patterns it's still
+// subscribed to
+func (b *Broker) Unwatch(sub *Subscriber, patterns []op.Pattern) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if len(patterns) == 0 {
+		for pattern := range sub.patterns {
+			b.unwatch(sub, pattern)
+		}
+
+		return sub.subscriptions()
+	}
+
+	for _, pattern := range patterns {
+		b.unwatch(sub, pattern)
+	}
+
+	return sub.subscriptions()
+}
+
+// Leave removes subscriber from all channels & stops it from watching
+// any key, as its connection is gone
+func (b *Broker) Leave(sub *Subscriber) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for channel := range sub.channels {
+		b.leave(sub, channel)
+	}
+
+	for pattern := range sub.patterns {
+		b.unwatch(sub, pattern)
+	}
+}
+
+func (b *Broker) leave(sub *Subscriber, channel op.Key) {
+	delete(sub.channels, channel)
+
+	subs, ok := b.channels[channel]
+	if !ok {
+		return
+	}
+
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(b.channels, channel)
+	}
+}
+
+func (b *Broker) unwatch(sub *Subscriber, pattern op.Pattern) {
+	if _, ok := sub.patterns[pattern]; !ok {
+		return
+	}
+
+	delete(sub.patterns, pattern)
+	atomic.AddInt64(&b.watching, -1)
+
+	watchers := b.keys
+	if pattern.Prefix {
+		watchers = b.prefixes
+	}
+
+	subs := watchers[pattern.Key]
+	delete(subs, sub)
+	if len(subs) != 0 {
+		return
+	}
+
+	delete(watchers, pattern.Key)
+	if pattern.Prefix {
+		if b.lengths[len(pattern.Key)]--; b.lengths[len(pattern.Key)] == 0 {
+			delete(b.lengths, len(pattern.Key))
+		}
+	}
+}
+
+// Subscriptions returns number of channels & patterns subscriber is
+// subscribed to
+func (b *Broker) Subscriptions(sub *Subscriber) int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return sub.subscriptions()
+}
+
+// Publish pushes message to every subscriber of channel, returning number
+// of them, it could be handed to. Message is encoded once & same frame
+// is shared by all of them.
+func (b *Broker) Publish(channel op.Key, payload op.Value) int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	subs := b.channels[channel]
+	if len(subs) == 0 {
+		return 0
+	}
+
+	buf := new(bytes.Buffer)
+	msg := op.Message{Channel: channel, Payload: payload}
+	if _, err := msg.WriteTo(buf); err != nil {
+		return 0
+	}
+
+	receivers := 0
+	for sub := range subs {
+		if sub.push(buf.Bytes()) {
+			receivers++
+		}
+	}
+
+	return receivers
+}
+
+// Notify pushes change of key to every subscriber watching it, returning
+// number of them, it could be handed to. Subscriber watching key through
+// many patterns gets it once. It's meant to be passed to
+// `store.Notifier`, so it must not block.
+func (b *Broker) Notify(ev op.Event) int {
+	if atomic.LoadInt64(&b.watching) == 0 {
+		return 0
+	}
+
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	var watchers map[*Subscriber]struct{}
+	collect := func(subs map[*Subscriber]struct{}) {
+		if len(subs) == 0 {
+			return
+		}
+
+		if watchers == nil {
+			watchers = make(map[*Subscriber]struct{}, len(subs))
+		}
+
+		for sub := range subs {
+			watchers[sub] = struct{}{}
+		}
+	}
+
+	collect(b.keys[ev.Key])
+	for length := range b.lengths {
+		if length <= len(ev.Key) {
+			collect(b.prefixes[ev.Key[:length]])
+		}
+	}
+
+	if len(watchers) == 0 {
+		return 0
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err := ev.WriteTo(buf); err != nil {
+		return 0
+	}
+
+	receivers := 0
+	for sub := range watchers {
+		if sub.push(buf.Bytes()) {
+			receivers++
+		}
+	}
+
+	return receivers
+}
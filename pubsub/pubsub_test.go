@@ -0,0 +1,172 @@
+package pubsub_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/pubsub"
+)
+
+// conn collects frames handed to subscriber, without writing them
+type conn struct {
+	frames [][]byte
+	closed int
+}
+
+func newSubscriber(size int, policy pubsub.Policy) (*pubsub.Subscriber, *conn) {
+	c := new(conn)
+	send := func(frame []byte) bool {
+		c.frames = append(c.frames, frame)
+		return true
+	}
+
+	return pubsub.NewSubscriber(size, policy, send, func() { c.closed++ }), c
+}
+
+func TestBroker(t *testing.T) {
+	b := pubsub.NewBroker()
+	sub1, conn1 := newSubscriber(8, pubsub.DropMessage)
+	sub2, conn2 := newSubscriber(8, pubsub.DropMessage)
+
+	if n := b.Subscribe(sub1, []op.Key{"news", "sports", "news"}); n != 2 {
+		t.Fatalf("Expected 2 subscriptions, found %d\n", n)
+	}
+
+	b.Subscribe(sub2, []op.Key{"news"})
+	if n := b.Publish("news", op.Value("hello")); n != 2 {
+		t.Fatalf("Expected 2 receivers, found %d\n", n)
+	}
+
+	if n := b.Publish("weather", op.Value("sunny")); n != 0 {
+		t.Fatalf("Expected no receivers, found %d\n", n)
+	}
+
+	msg := new(op.Message)
+	if _, err := msg.ReadFrom(bytes.NewReader(conn1.frames[0])); err != nil || msg.Channel != "news" || string(msg.Payload) != "hello" {
+		t.Fatalf("Expected `hello` on `news`, received %+v [%v]\n", msg, err)
+	}
+
+	if len(conn2.frames) != 1 {
+		t.Fatalf("Expected 1 frame for second subscriber, found %d\n", len(conn2.frames))
+	}
+
+	if n := b.Unsubscribe(sub1, []op.Key{"news"}); n != 1 {
+		t.Fatalf("Expected 1 subscription left, found %d\n", n)
+	}
+
+	if n := b.Publish("news", op.Value("again")); n != 1 || len(conn1.frames) != 1 {
+		t.Fatalf("Expected only second subscriber to receive, found %d receivers\n", n)
+	}
+
+	if n := b.Unsubscribe(sub1, nil); n != 0 || b.Subscriptions(sub1) != 0 {
+		t.Fatalf("Expected no subscriptions left, found %d\n", n)
+	}
+
+	if n := b.Publish("sports", op.Value("goal")); n != 0 {
+		t.Fatalf("Expected no receivers, found %d\n", n)
+	}
+}
+
+func TestBrokerWatch(t *testing.T) {
+	b := pubsub.NewBroker()
+	sub1, conn1 := newSubscriber(8, pubsub.DropMessage)
+	sub2, conn2 := newSubscriber(8, pubsub.DropMessage)
+
+	if n := b.Notify(op.Event{Key: "config/app", Kind: op.KeyWritten, Version: 1}); n != 0 {
+		t.Fatalf("Expected no receivers, found %d\n", n)
+	}
+
+	b.Subscribe(sub1, []op.Key{"news"})
+	if n := b.Watch(sub1, []op.Pattern{{Key: "config/app"}, {Key: "config/", Prefix: true}}); n != 3 {
+		t.Fatalf("Expected 3 subscriptions, found %d\n", n)
+	}
+
+	b.Watch(sub2, []op.Pattern{{Key: "config/", Prefix: true}, {Key: "c", Prefix: true}})
+
+	// key matched by many patterns of one subscriber is pushed once
+	if n := b.Notify(op.Event{Key: "config/app", Kind: op.KeyDeleted, Version: 2}); n != 2 {
+		t.Fatalf("Expected 2 receivers, found %d\n", n)
+	}
+
+	if n := b.Notify(op.Event{Key: "cache", Kind: op.KeyWritten, Version: 3}); n != 1 {
+		t.Fatalf("Expected 1 receiver, found %d\n", n)
+	}
+
+	if n := b.Notify(op.Event{Key: "news", Kind: op.KeyWritten, Version: 4}); n != 0 {
+		t.Fatalf("Expected no receivers, found %d\n", n)
+	}
+
+	if len(conn1.frames) != 1 || len(conn2.frames) != 2 {
+		t.Fatalf("Expected 1 & 2 frames, found %d & %d\n", len(conn1.frames), len(conn2.frames))
+	}
+
+	ev := new(op.Event)
+	if _, err := ev.ReadFrom(bytes.NewReader(conn1.frames[0])); err != nil || *ev != (op.Event{Key: "config/app", Kind: op.KeyDeleted, Version: 2}) {
+		t.Fatalf("Expected deletion of `config/app`, received %+v [%v]\n", ev, err)
+	}
+
+	if n := b.Unwatch(sub1, []op.Pattern{{Key: "config/", Prefix: true}}); n != 2 {
+		t.Fatalf("Expected 2 subscriptions left, found %d\n", n)
+	}
+
+	if n := b.Notify(op.Event{Key: "config/db", Kind: op.KeyExpired, Version: 5}); n != 1 || len(conn1.frames) != 1 {
+		t.Fatalf("Expected only second subscriber to receive, found %d receivers\n", n)
+	}
+
+	if n := b.Unwatch(sub1, nil); n != 1 {
+		t.Fatalf("Expected 1 subscription left, found %d\n", n)
+	}
+
+	b.Leave(sub1)
+	b.Leave(sub2)
+	if n := b.Subscriptions(sub1) + b.Subscriptions(sub2); n != 0 {
+		t.Fatalf("Expected no subscriptions left, found %d\n", n)
+	}
+
+	if n := b.Notify(op.Event{Key: "config/app", Kind: op.KeyWritten, Version: 6}); n != 0 {
+		t.Fatalf("Expected no receivers, found %d\n", n)
+	}
+}
+
+func TestSubscriberQueue(t *testing.T) {
+	for _, policy := range []pubsub.Policy{pubsub.DropMessage, pubsub.Disconnect} {
+		b := pubsub.NewBroker()
+		sub, c := newSubscriber(2, policy)
+		b.Subscribe(sub, []op.Key{"news"})
+
+		// third message finds queue full, till one is written
+		for i, expected := range []int{1, 1, 0, 0} {
+			if n := b.Publish("news", op.Value("hello")); n != expected {
+				t.Fatalf("[%s] Expected %d receivers of message #%d, found %d\n", policy, expected, i, n)
+			}
+		}
+
+		sub.Sent()
+		if n := b.Publish("news", op.Value("hello")); n != 1 {
+			t.Fatalf("[%s] Expected message to be queued, once queue has room\n", policy)
+		}
+
+		if sub.Dropped() != 2 || len(c.frames) != 3 {
+			t.Fatalf("[%s] Expected 2 dropped & 3 queued messages, found %d & %d\n", policy, sub.Dropped(), len(c.frames))
+		}
+
+		// connection is closed only once
+		if closed := map[pubsub.Policy]int{pubsub.DropMessage: 0, pubsub.Disconnect: 1}[policy]; c.closed != closed {
+			t.Fatalf("[%s] Expected connection to be closed %d time(s), found %d\n", policy, closed, c.closed)
+		}
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	for _, policy := range []pubsub.Policy{pubsub.DropMessage, pubsub.Disconnect} {
+		parsed, err := pubsub.ParsePolicy(policy.String())
+		if err != nil || parsed != policy {
+			t.Fatalf("Expected `%s`, received `%s`\n", policy, parsed)
+		}
+	}
+
+	if _, err := pubsub.ParsePolicy("block"); err == nil {
+		t.Fatalf("Expected unknown policy to be rejected\n")
+	}
+}
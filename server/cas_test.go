@@ -0,0 +1,88 @@
+package server_test
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/server"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+func TestCAS(t *testing.T) {
+	for _, mode := range server.Modes() {
+		t.Run(mode, func(t *testing.T) {
+			srv := start(t, mode, store.NewSharded(4, expiry.System))
+			testCASFlow(t, "tcp", srv.Addr())
+		})
+	}
+}
+
+// testCASFlow increments one key from many connections, using
+// read-modify-CAS & retrying on conflict, so that every increment lands
+// exactly once, as seen from final value
+func testCASFlow(t *testing.T, proto string, addr string) {
+	const (
+		clients    = 8
+		increments = 25
+	)
+
+	conn, err := net.Dial(proto, addr)
+	if err != nil {
+		t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+	}
+	defer conn.Close()
+
+	key := op.Key("counter")
+	zero := op.Value("0")
+	if err := roundTrip(t, conn, &op.WriteRequest{Key: &key, Value: &zero}, new(op.Value)); err != nil {
+		t.Fatalf("Failed to write : %s\n", err.Error())
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		conn, err := net.Dial(proto, addr)
+		if err != nil {
+			t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+		}
+		defer conn.Close()
+
+		wg.Add(1)
+		go func(conn net.Conn) {
+			defer wg.Done()
+
+			for done := 0; done < increments; {
+				cur := new(op.VersionedValue)
+				if err := roundTrip(t, conn, &op.ReadVersionRequest{ReadRequest: op.ReadRequest{Key: &key}}, cur); err != nil {
+					t.Errorf("Failed to read : %s\n", err.Error())
+					return
+				}
+
+				n, _ := strconv.Atoi(string(cur.Value))
+				next := op.Value(strconv.Itoa(n + 1))
+				res := new(op.WriteResult)
+				if err := roundTrip(t, conn, &op.CASRequest{Key: &key, Value: &next, Version: cur.Version}, res); err != nil {
+					t.Errorf("Failed to CAS : %s\n", err.Error())
+					return
+				}
+
+				if res.Applied {
+					done++
+				}
+			}
+		}(conn)
+	}
+
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+
+	val := new(op.Value)
+	if err := roundTrip(t, conn, &op.ReadRequest{Key: &key}, val); err != nil || string(*val) != strconv.Itoa(clients*increments) {
+		t.Fatalf("Expected counter = %d, received `%s` [%v]\n", clients*increments, *val, err)
+	}
+}
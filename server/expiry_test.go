@@ -0,0 +1,129 @@
+package server_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/itzmeanjan/tseep/config"
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/server"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+func TestExpiry(t *testing.T) {
+	for _, mode := range server.Modes() {
+		t.Run(mode, func(t *testing.T) {
+			clock := expiry.NewManualClock(time.Unix(0, 0))
+			st := store.NewMap(clock)
+			srv := start(t, mode, st, config.WithReapInterval(time.Millisecond))
+			testExpiryFlow(t, "tcp", srv.Addr(), clock, st)
+		})
+	}
+}
+
+// testExpiryFlow checks expired keys are invisible right away, while
+// ones never accessed again are reclaimed by sweeper, which is seen from
+// keys left in store
+func testExpiryFlow(t *testing.T, proto string, addr string, clock *expiry.ManualClock, st store.Store) {
+	conn, err := net.Dial(proto, addr)
+	if err != nil {
+		t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+	}
+	defer func() {
+		conn.Close()
+	}()
+
+	key := op.Key("session")
+	val := op.Value("token")
+	checkTTL := func(found bool, ttl time.Duration) {
+		resp := new(op.TTLResponse)
+		if err := roundTrip(t, conn, &op.TTLRequest{Key: &key}, resp); err != nil {
+			t.Fatalf("Failed to read response : %s\n", err.Error())
+		}
+
+		if resp.Found != found || (found && resp.TTL != ttl) {
+			t.Fatalf("Expected TTL %s [found %v], received %s [found %v]\n", ttl, found, resp.TTL, resp.Found)
+		}
+	}
+
+	if err := roundTrip(t, conn, &op.WriteRequest{Key: &key, Value: &val, TTL: 10 * time.Second}, new(op.Value)); err != nil {
+		t.Fatalf("Failed to read response : %s\n", err.Error())
+	}
+
+	checkTTL(true, 10*time.Second)
+	clock.Advance(4 * time.Second)
+	checkTTL(true, 6*time.Second)
+
+	if err := roundTrip(t, conn, &op.PersistRequest{Key: &key}, new(op.Value)); err != nil {
+		t.Fatalf("Failed to persist : %s\n", err.Error())
+	}
+
+	checkTTL(true, op.NoExpiry)
+
+	if err := roundTrip(t, conn, &op.ExpireRequest{Key: &key, TTL: time.Second}, new(op.Value)); err != nil {
+		t.Fatalf("Failed to expire : %s\n", err.Error())
+	}
+
+	checkTTL(true, time.Second)
+	clock.Advance(time.Second)
+
+	// expired key is invisible right away, without waiting for sweeper
+	if err := roundTrip(t, conn, &op.ReadRequest{Key: &key}, new(op.Value)); !errors.Is(err, op.ErrNotFound) {
+		t.Fatalf("Expected to receive not found response, received %v\n", err)
+	}
+
+	checkTTL(false, 0)
+	if err := roundTrip(t, conn, &op.ExpireRequest{Key: &key, TTL: time.Second}, new(op.Value)); !errors.Is(err, op.ErrNotFound) {
+		t.Fatalf("Expected to receive not found response, received %v\n", err)
+	}
+
+	if err := roundTrip(t, conn, &op.PersistRequest{Key: &key}, new(op.Value)); !errors.Is(err, op.ErrNotFound) {
+		t.Fatalf("Expected to receive not found response, received %v\n", err)
+	}
+
+	// writing without TTL makes key persistent again
+	if err := roundTrip(t, conn, &op.WriteRequest{Key: &key, Value: &val, TTL: time.Second}, new(op.Value)); err != nil {
+		t.Fatalf("Failed to read response : %s\n", err.Error())
+	}
+
+	if err := roundTrip(t, conn, &op.WriteRequest{Key: &key, Value: &val}, new(op.Value)); err != nil {
+		t.Fatalf("Failed to read response : %s\n", err.Error())
+	}
+
+	checkTTL(true, op.NoExpiry)
+
+	// zero TTL makes key expire right away
+	if err := roundTrip(t, conn, &op.ExpireRequest{Key: &key}, new(op.Value)); err != nil {
+		t.Fatalf("Failed to expire : %s\n", err.Error())
+	}
+
+	checkTTL(false, 0)
+
+	// keys never accessed after expiring are reclaimed by sweeper
+	for i := 0; i < 64; i++ {
+		key := op.Key(fmt.Sprintf("session-%d", i))
+		if err := roundTrip(t, conn, &op.WriteRequest{Key: &key, Value: &val, TTL: time.Second}, new(op.Value)); err != nil {
+			t.Fatalf("Failed to read response : %s\n", err.Error())
+		}
+	}
+
+	clock.Advance(time.Second)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		left := st.Len()
+
+		if left == 0 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected expired keys to be reclaimed, %d keys left\n", left)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,143 @@
+package server_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/itzmeanjan/tseep/config"
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/server"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+func TestKeyspace(t *testing.T) {
+	for _, mode := range server.Modes() {
+		t.Run(mode, func(t *testing.T) {
+			clock := expiry.NewManualClock(time.Unix(0, 0))
+			srv := start(t, mode, store.NewSharded(4, clock), config.WithReapInterval(time.Millisecond))
+			testKeyspaceFlow(t, "tcp", srv.Addr(), clock)
+		})
+	}
+}
+
+// watch sends KSUBSCRIBE or KUNSUBSCRIBE, returning number of channels &
+// patterns connection is subscribed to afterwards
+func (s *subscribed) watch(t *testing.T, unsubscribe bool, patterns ...op.Pattern) uint32 {
+	resp := new(op.SubscribeResponse)
+	if err := roundTrip(t, s, &op.KeyspaceRequest{Patterns: patterns, Unsubscribe: unsubscribe}, resp); err != nil {
+		t.Fatalf("Failed to watch : %s\n", err.Error())
+	}
+
+	return resp.Count
+}
+
+// next reads pushed event, checking it's what's expected
+func (s *subscribed) next(t *testing.T, key op.Key, kind op.EventKind) *op.Event {
+	s.SetReadDeadline(time.Now().Add(5 * time.Second))
+	defer s.SetReadDeadline(time.Time{})
+
+	ev := new(op.Event)
+	if _, err := ev.ReadFrom(s); err != nil || ev.Key != key || ev.Kind != kind {
+		t.Fatalf("Expected `%s` to be %s, received %+v [%v]\n", key, kind, ev, err)
+	}
+
+	return ev
+}
+
+func readVersion(t *testing.T, conn net.Conn, key op.Key) uint64 {
+	resp := new(op.VersionedValue)
+	if err := roundTrip(t, conn, &op.ReadVersionRequest{ReadRequest: op.ReadRequest{Key: &key}}, resp); err != nil || !resp.Found {
+		t.Fatalf("Failed to read version of `%s` : %v\n", key, err)
+	}
+
+	return resp.Version
+}
+
+// testKeyspaceFlow watches a key & a prefix, while another connection
+// writes, deletes & lets keys expire. Events arrive in order they're
+// applied, carrying versions seen by GETV.
+func testKeyspaceFlow(t *testing.T, proto string, addr string, clock *expiry.ManualClock) {
+	watcher := dialSubscribed(t, proto, addr)
+	defer watcher.Close()
+
+	conn, err := net.Dial(proto, addr)
+	if err != nil {
+		t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+	}
+	defer conn.Close()
+
+	if n := watcher.watch(t, false, op.Pattern{Key: "config/app"}, op.Pattern{Key: "feature/", Prefix: true}); n != 2 {
+		t.Fatalf("Expected 2 subscriptions, found %d\n", n)
+	}
+
+	write := func(key op.Key, ttl time.Duration) {
+		val := op.Value("on")
+		if err := roundTrip(t, conn, &op.WriteRequest{Key: &key, Value: &val, TTL: ttl}, new(op.Value)); err != nil {
+			t.Fatalf("Failed to write `%s` : %s\n", key, err.Error())
+		}
+	}
+
+	// unwatched keys aren't pushed
+	write("config/db", 0)
+	write("config/app", 0)
+	version := readVersion(t, conn, "config/app")
+	if ev := watcher.next(t, "config/app", op.KeyWritten); ev.Version != version {
+		t.Fatalf("Expected version %d, received %d\n", version, ev.Version)
+	}
+
+	key := op.Key("config/app")
+	if err := roundTrip(t, conn, &op.DeleteRequest{Key: &key}, new(op.Value)); err != nil {
+		t.Fatalf("Failed to delete : %s\n", err.Error())
+	}
+
+	if ev := watcher.next(t, "config/app", op.KeyDeleted); ev.Version <= version {
+		t.Fatalf("Expected version after %d, received %d\n", version, ev.Version)
+	}
+
+	// deleting missing key changes nothing
+	if err := roundTrip(t, conn, &op.DeleteRequest{Key: &key}, new(op.Value)); !errors.Is(err, op.ErrNotFound) {
+		t.Fatalf("Expected to receive not found response, received %v\n", err)
+	}
+
+	write("feature/dark-mode", time.Second)
+	watcher.next(t, "feature/dark-mode", op.KeyWritten)
+
+	// expired key is reported, once sweeper reclaims it
+	clock.Advance(time.Second)
+	watcher.next(t, "feature/dark-mode", op.KeyExpired)
+
+	// so is key made to expire right away by zero TTL
+	write("feature/dark-mode", 0)
+	watcher.next(t, "feature/dark-mode", op.KeyWritten)
+	dark := op.Key("feature/dark-mode")
+	if err := roundTrip(t, conn, &op.ExpireRequest{Key: &dark}, new(op.Value)); err != nil {
+		t.Fatalf("Failed to expire : %s\n", err.Error())
+	}
+
+	watcher.next(t, "feature/dark-mode", op.KeyExpired)
+
+	// only subscriptions are taken in push mode
+	var opErr *op.Error
+	if err := roundTrip(t, watcher, &op.ReadRequest{Key: &key}, new(op.Value)); !errors.As(err, &opErr) || opErr.Code != op.NotAllowed {
+		t.Fatalf("Expected READ to be rejected in push mode, received %v\n", err)
+	}
+
+	if n := watcher.watch(t, true, op.Pattern{Key: "feature/", Prefix: true}); n != 1 {
+		t.Fatalf("Expected 1 subscription left, found %d\n", n)
+	}
+
+	write("feature/beta", 0)
+	write("config/app", 0)
+	watcher.next(t, "config/app", op.KeyWritten)
+
+	if n := watcher.watch(t, true); n != 0 {
+		t.Fatalf("Expected no subscription left, found %d\n", n)
+	}
+
+	if err := roundTrip(t, watcher, &op.ReadRequest{Key: &key}, new(op.Value)); err != nil {
+		t.Fatalf("Expected READ to be served, after leaving push mode, received %v\n", err)
+	}
+}
@@ -0,0 +1,106 @@
+package server_test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/itzmeanjan/tseep/config"
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/limit"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/server"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+func TestLimit(t *testing.T) {
+	for _, mode := range server.Modes() {
+		t.Run(mode, func(t *testing.T) {
+			srv := start(t, mode, store.NewMap(expiry.System), config.WithMaxConns(3, 2))
+			testLimitFlow(t, "tcp", srv.Addr())
+		})
+	}
+}
+
+// dialFrom dials server from given local IP, returning error frame, which
+// connection is rejected with, if it's not taken
+func dialFrom(t *testing.T, proto string, addr string, ip string) (net.Conn, error) {
+	dialer := net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP(ip)}}
+	conn, err := dialer.Dial(proto, addr)
+	if err != nil {
+		t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+	}
+
+	// taken connection is sent nothing, till it sends request
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, err = new(op.Value).ReadFrom(conn)
+	conn.SetReadDeadline(time.Time{})
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return conn, nil
+	}
+
+	conn.Close()
+	return nil, err
+}
+
+// testLimitFlow rejects connections over total & per IP limits, with an
+// error frame, while slots of closed connections are taken again
+func testLimitFlow(t *testing.T, proto string, addr string) {
+	expectRejected := func(ip string, expected *op.Error) {
+		conn, err := dialFrom(t, proto, addr, ip)
+		var opErr *op.Error
+		if conn != nil || !errors.As(err, &opErr) || *opErr != *expected {
+			t.Fatalf("Expected connection from %s to be rejected with %v, received %v\n", ip, expected, err)
+		}
+	}
+
+	conns := make([]net.Conn, 0, 3)
+	for _, ip := range []string{"127.0.0.1", "127.0.0.1", "127.0.0.2"} {
+		conn, err := dialFrom(t, proto, addr, ip)
+		if err != nil {
+			t.Fatalf("Expected connection from %s to be taken, received %v\n", ip, err)
+		}
+		defer conn.Close()
+
+		conns = append(conns, conn)
+	}
+
+	expectRejected("127.0.0.1", limit.ErrTooManyConnsFromIP)
+	expectRejected("127.0.0.2", limit.ErrTooManyConns)
+
+	// taken connections are served
+	for _, conn := range conns {
+		if err := roundTrip(t, conn, &op.StatsRequest{}, new(op.StatsResponse)); err != nil {
+			t.Fatalf("Failed to read stats : %s\n", err.Error())
+		}
+	}
+
+	// slot is given back, once server notices connection is closed
+	conns[0].Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := dialFrom(t, proto, addr, "127.0.0.1")
+		if err == nil {
+			defer conn.Close()
+			conns[0] = conn
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected slot of closed connection to be taken, received %v\n", err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stats := new(op.StatsResponse)
+	if err := roundTrip(t, conns[0], &op.StatsRequest{}, stats); err != nil {
+		t.Fatalf("Failed to read stats : %s\n", err.Error())
+	}
+
+	if n, _ := stats.Get("rejected_connections"); n < 2 {
+		t.Fatalf("Expected at least 2 rejected connections, found %d\n", n)
+	}
+}
@@ -0,0 +1,288 @@
+package server_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/server"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+func TestList(t *testing.T) {
+	for _, mode := range server.Modes() {
+		t.Run(mode, func(t *testing.T) {
+			srv := start(t, mode, store.NewMap(expiry.System))
+			testListFlow(t, "tcp", srv.Addr())
+			testBlockingPop(t, "tcp", srv.Addr())
+			testParkedClose(t, "tcp", srv.Addr())
+		})
+	}
+}
+
+func push(t *testing.T, conn net.Conn, key op.Key, left bool, values ...string) uint32 {
+	req := &op.PushRequest{ReadRequest: op.ReadRequest{Key: &key}, Left: left}
+	for _, v := range values {
+		req.Values = append(req.Values, op.Value(v))
+	}
+
+	resp := new(op.LenResponse)
+	if err := roundTrip(t, conn, req, resp); err != nil {
+		t.Fatalf("Failed to push : %s\n", err.Error())
+	}
+
+	return resp.Len
+}
+
+// pop pops head or tail of list, returning empty string for missing key
+func pop(t *testing.T, conn net.Conn, key op.Key, left bool) string {
+	val := new(op.Value)
+	err := roundTrip(t, conn, &op.PopRequest{ReadRequest: op.ReadRequest{Key: &key}, Left: left}, val)
+	if errors.Is(err, op.ErrNotFound) {
+		return ""
+	}
+
+	if err != nil {
+		t.Fatalf("Failed to pop : %s\n", err.Error())
+	}
+
+	return string(*val)
+}
+
+func lrange(t *testing.T, conn net.Conn, key op.Key, start, stop int64) string {
+	resp := new(op.ListResponse)
+	if err := roundTrip(t, conn, &op.RangeRequest{ReadRequest: op.ReadRequest{Key: &key}, Start: start, Stop: stop}, resp); err != nil {
+		t.Fatalf("Failed to read range : %s\n", err.Error())
+	}
+
+	return fmt.Sprintf("%s", resp.Items)
+}
+
+func expectWrongType(t *testing.T, err error) {
+	t.Helper()
+
+	var opErr *op.Error
+	if !errors.As(err, &opErr) || opErr.Code != op.WrongType {
+		t.Fatalf("Expected wrong type error, received %v\n", err)
+	}
+}
+
+// testListFlow pushes to & pops from both ends of list, which is deleted
+// once empty, while list & byte string values don't mix
+func testListFlow(t *testing.T, proto string, addr string) {
+	conn, err := net.Dial(proto, addr)
+	if err != nil {
+		t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+	}
+	defer conn.Close()
+
+	if n := push(t, conn, "jobs", false, "a", "b"); n != 2 {
+		t.Fatalf("Expected 2 items, found %d\n", n)
+	}
+
+	if n := push(t, conn, "jobs", true, "x", "y"); n != 4 {
+		t.Fatalf("Expected 4 items, found %d\n", n)
+	}
+
+	if items := lrange(t, conn, "jobs", 0, -1); items != "[y x a b]" {
+		t.Fatalf("Expected [y x a b], found %s\n", items)
+	}
+
+	if items := lrange(t, conn, "jobs", -2, 100); items != "[a b]" {
+		t.Fatalf("Expected [a b], found %s\n", items)
+	}
+
+	if items := lrange(t, conn, "missing", 0, -1); items != "[]" {
+		t.Fatalf("Expected no items, found %s\n", items)
+	}
+
+	for _, key := range []op.Key{"jobs", "missing"} {
+		key := key
+		resp := new(op.LenResponse)
+		if err := roundTrip(t, conn, &op.LenRequest{ReadRequest: op.ReadRequest{Key: &key}}, resp); err != nil {
+			t.Fatalf("Failed to read length : %s\n", err.Error())
+		}
+
+		if expected := map[op.Key]uint32{"jobs": 4}[key]; resp.Len != expected {
+			t.Fatalf("Expected `%s` to have %d items, found %d\n", key, expected, resp.Len)
+		}
+	}
+
+	for _, expected := range []struct {
+		left bool
+		val  string
+	}{{true, "y"}, {false, "b"}, {false, "a"}, {true, "x"}, {false, ""}} {
+		if val := pop(t, conn, "jobs", expected.left); val != expected.val {
+			t.Fatalf("Expected to pop `%s`, received `%s`\n", expected.val, val)
+		}
+	}
+
+	key := op.Key("jobs")
+	if err := roundTrip(t, conn, &op.ReadRequest{Key: &key}, new(op.Value)); !errors.Is(err, op.ErrNotFound) {
+		t.Fatalf("Expected empty list to be deleted, received %v\n", err)
+	}
+
+	// list & byte string requests reject each other's keys
+	push(t, conn, "jobs", false, "a")
+	expectWrongType(t, roundTrip(t, conn, &op.ReadRequest{Key: &key}, new(op.Value)))
+	expectWrongType(t, roundTrip(t, conn, &op.IncrRequest{Key: &key, Delta: 1}, new(op.IncrResponse)))
+
+	mget := new(op.MGetResponse)
+	if err := roundTrip(t, conn, &op.MGetRequest{Keys: []op.Key{key}}, mget); err != nil || mget.Lookups[0].Found {
+		t.Fatalf("Expected list to be missing for MGET, received %+v [%v]\n", mget, err)
+	}
+
+	val := op.Value("v")
+	if err := roundTrip(t, conn, &op.WriteRequest{Key: &key, Value: &val}, new(op.Value)); err != nil {
+		t.Fatalf("Failed to write : %s\n", err.Error())
+	}
+
+	expectWrongType(t, roundTrip(t, conn, &op.PushRequest{ReadRequest: op.ReadRequest{Key: &key}, Values: []op.Value{val}}, new(op.LenResponse)))
+	expectWrongType(t, roundTrip(t, conn, &op.PopRequest{ReadRequest: op.ReadRequest{Key: &key}}, new(op.Value)))
+	expectWrongType(t, roundTrip(t, conn, &op.BlockingPopRequest{ReadRequest: op.ReadRequest{Key: &key}}, new(op.Value)))
+}
+
+// waitBlocked waits till n connections are parked by server
+func waitBlocked(t *testing.T, conn net.Conn, n uint64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stats := new(op.StatsResponse)
+		if err := roundTrip(t, conn, &op.StatsRequest{}, stats); err != nil {
+			t.Fatalf("Failed to read stats : %s\n", err.Error())
+		}
+
+		blocked, _ := stats.Get("blocked_clients")
+		if blocked == n {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d blocked clients, found %d\n", n, blocked)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// testBlockingPop parks connections on empty list, which are served in
+// order they were parked, once values are pushed, or when their timeout
+// runs out. Requests pipelined after parked one wait for it, while
+// parked connections take up no goroutine of their own.
+func testBlockingPop(t *testing.T, proto string, addr string) {
+	conn, err := net.Dial(proto, addr)
+	if err != nil {
+		t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+	}
+	defer conn.Close()
+
+	key := op.Key("queue")
+	brpop := &op.BlockingPopRequest{ReadRequest: op.ReadRequest{Key: &key}, Timeout: 100 * time.Millisecond}
+	start := time.Now()
+	if err := roundTrip(t, conn, brpop, new(op.Value)); !errors.Is(err, op.ErrNotFound) {
+		t.Fatalf("Expected timeout to run out, received %v\n", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < brpop.Timeout {
+		t.Fatalf("Expected to wait for %s, returned after %s\n", brpop.Timeout, elapsed)
+	}
+
+	push(t, conn, key, false, "ready")
+	if val := new(op.Value); roundTrip(t, conn, brpop, val) != nil || string(*val) != "ready" {
+		t.Fatalf("Expected to pop `ready` right away, received `%s`\n", *val)
+	}
+
+	// without timeout, connection waits till value is pushed
+	brpop.Timeout = 0
+	send := func(conn net.Conn, req request) {
+		if _, err := req.WriteEnvelope(conn); err != nil {
+			t.Fatalf("Failed to write request envelope : %s\n", err.Error())
+		}
+
+		if _, err := req.WriteTo(conn); err != nil {
+			t.Fatalf("Failed to write request body : %s\n", err.Error())
+		}
+	}
+
+	workers := make([]net.Conn, 16)
+	for i := range workers {
+		worker, err := net.Dial(proto, addr)
+		if err != nil {
+			t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+		}
+		defer worker.Close()
+
+		workers[i] = worker
+	}
+
+	// connections are established, before goroutines are counted
+	waitBlocked(t, conn, 0)
+	goroutines := runtime.NumGoroutine()
+
+	for i, worker := range workers {
+		send(worker, brpop)
+		send(worker, &op.ReadRequest{Key: &key})
+		waitBlocked(t, conn, uint64(i+1))
+	}
+
+	if n := runtime.NumGoroutine(); n > goroutines+2 {
+		t.Fatalf("Expected parked connections to take no goroutine, found %d more\n", n-goroutines)
+	}
+
+	// oldest parked connection gets value pushed first
+	for i, worker := range workers {
+		push(t, conn, key, true, fmt.Sprint(i))
+
+		worker.SetReadDeadline(time.Now().Add(5 * time.Second))
+		val := new(op.Value)
+		if _, err := val.ReadFrom(worker); err != nil || string(*val) != fmt.Sprint(i) {
+			t.Fatalf("Expected worker %d to pop `%d`, received `%s` [%v]\n", i, i, *val, err)
+		}
+
+		if _, err := new(op.Value).ReadFrom(worker); !errors.Is(err, op.ErrNotFound) {
+			t.Fatalf("Expected pipelined READ to be served after pop, received %v\n", err)
+		}
+	}
+
+	waitBlocked(t, conn, 0)
+}
+
+// testParkedClose closes connection, while it's parked, which server
+// notices without anything being pushed, so that value pushed next is
+// kept, instead of being popped for connection, which is gone
+func testParkedClose(t *testing.T, proto string, addr string) {
+	conn, err := net.Dial(proto, addr)
+	if err != nil {
+		t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+	}
+	defer conn.Close()
+
+	parked, err := net.Dial(proto, addr)
+	if err != nil {
+		t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+	}
+
+	key := op.Key("abandoned")
+	brpop := &op.BlockingPopRequest{ReadRequest: op.ReadRequest{Key: &key}}
+	if _, err := brpop.WriteEnvelope(parked); err != nil {
+		t.Fatalf("Failed to write request envelope : %s\n", err.Error())
+	}
+
+	if _, err := brpop.WriteTo(parked); err != nil {
+		t.Fatalf("Failed to write request body : %s\n", err.Error())
+	}
+
+	waitBlocked(t, conn, 1)
+	parked.Close()
+	waitBlocked(t, conn, 0)
+
+	push(t, conn, key, false, "kept")
+	resp := new(op.LenResponse)
+	if err := roundTrip(t, conn, &op.LenRequest{ReadRequest: op.ReadRequest{Key: &key}}, resp); err != nil || resp.Len != 1 {
+		t.Fatalf("Expected pushed value to be kept, found %d items [%v]\n", resp.Len, err)
+	}
+}
@@ -0,0 +1,199 @@
+package server_test
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/itzmeanjan/tseep/config"
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/pubsub"
+	"github.com/itzmeanjan/tseep/server"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+func TestPubSub(t *testing.T) {
+	for _, mode := range server.Modes() {
+		t.Run(mode, func(t *testing.T) {
+			srv := start(t, mode, store.NewMap(expiry.System))
+			testPubSubFlow(t, "tcp", srv.Addr())
+
+			for _, policy := range []pubsub.Policy{pubsub.DropMessage, pubsub.Disconnect} {
+				srv := start(t, mode, store.NewMap(expiry.System), config.WithPushQueue(4, policy))
+				testSlowSubscriber(t, "tcp", srv.Addr(), policy)
+			}
+		})
+	}
+}
+
+// subscribed wraps connection in push mode, whose responses may come
+// after messages
+type subscribed struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (s *subscribed) Read(p []byte) (int, error) {
+	return s.r.Read(p)
+}
+
+// subscribe sends SUBSCRIBE or UNSUBSCRIBE, returning number of channels
+// connection is subscribed to afterwards
+func (s *subscribed) subscribe(t *testing.T, unsubscribe bool, channels ...op.Key) uint32 {
+	resp := new(op.SubscribeResponse)
+	if err := roundTrip(t, s, &op.SubscribeRequest{Channels: channels, Unsubscribe: unsubscribe}, resp); err != nil {
+		t.Fatalf("Failed to subscribe : %s\n", err.Error())
+	}
+
+	return resp.Count
+}
+
+func publish(t *testing.T, conn net.Conn, channel op.Key, msg op.Value) uint32 {
+	resp := new(op.PublishResponse)
+	if err := roundTrip(t, conn, &op.PublishRequest{Channel: &channel, Message: &msg}, resp); err != nil {
+		t.Fatalf("Failed to publish : %s\n", err.Error())
+	}
+
+	return resp.Receivers
+}
+
+func dialSubscribed(t *testing.T, proto string, addr string) *subscribed {
+	conn, err := net.Dial(proto, addr)
+	if err != nil {
+		t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+	}
+
+	return &subscribed{Conn: conn, r: bufio.NewReader(conn)}
+}
+
+// testPubSubFlow fans messages out to subscribers, in order they're
+// published, while subscribed connection only takes subscriptions
+func testPubSubFlow(t *testing.T, proto string, addr string) {
+	subs := []*subscribed{dialSubscribed(t, proto, addr), dialSubscribed(t, proto, addr)}
+	for _, sub := range subs {
+		defer sub.Close()
+	}
+
+	pub, err := net.Dial(proto, addr)
+	if err != nil {
+		t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+	}
+	defer pub.Close()
+
+	if n := subs[0].subscribe(t, false, "news", "sports"); n != 2 {
+		t.Fatalf("Expected 2 subscriptions, found %d\n", n)
+	}
+
+	if n := subs[1].subscribe(t, false, "news"); n != 1 {
+		t.Fatalf("Expected 1 subscription, found %d\n", n)
+	}
+
+	for i := 0; i < 100; i++ {
+		if n := publish(t, pub, "news", op.Value(fmt.Sprint(i))); n != 2 {
+			t.Fatalf("Expected 2 receivers, found %d\n", n)
+		}
+	}
+
+	if n := publish(t, pub, "sports", op.Value("goal")); n != 1 {
+		t.Fatalf("Expected 1 receiver, found %d\n", n)
+	}
+
+	for i, sub := range subs {
+		for j := 0; j < 100; j++ {
+			msg := new(op.Message)
+			if _, err := msg.ReadFrom(sub); err != nil || msg.Channel != "news" || string(msg.Payload) != fmt.Sprint(j) {
+				t.Fatalf("Expected message #%d on `news`, received %+v [%v]\n", j, msg, err)
+			}
+		}
+
+		if i != 0 {
+			continue
+		}
+
+		msg := new(op.Message)
+		if _, err := msg.ReadFrom(sub); err != nil || msg.Channel != "sports" {
+			t.Fatalf("Expected message on `sports`, received %+v [%v]\n", msg, err)
+		}
+	}
+
+	// only subscriptions are taken in push mode
+	key := op.Key("hello")
+	var opErr *op.Error
+	if err := roundTrip(t, subs[0], &op.ReadRequest{Key: &key}, new(op.Value)); !errors.As(err, &opErr) || opErr.Code != op.NotAllowed {
+		t.Fatalf("Expected READ to be rejected in push mode, received %v\n", err)
+	}
+
+	if n := subs[0].subscribe(t, true, "sports"); n != 1 {
+		t.Fatalf("Expected 1 subscription left, found %d\n", n)
+	}
+
+	if n := subs[0].subscribe(t, true); n != 0 {
+		t.Fatalf("Expected no subscription left, found %d\n", n)
+	}
+
+	if err := roundTrip(t, subs[0], &op.ReadRequest{Key: &key}, new(op.Value)); !errors.Is(err, op.ErrNotFound) {
+		t.Fatalf("Expected READ to be served, after leaving push mode, received %v\n", err)
+	}
+
+	if n := publish(t, pub, "news", op.Value("bye")); n != 1 {
+		t.Fatalf("Expected 1 receiver, found %d\n", n)
+	}
+}
+
+// testSlowSubscriber publishes to subscriber, which never reads, till
+// its queue fills up. Messages are then dropped or subscriber gets
+// disconnected, while server keeps serving others.
+func testSlowSubscriber(t *testing.T, proto string, addr string, policy pubsub.Policy) {
+	slow := dialSubscribed(t, proto, addr)
+	defer slow.Close()
+
+	pub, err := net.Dial(proto, addr)
+	if err != nil {
+		t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+	}
+	defer pub.Close()
+
+	slow.subscribe(t, false, "news")
+
+	// large messages fill socket buffers, after which they queue up
+	payload := op.Value(make([]byte, 1<<16))
+	dropped := false
+	for i := 0; i < 1024 && !dropped; i++ {
+		dropped = publish(t, pub, "news", payload) == 0
+	}
+
+	if !dropped {
+		t.Fatalf("[%s] Expected messages to be dropped for slow subscriber\n", policy)
+	}
+
+	if n := publish(t, pub, "news", payload); policy == pubsub.Disconnect && n != 0 {
+		t.Fatalf("[%s] Expected disconnected subscriber to receive nothing, found %d receivers\n", policy, n)
+	}
+
+	// subscriber drains what's written to it, after which disconnected
+	// one finds connection closed
+	slow.SetReadDeadline(time.Now().Add(time.Second))
+	received := 0
+	for {
+		msg := new(op.Message)
+		if _, err := msg.ReadFrom(slow); err != nil {
+			var netErr net.Error
+			timedOut := errors.As(err, &netErr) && netErr.Timeout()
+			if timedOut == (policy == pubsub.Disconnect) {
+				t.Fatalf("[%s] Unexpected end of messages, after %d : %v\n", policy, received, err)
+			}
+
+			break
+		}
+
+		received++
+	}
+
+	if received == 0 {
+		t.Fatalf("[%s] Expected messages queued before being full to arrive\n", policy)
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/itzmeanjan/tseep/config"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+// Server is a running server, whichever way it does network I/O
+type Server interface {
+	// Addr returns address server is listening on
+	Addr() string
+	// Shutdown stops server gracefully, returning once all of its
+	// goroutines have exited
+	Shutdown(ctx context.Context) error
+	// Stats returns counters, also reported by STATS
+	Stats() []op.Counter
+}
+
+// Constructor starts server listening on addr, which serves requests
+// against st, till ctx is cancelled or it's shut down
+type Constructor func(ctx context.Context, proto string, addr string, st store.Store, opts ...config.Option) (Server, error)
+
+var (
+	lock  sync.RWMutex
+	modes = make(map[string]Constructor)
+)
+
+// Register makes constructor of server available by name of its mode.
+// It's called by package implementing server, when it's imported, so
+// importing it for side effects is enough to make mode available.
+// Registering same mode twice panics.
+func Register(mode string, ctor Constructor) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, ok := modes[mode]; ok {
+		panic(fmt.Sprintf("server mode `%s` registered twice", mode))
+	}
+
+	modes[mode] = ctor
+}
+
+// New starts server of given mode, which must be registered already
+func New(ctx context.Context, mode string, proto string, addr string, st store.Store, opts ...config.Option) (Server, error) {
+	lock.RLock()
+	ctor, ok := modes[mode]
+	lock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown server mode `%s`", mode)
+	}
+
+	return ctor(ctx, proto, addr, st, opts...)
+}
+
+// Modes returns registered modes, sorted by name
+func Modes() []string {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	names := make([]string, 0, len(modes))
+	for mode := range modes {
+		names = append(names, mode)
+	}
+
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,693 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/itzmeanjan/tseep/config"
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/server"
+	"github.com/itzmeanjan/tseep/store"
+	_ "github.com/itzmeanjan/tseep/v1"
+	_ "github.com/itzmeanjan/tseep/v2"
+	_ "github.com/itzmeanjan/tseep/v3"
+	_ "github.com/itzmeanjan/tseep/v4"
+)
+
+type request interface {
+	WriteEnvelope(io.Writer) (int64, error)
+	io.WriterTo
+}
+
+// roundTrip sends request & reads its response
+func roundTrip(t *testing.T, conn net.Conn, req request, resp io.ReaderFrom) error {
+	if _, err := req.WriteEnvelope(conn); err != nil {
+		t.Fatalf("Failed to write request envelope : %s\n", err.Error())
+	}
+
+	if _, err := req.WriteTo(conn); err != nil {
+		t.Fatalf("Failed to write request body : %s\n", err.Error())
+	}
+
+	_, err := resp.ReadFrom(conn)
+	return err
+}
+
+// start starts server of given mode, which is closed once test is over.
+// Modes running watchers or event loops run two of them.
+func start(t *testing.T, mode string, st store.Store, opts ...config.Option) server.Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	srv, err := server.New(ctx, mode, "tcp", "127.0.0.1:0", st, append([]config.Option{config.WithWatchers(2)}, opts...)...)
+	if err != nil {
+		t.Fatalf("Failed to start TCP server : %s\n", err.Error())
+	}
+
+	return srv
+}
+
+func TestRegistry(t *testing.T) {
+	// v4 runs epoll event loops, so it's built on linux only
+	expected := "[v1 v2 v3]"
+	if runtime.GOOS == "linux" {
+		expected = "[v1 v2 v3 v4]"
+	}
+
+	if modes := fmt.Sprint(server.Modes()); modes != expected {
+		t.Fatalf("Expected modes %s, found %s\n", expected, modes)
+	}
+
+	if _, err := server.New(context.Background(), "v0", "tcp", "127.0.0.1:0", store.NewMap(expiry.System)); err == nil {
+		t.Fatalf("Expected unknown mode to be rejected\n")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Expected registering mode twice to panic\n")
+		}
+	}()
+
+	server.Register("v1", nil)
+}
+
+// TestModes runs same flow against server of every mode, which is
+// started & shut down through common interface
+func TestModes(t *testing.T) {
+	for _, mode := range server.Modes() {
+		t.Run(mode, func(t *testing.T) {
+			goroutines := runtime.NumGoroutine()
+			srv, err := server.New(context.Background(), mode, "tcp", "127.0.0.1:0", store.NewMap(expiry.System), config.WithWatchers(2))
+			if err != nil {
+				t.Fatalf("Failed to start TCP server : %s\n", err.Error())
+			}
+
+			conn, err := net.Dial("tcp", srv.Addr())
+			if err != nil {
+				t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+			}
+			defer conn.Close()
+
+			for i := 0; i < 16; i++ {
+				key := op.Key(fmt.Sprintf("key-%d", i))
+				val := op.Value(mode)
+				resp := new(op.Value)
+				if err := roundTrip(t, conn, &op.WriteRequest{Key: &key, Value: &val}, resp); err != nil || string(*resp) != mode {
+					t.Fatalf("Expected to write `%s`, received `%s` [%v]\n", mode, *resp, err)
+				}
+			}
+
+			stats := &op.StatsResponse{Counters: srv.Stats()}
+			if n, _ := stats.Get("keys"); n != 16 {
+				t.Fatalf("Expected 16 keys, found %d\n", n)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			if err := srv.Shutdown(ctx); err != nil {
+				t.Fatalf("Expected graceful shutdown, received %v\n", err)
+			}
+
+			if _, err := net.Dial("tcp", srv.Addr()); err == nil {
+				t.Fatalf("Expected listener to be closed\n")
+			}
+
+			deadline := time.Now().Add(5 * time.Second)
+			for runtime.NumGoroutine() > goroutines {
+				if time.Now().After(deadline) {
+					t.Fatalf("Expected %d goroutines, found %d\n", goroutines, runtime.NumGoroutine())
+				}
+
+				time.Sleep(time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestServer(t *testing.T) {
+	for _, mode := range server.Modes() {
+		t.Run(mode, func(t *testing.T) {
+			srv := start(t, mode, store.NewMap(expiry.System))
+
+			testClientFlow(t, context.Background(), "tcp", srv.Addr())
+			testErrorFlow(t, "tcp", srv.Addr())
+			testFramingFlow(t, "tcp", srv.Addr())
+			testPipelineFlow(t, "tcp", srv.Addr())
+			testTaggedFlow(t, "tcp", srv.Addr())
+			testHalfCloseFlow(t, "tcp", srv.Addr())
+		})
+	}
+}
+
+func testClientFlow(t *testing.T, ctx context.Context, proto string, addr string) {
+	conn, err := net.Dial(proto, addr)
+	if err != nil {
+		t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+	}
+	defer func() {
+		conn.Close()
+	}()
+
+	key := op.Key("hello")
+	rReq := op.ReadRequest{Key: &key}
+	if _, err := rReq.WriteEnvelope(conn); err != nil {
+		t.Fatalf("Failed to write request envelope : %s\n", err.Error())
+	}
+
+	if _, err := rReq.WriteTo(conn); err != nil {
+		t.Fatalf("Failed to write request body : %s\n", err.Error())
+	}
+
+	resp := new(op.Value)
+	if _, err := resp.ReadFrom(conn); !errors.Is(err, op.ErrNotFound) {
+		t.Fatalf("Expected to receive not found response, received %v\n", err)
+	}
+
+	wVal := op.Value("world")
+	wReq := op.WriteRequest{Key: &key, Value: &wVal}
+	if _, err := wReq.WriteEnvelope(conn); err != nil {
+		t.Fatalf("Failed to write request envelope : %s\n", err.Error())
+	}
+
+	if _, err := wReq.WriteTo(conn); err != nil {
+		t.Fatalf("Failed to write request body : %s\n", err.Error())
+	}
+
+	if _, err := resp.ReadFrom(conn); err != nil {
+		t.Fatalf("Failed to read response : %s\n", err.Error())
+	}
+
+	if !bytes.Equal(*resp, wVal) {
+		t.Fatalf("Expected to receive `%s`, received `%s`\n", wVal, *resp)
+	}
+
+	if _, err := rReq.WriteEnvelope(conn); err != nil {
+		t.Fatalf("Failed to write request : %s\n", err.Error())
+	}
+
+	if _, err := rReq.WriteTo(conn); err != nil {
+		t.Fatalf("Failed to write request body : %s\n", err.Error())
+	}
+
+	if _, err := resp.ReadFrom(conn); err != nil {
+		t.Fatalf("Failed to read response : %s\n", err.Error())
+	}
+
+	if !bytes.Equal(wVal, *resp) {
+		t.Fatalf("Expected to receive `%s`, received `%s`\n", wVal, *resp)
+	}
+
+	dReq := op.DeleteRequest{Key: &key}
+	for _, existed := range []bool{true, false} {
+		if _, err := dReq.WriteEnvelope(conn); err != nil {
+			t.Fatalf("Failed to write request envelope : %s\n", err.Error())
+		}
+
+		if _, err := dReq.WriteTo(conn); err != nil {
+			t.Fatalf("Failed to write request body : %s\n", err.Error())
+		}
+
+		dResp := new(op.DeleteResponse)
+		if _, err := dResp.ReadFrom(conn); err != nil {
+			t.Fatalf("Failed to read response : %s\n", err.Error())
+		}
+
+		if dResp.Existed != existed {
+			t.Fatalf("Expected existed = %v, received %v\n", existed, dResp.Existed)
+		}
+	}
+
+	// empty value must be told apart from missing key
+	w := new(bytes.Buffer)
+	eVal := op.Value("")
+	eReq := op.WriteRequest{Key: &key, Value: &eVal}
+	if _, err := eReq.WriteEnvelope(w); err != nil {
+		t.Fatalf("Failed to write request envelope : %s\n", err.Error())
+	}
+
+	if _, err := eReq.WriteTo(w); err != nil {
+		t.Fatalf("Failed to write request body : %s\n", err.Error())
+	}
+
+	if _, err := rReq.WriteEnvelope(w); err != nil {
+		t.Fatalf("Failed to write request envelope : %s\n", err.Error())
+	}
+
+	if _, err := rReq.WriteTo(w); err != nil {
+		t.Fatalf("Failed to write request body : %s\n", err.Error())
+	}
+
+	if _, err := conn.Write(w.Bytes()); err != nil {
+		t.Fatalf("Failed to write request : %s\n", err.Error())
+	}
+
+	w.Reset()
+
+	for i := 0; i < 2; i++ {
+		if _, err := resp.ReadFrom(conn); err != nil {
+			t.Fatalf("Failed to read response : %s\n", err.Error())
+		}
+
+		if resp.Len() != 0 {
+			t.Fatalf("Expected to receive empty value, received `%s`\n", *resp)
+		}
+	}
+
+	dResp := new(op.DeleteResponse)
+	if _, err := dReq.WriteEnvelope(w); err != nil {
+		t.Fatalf("Failed to write request envelope : %s\n", err.Error())
+	}
+
+	if _, err := dReq.WriteTo(w); err != nil {
+		t.Fatalf("Failed to write request body : %s\n", err.Error())
+	}
+
+	if _, err := conn.Write(w.Bytes()); err != nil {
+		t.Fatalf("Failed to write request : %s\n", err.Error())
+	}
+
+	w.Reset()
+
+	if _, err := dResp.ReadFrom(conn); err != nil {
+		t.Fatalf("Failed to read response : %s\n", err.Error())
+	}
+
+	if !dResp.Existed {
+		t.Fatalf("Expected key with empty value to exist\n")
+	}
+}
+
+func testErrorFlow(t *testing.T, proto string, addr string) {
+	conn, err := net.Dial(proto, addr)
+	if err != nil {
+		t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+	}
+	defer func() {
+		conn.Close()
+	}()
+
+	frames := []struct {
+		raw  []byte
+		code op.ErrorCode
+	}{
+		{raw: []byte{63, 0, 2, 'h', 'i'}, code: op.BadOpcode},
+		{raw: []byte{byte(op.READ), 0, 3, 10, 'h', 'i'}, code: op.Malformed},
+		{raw: []byte{byte(op.DELETE), 0, 0}, code: op.Malformed},
+		{raw: []byte{byte(op.SAVE), 0, 0}, code: op.Internal},
+		{raw: []byte{128 | byte(op.READ), 0, 0, 0, 3, 0, 0, 9}, code: op.Malformed},
+	}
+
+	for _, frame := range frames {
+		if _, err := conn.Write(frame.raw); err != nil {
+			t.Fatalf("Failed to write request : %s\n", err.Error())
+		}
+
+		resp := new(op.Value)
+		_, err := resp.ReadFrom(conn)
+
+		var opErr *op.Error
+		if !errors.As(err, &opErr) {
+			t.Fatalf("Expected to receive error frame, received %v\n", err)
+		}
+
+		if opErr.Code != frame.code {
+			t.Fatalf("Expected error code `%s`, received `%s`\n", frame.code, opErr.Code)
+		}
+	}
+
+	// connection must still be usable after receiving error frames
+	key := op.Key("hello")
+	rReq := op.ReadRequest{Key: &key}
+	w := new(bytes.Buffer)
+	if _, err := rReq.WriteEnvelope(w); err != nil {
+		t.Fatalf("Failed to write request envelope : %s\n", err.Error())
+	}
+
+	if _, err := rReq.WriteTo(w); err != nil {
+		t.Fatalf("Failed to write request body : %s\n", err.Error())
+	}
+
+	if _, err := conn.Write(w.Bytes()); err != nil {
+		t.Fatalf("Failed to write request : %s\n", err.Error())
+	}
+
+	// key was deleted in client flow
+	resp := new(op.Value)
+	if _, err := resp.ReadFrom(conn); !errors.Is(err, op.ErrNotFound) {
+		t.Fatalf("Expected to receive not found response, received %v\n", err)
+	}
+
+	// body larger than max frame size is never read, so connection gets closed
+	if _, err := conn.Write([]byte{128 | byte(op.WRITE), 255, 255, 255, 255}); err != nil {
+		t.Fatalf("Failed to write request : %s\n", err.Error())
+	}
+
+	var opErr *op.Error
+	if _, err := resp.ReadFrom(conn); !errors.As(err, &opErr) || opErr.Code != op.TooLarge {
+		t.Fatalf("Expected to receive too large error frame, received %v\n", err)
+	}
+
+	if _, err := resp.ReadFrom(conn); !errors.Is(err, io.EOF) {
+		t.Fatalf("Expected connection to be closed, received %v\n", err)
+	}
+}
+
+func testFramingFlow(t *testing.T, proto string, addr string) {
+	conn, err := net.Dial(proto, addr)
+	if err != nil {
+		t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+	}
+	defer func() {
+		conn.Close()
+	}()
+
+	for _, hdr := range []op.Header{{Legacy: true}, {Legacy: false}} {
+		size := 1 << 17
+		if hdr.Legacy {
+			size = 255
+		}
+
+		key := op.Key(fmt.Sprintf("framing-%v", hdr.Legacy))
+		val := op.Value(bytes.Repeat([]byte{'v'}, size))
+		wReq := op.WriteRequest{Header: hdr, Key: &key, Value: &val}
+		rReq := op.ReadRequest{Header: hdr, Key: &key}
+
+		w := new(bytes.Buffer)
+		if _, err := wReq.WriteEnvelope(w); err != nil {
+			t.Fatalf("Failed to write request envelope : %s\n", err.Error())
+		}
+
+		if _, err := wReq.WriteTo(w); err != nil {
+			t.Fatalf("Failed to write request body : %s\n", err.Error())
+		}
+
+		if _, err := rReq.WriteEnvelope(w); err != nil {
+			t.Fatalf("Failed to write request envelope : %s\n", err.Error())
+		}
+
+		if _, err := rReq.WriteTo(w); err != nil {
+			t.Fatalf("Failed to write request body : %s\n", err.Error())
+		}
+
+		if _, err := conn.Write(w.Bytes()); err != nil {
+			t.Fatalf("Failed to write request : %s\n", err.Error())
+		}
+
+		for i := 0; i < 2; i++ {
+			resp := new(op.Value)
+			if _, err := resp.ReadFrom(conn); err != nil {
+				t.Fatalf("Failed to read response : %s\n", err.Error())
+			}
+
+			if !bytes.Equal(*resp, val) {
+				t.Fatalf("Expected to receive %d bytes value, received %d bytes\n", len(val), len(*resp))
+			}
+		}
+	}
+}
+
+func testPipelineFlow(t *testing.T, proto string, addr string) {
+	conn, err := net.Dial(proto, addr)
+	if err != nil {
+		t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+	}
+	defer func() {
+		conn.Close()
+	}()
+
+	count := 256
+	vals := make([]op.Value, 0, count)
+	w := new(bytes.Buffer)
+	for i := 0; i < count; i++ {
+		hdr := op.Header{Legacy: i%2 == 0}
+		key := op.Key(fmt.Sprintf("pipeline-%d", i))
+		val := op.Value(fmt.Sprintf("%d", i))
+		wReq := op.WriteRequest{Header: hdr, Key: &key, Value: &val}
+		rReq := op.ReadRequest{Header: hdr, Key: &key}
+
+		if _, err := wReq.WriteEnvelope(w); err != nil {
+			t.Fatalf("Failed to write request envelope : %s\n", err.Error())
+		}
+
+		if _, err := wReq.WriteTo(w); err != nil {
+			t.Fatalf("Failed to write request body : %s\n", err.Error())
+		}
+
+		if _, err := rReq.WriteEnvelope(w); err != nil {
+			t.Fatalf("Failed to write request envelope : %s\n", err.Error())
+		}
+
+		if _, err := rReq.WriteTo(w); err != nil {
+			t.Fatalf("Failed to write request body : %s\n", err.Error())
+		}
+
+		vals = append(vals, val)
+	}
+
+	// all requests are sent before reading any response, once in single
+	// write & then byte by byte, so that frames get split across reads
+	for _, chunk := range []int{w.Len(), 1} {
+		go func(stream []byte) {
+			for len(stream) > 0 {
+				n := chunk
+				if n > len(stream) {
+					n = len(stream)
+				}
+
+				if _, err := conn.Write(stream[:n]); err != nil {
+					return
+				}
+
+				stream = stream[n:]
+			}
+		}(w.Bytes())
+
+		for _, val := range vals {
+			for i := 0; i < 2; i++ {
+				resp := new(op.Value)
+				if _, err := resp.ReadFrom(conn); err != nil {
+					t.Fatalf("Failed to read response : %s\n", err.Error())
+				}
+
+				if !bytes.Equal(*resp, val) {
+					t.Fatalf("Expected to receive `%s`, received `%s`\n", val, *resp)
+				}
+			}
+		}
+	}
+}
+
+// testHalfCloseFlow sends requests, whose responses don't fit in socket
+// buffers, & shuts down writing side right away. All responses are still
+// received, before server closes connection.
+func testHalfCloseFlow(t *testing.T, proto string, addr string) {
+	conn, err := net.Dial(proto, addr)
+	if err != nil {
+		t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+	}
+	defer conn.Close()
+
+	key := op.Key("half-close")
+	val := op.Value(bytes.Repeat([]byte{'x'}, 1<<18))
+	if err := roundTrip(t, conn, &op.WriteRequest{Key: &key, Value: &val}, new(op.Value)); err != nil {
+		t.Fatalf("Failed to write : %s\n", err.Error())
+	}
+
+	count := 32
+	w := new(bytes.Buffer)
+	for i := 0; i < count; i++ {
+		rReq := op.ReadRequest{Key: &key}
+		if _, err := rReq.WriteEnvelope(w); err != nil {
+			t.Fatalf("Failed to write request envelope : %s\n", err.Error())
+		}
+
+		if _, err := rReq.WriteTo(w); err != nil {
+			t.Fatalf("Failed to write request body : %s\n", err.Error())
+		}
+	}
+
+	if _, err := conn.Write(w.Bytes()); err != nil {
+		t.Fatalf("Failed to write requests : %s\n", err.Error())
+	}
+
+	if err := conn.(*net.TCPConn).CloseWrite(); err != nil {
+		t.Fatalf("Failed to shut down writing side : %s\n", err.Error())
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for i := 0; i < count; i++ {
+		resp := new(op.Value)
+		if _, err := resp.ReadFrom(conn); err != nil {
+			t.Fatalf("Failed to read response %d : %s\n", i, err.Error())
+		}
+
+		if !bytes.Equal(*resp, val) {
+			t.Fatalf("Expected to receive value of %d bytes, received %d bytes\n", len(val), len(*resp))
+		}
+	}
+
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("Expected connection to be closed, received %v\n", err)
+	}
+}
+
+func testTaggedFlow(t *testing.T, proto string, addr string) {
+	conn, err := net.Dial(proto, addr)
+	if err != nil {
+		t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+	}
+	defer func() {
+		conn.Close()
+	}()
+
+	count := 64
+	keys := make([]op.Key, 0, count)
+	for i := 0; i < count; i++ {
+		keys = append(keys, op.Key(fmt.Sprintf("tagged-%d", i)))
+	}
+
+	// reads are sent only after all writes are answered, because tagged
+	// requests may be served in any order
+	for _, write := range []bool{true, false} {
+		w := new(bytes.Buffer)
+		for i := range keys {
+			hdr := op.Header{Legacy: i%2 == 0, Tagged: true, ID: uint32(i)}
+			val := op.Value(keys[i])
+
+			var req interface {
+				WriteEnvelope(io.Writer) (int64, error)
+				io.WriterTo
+			} = &op.ReadRequest{Header: hdr, Key: &keys[i]}
+			if write {
+				req = &op.WriteRequest{Header: hdr, Key: &keys[i], Value: &val}
+			}
+
+			if _, err := req.WriteEnvelope(w); err != nil {
+				t.Fatalf("Failed to write request envelope : %s\n", err.Error())
+			}
+
+			if _, err := req.WriteTo(w); err != nil {
+				t.Fatalf("Failed to write request body : %s\n", err.Error())
+			}
+		}
+
+		if _, err := conn.Write(w.Bytes()); err != nil {
+			t.Fatalf("Failed to write request : %s\n", err.Error())
+		}
+
+		answered := make(map[uint32]bool)
+		for range keys {
+			resp := new(op.Value)
+			hdr, _, err := resp.ReadFrame(conn)
+			if err != nil {
+				t.Fatalf("Failed to read response : %s\n", err.Error())
+			}
+
+			if !hdr.Tagged || int(hdr.ID) >= count || answered[hdr.ID] {
+				t.Fatalf("Unexpected response header %+v\n", hdr)
+			}
+
+			if !bytes.Equal(*resp, []byte(keys[hdr.ID])) {
+				t.Fatalf("Expected to receive `%s`, received `%s`\n", keys[hdr.ID], *resp)
+			}
+
+			answered[hdr.ID] = true
+		}
+	}
+}
+
+func BenchmarkServer(b *testing.B) {
+	for _, mode := range server.Modes() {
+		b.Run(mode, func(b *testing.B) {
+			benchmarkServerNClients(b, mode, store.NewMap(expiry.System))
+		})
+	}
+}
+
+func BenchmarkServerSharded(b *testing.B) {
+	for _, mode := range server.Modes() {
+		b.Run(mode, func(b *testing.B) {
+			benchmarkServerNClients(b, mode, store.NewSharded(16, expiry.System))
+		})
+	}
+}
+
+func benchmarkServerNClients(b *testing.B, mode string, st store.Store) {
+	proto := "tcp"
+	addr := "127.0.0.1:0"
+	ctx, cancel := context.WithCancel(context.Background())
+	// modes running watchers or event loops run eight of them
+	srv, err := server.New(ctx, mode, proto, addr, st, config.WithWatchers(8))
+	if err != nil {
+		b.Fatalf("Failed to start TCP server : %s\n", err.Error())
+	}
+
+	b.ReportAllocs()
+	b.SetBytes(264 + 6 + 523 + 261)
+	b.ResetTimer()
+
+	b.RunParallel(func(p *testing.PB) {
+		d := net.Dialer{
+			Timeout:  10 * time.Second,
+			Deadline: time.Now().Add(20 * time.Second),
+		}
+		conn, err := d.DialContext(ctx, proto, srv.Addr())
+		if err != nil {
+			b.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+		}
+		defer func() {
+			conn.Close()
+		}()
+
+		for p.Next() {
+			benchmarkClientFlow(b, conn, 1+rand.Intn(255))
+		}
+	})
+
+	cancel()
+}
+
+func benchmarkClientFlow(b *testing.B, conn net.Conn, idx int) {
+	key := op.Key(fmt.Sprintf("%255d", idx))
+	rReq := op.ReadRequest{Key: &key}
+	if _, err := rReq.WriteEnvelope(conn); err != nil {
+		b.Errorf("Failed to write request envelope : %s\n", err.Error())
+	}
+
+	if _, err := rReq.WriteTo(conn); err != nil {
+		b.Errorf("Failed to write request body : %s\n", err.Error())
+	}
+
+	resp := new(op.Value)
+	if _, err := resp.ReadFrom(conn); err != nil && !errors.Is(err, op.ErrNotFound) {
+		b.Errorf("Failed to read response : %s\n", err.Error())
+	}
+
+	wVal := op.Value(fmt.Sprintf("%255d", idx))
+	wReq := op.WriteRequest{Key: &key, Value: &wVal}
+	if _, err := wReq.WriteEnvelope(conn); err != nil {
+		b.Errorf("Failed to write request envelope : %s\n", err.Error())
+	}
+
+	if _, err := wReq.WriteTo(conn); err != nil {
+		b.Errorf("Failed to write request body : %s\n", err.Error())
+	}
+
+	if _, err := resp.ReadFrom(conn); err != nil {
+		b.Errorf("Failed to read response : %s\n", err.Error())
+	}
+
+	if !bytes.Equal(*resp, wVal) {
+		b.Errorf("Expected to receive `%s`, received `%s`\n", wVal, *resp)
+	}
+}
@@ -0,0 +1,217 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/itzmeanjan/tseep/config"
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/server"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+// gatedStore holds up READ of `slow` key, till it's released, so that
+// request stays in flight
+type gatedStore struct {
+	store.Store
+	entered chan struct{}
+	release chan struct{}
+}
+
+func newGatedStore() *gatedStore {
+	return &gatedStore{Store: store.NewMap(expiry.System), entered: make(chan struct{}, 1), release: make(chan struct{})}
+}
+
+func (g *gatedStore) Get(key op.Key) (store.Entry, bool) {
+	if key == "slow" {
+		g.entered <- struct{}{}
+		<-g.release
+	}
+
+	return g.Store.Get(key)
+}
+
+func TestShutdown(t *testing.T) {
+	for _, mode := range server.Modes() {
+		t.Run(mode, func(t *testing.T) {
+			proto := "tcp"
+			addr := "127.0.0.1:0"
+
+			for _, expire := range []bool{false, true} {
+				goroutines := runtime.NumGoroutine()
+
+				st := newGatedStore()
+				srv, err := server.New(context.Background(), mode, proto, addr, st, config.WithWatchers(2))
+				if err != nil {
+					t.Fatalf("Failed to start TCP server : %s\n", err.Error())
+				}
+
+				testShutdownFlow(t, proto, srv.Addr(), srv.Shutdown, st, expire)
+				waitGoroutines(t, goroutines)
+			}
+
+			// cancelling context closes listener, without waiting for next
+			// client
+			goroutines := runtime.NumGoroutine()
+			ctx, cancel := context.WithCancel(context.Background())
+			srv, err := server.New(ctx, mode, proto, addr, store.NewMap(expiry.System), config.WithWatchers(2))
+			if err != nil {
+				t.Fatalf("Failed to start TCP server : %s\n", err.Error())
+			}
+
+			cancel()
+			waitGoroutines(t, goroutines)
+			if err := srv.Shutdown(context.Background()); !errors.Is(err, context.Canceled) {
+				t.Fatalf("Expected server to be closed by cancelled context, received %v\n", err)
+			}
+		})
+	}
+}
+
+// waitGoroutines waits till goroutines started after n were counted have
+// exited
+func waitGoroutines(t *testing.T, n int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > n {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			t.Fatalf("Expected %d goroutines, found %d\n%s\n", n, runtime.NumGoroutine(), buf[:runtime.Stack(buf, true)])
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// testShutdownFlow shuts server down, while one request is in flight &
+// rest of connections are idle, subscribed or parked. Listener is closed
+// right away, while request in flight is answered, unless shutdown
+// deadline runs out first, after which every connection is closed.
+func testShutdownFlow(t *testing.T, proto string, addr string, shutdown func(context.Context) error, st *gatedStore, expire bool) {
+	dial := func() net.Conn {
+		conn, err := net.Dial(proto, addr)
+		if err != nil {
+			t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+		}
+
+		return conn
+	}
+
+	idle := dial()
+	defer idle.Close()
+
+	push(t, idle, "queue", false, "a")
+
+	sub := dialSubscribed(t, proto, addr)
+	defer sub.Close()
+	sub.subscribe(t, false, "news")
+	publish(t, idle, "news", op.Value("hello"))
+	if _, err := new(op.Message).ReadFrom(sub); err != nil {
+		t.Fatalf("Failed to receive message : %s\n", err.Error())
+	}
+
+	parked := dial()
+	defer parked.Close()
+	empty := op.Key("empty")
+	brpop := &op.BlockingPopRequest{ReadRequest: op.ReadRequest{Key: &empty}}
+	if _, err := brpop.WriteEnvelope(parked); err != nil {
+		t.Fatalf("Failed to write request envelope : %s\n", err.Error())
+	}
+	if _, err := brpop.WriteTo(parked); err != nil {
+		t.Fatalf("Failed to write request body : %s\n", err.Error())
+	}
+	waitBlocked(t, idle, 1)
+
+	busy := dial()
+	defer busy.Close()
+	slow := op.Key("slow")
+	read := &op.ReadRequest{Key: &slow}
+	if _, err := read.WriteEnvelope(busy); err != nil {
+		t.Fatalf("Failed to write request envelope : %s\n", err.Error())
+	}
+	if _, err := read.WriteTo(busy); err != nil {
+		t.Fatalf("Failed to write request body : %s\n", err.Error())
+	}
+	<-st.entered
+
+	timeout := 5 * time.Second
+	if expire {
+		timeout = 50 * time.Millisecond
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- shutdown(ctx)
+	}()
+
+	// listener is closed, though request is still in flight
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial(proto, addr)
+		if err != nil {
+			break
+		}
+		conn.Close()
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected listener to be closed\n")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	if expire {
+		<-ctx.Done()
+		// deadline has run out, though shutdown waits for goroutine
+		// serving request
+		select {
+		case err := <-done:
+			t.Fatalf("Expected shutdown to wait for request in flight, returned %v\n", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	close(st.release)
+	select {
+	case err := <-done:
+		if expire && !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Expected shutdown deadline to run out, received %v\n", err)
+		}
+
+		if !expire && err != nil {
+			t.Fatalf("Expected graceful shutdown, received %v\n", err)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected shutdown to return\n")
+	}
+
+	if !expire {
+		if _, err := new(op.Value).ReadFrom(busy); !errors.Is(err, op.ErrNotFound) {
+			t.Fatalf("Expected request in flight to be answered, received %v\n", err)
+		}
+	}
+
+	// every connection is closed, without parked request being answered
+	for _, conn := range []net.Conn{idle, sub, parked, busy} {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, err := io.Copy(io.Discard, conn)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Fatalf("Expected connection to be closed\n")
+		}
+
+		// response in flight may have been written, before deadline ran out
+		if n != 0 && conn != busy {
+			t.Fatalf("Expected nothing more to be read, read %d bytes\n", n)
+		}
+	}
+}
@@ -1,45 +1,57 @@
+//go:build stress
 // +build stress
 
-package v1_test
+package server_test
 
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"testing"
 
+	"github.com/itzmeanjan/tseep/config"
+	"github.com/itzmeanjan/tseep/expiry"
 	"github.com/itzmeanjan/tseep/op"
-	v1 "github.com/itzmeanjan/tseep/v1"
+	"github.com/itzmeanjan/tseep/server"
+	"github.com/itzmeanjan/tseep/store"
 )
 
-func TestServerV1_Stress_1k(t *testing.T) {
+func TestStress_1k(t *testing.T) {
 	concurrentConnectionTest(t, 1<<10)
 }
 
-func TestServerV1_Stress_2k(t *testing.T) {
+func TestStress_2k(t *testing.T) {
 	concurrentConnectionTest(t, 1<<11)
 }
 
-func TestServerV1_Stress_4k(t *testing.T) {
+func TestStress_4k(t *testing.T) {
 	concurrentConnectionTest(t, 1<<12)
 }
 
-func TestServerV1_Stress_8k(t *testing.T) {
+func TestStress_8k(t *testing.T) {
 	concurrentConnectionTest(t, 1<<13)
 }
 
+// concurrentConnectionTest runs clients at once against server of every
+// mode
 func concurrentConnectionTest(t *testing.T, clientCount int) {
+	for _, mode := range server.Modes() {
+		t.Run(mode, func(t *testing.T) {
+			serveConcurrently(t, mode, clientCount)
+		})
+	}
+}
+
+func serveConcurrently(t *testing.T, mode string, clientCount int) {
 	proto := "tcp"
 	addr := "127.0.0.1:0"
 	ctx, cancel := context.WithCancel(context.Background())
-	server, err := v1.New(ctx, proto, addr)
+	srv, err := server.New(ctx, mode, proto, addr, store.NewMap(expiry.System), config.WithWatchers(4))
 	if err != nil {
 		t.Fatalf("Failed to start TCP server : %s\n", err.Error())
 	}
-	defer func() {
-		server.Listener.Close()
-	}()
 
 	report := make(chan struct{}, clientCount)
 	for i := 0; i < clientCount; i++ {
@@ -50,7 +62,7 @@ func concurrentConnectionTest(t *testing.T, clientCount int) {
 					report <- struct{}{}
 				}()
 
-				conn, err := net.Dial(proto, server.Addr)
+				conn, err := net.Dial(proto, srv.Addr())
 				if err != nil {
 					t.Logf("Failed to dial : %s\n", err.Error())
 					return
@@ -71,7 +83,7 @@ func concurrentConnectionTest(t *testing.T, clientCount int) {
 				}
 
 				resp := new(op.Value)
-				if _, err := resp.ReadFrom(conn); err != nil {
+				if _, err := resp.ReadFrom(conn); err != nil && !errors.Is(err, op.ErrNotFound) {
 					return
 				}
 
@@ -0,0 +1,126 @@
+package server_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itzmeanjan/tseep/config"
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/server"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+func TestTimeout(t *testing.T) {
+	for _, mode := range server.Modes() {
+		t.Run(mode, func(t *testing.T) {
+			srv := start(t, mode, store.NewMap(expiry.System), config.WithTimeouts(200*time.Millisecond, 100*time.Millisecond, 150*time.Millisecond))
+			testTimeoutFlow(t, "tcp", srv.Addr())
+		})
+	}
+}
+
+// expectClosed reads from connection, till server closes it, failing when
+// it's not closed within given duration
+func expectClosed(t *testing.T, conn net.Conn, within time.Duration) {
+	conn.SetReadDeadline(time.Now().Add(within))
+	if _, err := io.Copy(io.Discard, conn); errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Expected connection to be closed within %s\n", within)
+	}
+}
+
+// trickle writes bytes one at a time, till they're all written or
+// connection is closed
+func trickle(conn net.Conn, b []byte, every time.Duration) {
+	for i := range b {
+		if _, err := conn.Write(b[i : i+1]); err != nil {
+			return
+		}
+
+		time.Sleep(every)
+	}
+}
+
+// testTimeoutFlow closes connections, which are idle for too long, or
+// take too long sending envelope or body of request, even when sending
+// it a byte at a time. Active & subscribed connections are kept open.
+func testTimeoutFlow(t *testing.T, proto string, addr string) {
+	dial := func() net.Conn {
+		conn, err := net.Dial(proto, addr)
+		if err != nil {
+			t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+		}
+
+		return conn
+	}
+
+	idle := dial()
+	defer idle.Close()
+
+	start := time.Now()
+	expectClosed(t, idle, 2*time.Second)
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("Expected idle connection to be kept open for a while, closed after %s\n", elapsed)
+	}
+
+	key := op.Key(strings.Repeat("k", 64))
+	req := &op.ReadRequest{Header: op.Header{Tagged: true, ID: 1}, Key: &key}
+	frame := new(bytes.Buffer)
+	req.WriteEnvelope(frame)
+	envLen := frame.Len()
+	req.WriteTo(frame)
+
+	header := dial()
+	defer header.Close()
+	// trickling bytes doesn't push deadline back
+	go trickle(header, frame.Bytes()[:envLen], 60*time.Millisecond)
+	expectClosed(t, header, 400*time.Millisecond)
+
+	body := dial()
+	defer body.Close()
+	body.Write(frame.Bytes()[:envLen])
+	go trickle(body, frame.Bytes()[envLen:], 10*time.Millisecond)
+	expectClosed(t, body, 400*time.Millisecond)
+
+	// requests sent often enough keep connection open
+	active := dial()
+	for i := 0; i < 8; i++ {
+		if err := roundTrip(t, active, &op.ReadRequest{Key: &key}, new(op.Value)); !errors.Is(err, op.ErrNotFound) {
+			t.Fatalf("Expected active connection to be served, received %v\n", err)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+	active.Close()
+
+	sub := dialSubscribed(t, proto, addr)
+	defer sub.Close()
+	sub.subscribe(t, false, "news")
+	time.Sleep(300 * time.Millisecond)
+
+	pub := dial()
+	defer pub.Close()
+	if n := publish(t, pub, "news", op.Value("hello")); n != 1 {
+		t.Fatalf("Expected subscribed connection to be kept open, found %d receivers\n", n)
+	}
+
+	msg := new(op.Message)
+	if _, err := msg.ReadFrom(sub); err != nil || string(msg.Payload) != "hello" {
+		t.Fatalf("Expected message, received %+v [%v]\n", msg, err)
+	}
+
+	stats := new(op.StatsResponse)
+	if err := roundTrip(t, pub, &op.StatsRequest{}, stats); err != nil {
+		t.Fatalf("Failed to read stats : %s\n", err.Error())
+	}
+
+	if n, _ := stats.Get("timed_out_connections"); n != 3 {
+		t.Fatalf("Expected 3 timed out connections, found %d\n", n)
+	}
+}
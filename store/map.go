@@ -0,0 +1,430 @@
+package store
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/op"
+)
+
+// Map is default store, keeping all entries in one map guarded by
+// a RWMutex
+type Map struct {
+	lock     *sync.RWMutex
+	kv       map[op.Key]*slot
+	index    *skiplist // keys of kv, in order
+	expiry   *expiry.Table
+	clock    expiry.Clock
+	mem      *memory
+	vers     *versions
+	onEvict  func(op.Key)
+	onChange func(op.Event)
+}
+
+// versions hands out versions of written entries. It's shared by all
+// shards of a store & starts from creation time of store in
+// nanoseconds, so that versions don't repeat across restarts.
+type versions struct {
+	last uint64 // updated atomically
+}
+
+func newVersions(clock expiry.Clock) *versions {
+	return &versions{last: uint64(clock.Now().UnixNano())}
+}
+
+func (v *versions) next() uint64 {
+	return atomic.AddUint64(&v.last, 1)
+}
+
+func NewMap(clock expiry.Clock, opts ...Option) *Map {
+	return newMap(clock, newMemory(opts...), newVersions(clock))
+}
+
+func newMap(clock expiry.Clock, mem *memory, vers *versions) *Map {
+	return &Map{
+		lock:   &sync.RWMutex{},
+		kv:     make(map[op.Key]*slot),
+		index:  newSkiplist(),
+		expiry: expiry.NewTable(clock),
+		clock:  clock,
+		mem:    mem,
+		vers:   vers,
+	}
+}
+
+func (m *Map) Get(key op.Key) (Entry, bool) {
+	m.lock.RLock()
+	s, ok := m.kv[key]
+	expired := ok && m.expiry.Expired(key)
+	var entry Entry
+	if ok && !expired {
+		// slot is updated in place by writers, so it's read under lock
+		ttl, _ := m.expiry.TTL(key)
+		entry = Entry{Value: s.value, Type: s.typ, TTL: ttl, Version: s.version}
+		if m.mem.tracksAccess() {
+			s.touch(m.clock.Now().UnixNano())
+		}
+	}
+	m.lock.RUnlock()
+
+	if expired {
+		m.lock.Lock()
+		m.alive(key)
+		m.lock.Unlock()
+
+		return Entry{}, false
+	}
+
+	return entry, ok
+}
+
+func (m *Map) Set(key op.Key, entry Entry) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.apply(key, entry, true)
+}
+
+func (m *Map) Delete(key op.Key) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	ok := m.alive(key)
+	m.remove(key, op.KeyDeleted)
+	return ok
+}
+
+func (m *Map) Update(key op.Key, fn func(Entry, bool) (Entry, bool)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	entry, ok := m.lookup(key)
+	entry, keep := fn(entry, ok)
+	m.apply(key, entry, keep)
+}
+
+func (m *Map) SetIf(key op.Key, entry Entry, cond func(Entry, bool) bool) (Entry, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	current, ok := m.lookup(key)
+	if !cond(current, ok) {
+		return current, false
+	}
+
+	return m.apply(key, entry, true), true
+}
+
+func (m *Map) Modify(key op.Key, fn func(Entry, bool) (Entry, error)) (Entry, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	entry, err := fn(m.lookup(key))
+	if err != nil {
+		return Entry{}, err
+	}
+
+	return m.apply(key, entry, true), nil
+}
+
+func (m *Map) UpdateMany(keys []op.Key, fn func([]Entry, []bool)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	entries, exist := make([]Entry, len(keys)), make([]bool, len(keys))
+	for i, key := range keys {
+		entries[i], exist[i] = m.lookup(key)
+	}
+
+	fn(entries, exist)
+	for i, key := range keys {
+		m.apply(key, entries[i], exist[i])
+	}
+}
+
+func (m *Map) ModifyMany(keys []op.Key, fn func([]Entry, []bool, []bool) error) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	entries, exist, dirty := make([]Entry, len(keys)), make([]bool, len(keys)), make([]bool, len(keys))
+	for i, key := range keys {
+		entries[i], exist[i] = m.lookup(key)
+	}
+
+	if err := fn(entries, exist, dirty); err != nil {
+		return err
+	}
+
+	for i, key := range keys {
+		if dirty[i] {
+			m.apply(key, entries[i], exist[i])
+		}
+	}
+
+	return nil
+}
+
+func (m *Map) Scan(fn func(op.Key, Entry) bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	for key, s := range m.kv {
+		if m.expiry.Expired(key) {
+			continue
+		}
+
+		ttl, _ := m.expiry.TTL(key)
+		if !fn(key, Entry{Value: s.value, Type: s.typ, TTL: ttl, Version: s.version}) {
+			return
+		}
+	}
+}
+
+func (m *Map) Range(start, end op.Key, limit int) []op.Key {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	keys := make([]op.Key, 0)
+	for node := m.index.seek(start); node != nil && len(keys) < limit; node = node.next[0] {
+		if end != "" && node.key >= end {
+			break
+		}
+
+		if !m.expiry.Expired(node.key) {
+			keys = append(keys, node.key)
+		}
+	}
+
+	return keys
+}
+
+func (m *Map) Len() int {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return len(m.kv)
+}
+
+// Reserve evicts keys, till key fits in max memory with size bytes
+func (m *Map) Reserve(key op.Key, size int) error {
+	return m.mem.reserve(size-m.footprint(key), []op.Key{key}, []*Map{m}, 0)
+}
+
+// ReserveMany evicts keys, other than ones being reserved or kept, till
+// all of keys fit in max memory with their sizes
+func (m *Map) ReserveMany(sizes map[op.Key]int, keep []op.Key) error {
+	n := 0
+	except := make([]op.Key, 0, len(sizes)+len(keep))
+	for key, size := range sizes {
+		n += size - m.footprint(key)
+		except = append(except, key)
+	}
+
+	return m.mem.reserve(n, append(except, keep...), []*Map{m}, 0)
+}
+
+func (m *Map) Memory() MemoryStats {
+	return m.mem.stats()
+}
+
+// OnEvict sets fn to be called with every evicted key, while store is
+// locked
+func (m *Map) OnEvict(fn func(op.Key)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.onEvict = fn
+}
+
+// OnChange sets fn to be called with every change applied to store,
+// while store is locked
+func (m *Map) OnChange(fn func(op.Event)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.onChange = fn
+}
+
+// Sweep removes a sample of expired keys, returning true when a large
+// share of sample had expired
+func (m *Map) Sweep() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	keys := m.expiry.Collect(expiry.SampleSize)
+	for _, key := range keys {
+		m.remove(key, op.KeyExpired)
+	}
+
+	return len(keys) > expiry.SampleSize/4
+}
+
+// footprint returns bytes taken by key & its value, zero when key is
+// missing
+func (m *Map) footprint(key op.Key) int {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	s, ok := m.kv[key]
+	if !ok {
+		return 0
+	}
+
+	return len(key) + len(s.value)
+}
+
+// evict removes one key, other than except, picked from a sample of
+// keys as per policy. It returns false, when no key could be picked.
+func (m *Map) evict(except []op.Key) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var (
+		victim op.Key
+		found  bool
+	)
+
+	switch m.mem.policy {
+	case VolatileTTL:
+		var soonest time.Time
+		m.expiry.Sample(EvictionSample, func(key op.Key, deadline time.Time) {
+			if !excepted(key, except) && (!found || deadline.Before(soonest)) {
+				victim, soonest, found = key, deadline, true
+			}
+		})
+
+	case AllKeysLRU, AllKeysLFU:
+		now := m.clock.Now().UnixNano()
+		var best int64
+		sample := EvictionSample
+
+		for key, s := range m.kv {
+			if sample == 0 {
+				break
+			}
+
+			if excepted(key, except) {
+				continue
+			}
+
+			sample--
+			score := atomic.LoadInt64(&s.access)
+			if m.mem.policy == AllKeysLFU {
+				score = int64(s.frequency(now))
+			}
+
+			if !found || score < best {
+				victim, best, found = key, score, true
+			}
+		}
+
+	}
+
+	if !found {
+		return false
+	}
+
+	m.remove(victim, op.KeyEvicted)
+	if m.onEvict != nil {
+		m.onEvict(victim)
+	}
+
+	return true
+}
+
+// lookup returns entry of live key, while write lock is held
+func (m *Map) lookup(key op.Key) (Entry, bool) {
+	if !m.alive(key) {
+		return Entry{}, false
+	}
+
+	ttl, _ := m.expiry.TTL(key)
+	s := m.kv[key]
+	return Entry{Value: s.value, Type: s.typ, TTL: ttl, Version: s.version}, true
+}
+
+// apply stores entry or removes key, while write lock is held. Entry
+// is returned as stored.
+func (m *Map) apply(key op.Key, entry Entry, keep bool) Entry {
+	if !keep {
+		m.remove(key, op.KeyDeleted)
+		return Entry{}
+	}
+
+	if entry.TTL < 0 {
+		m.remove(key, op.KeyExpired)
+		return Entry{}
+	}
+
+	entry.Version = m.set(key, entry)
+	return entry
+}
+
+// set stores entry, while write lock is held, returning its version
+func (m *Map) set(key op.Key, entry Entry) uint64 {
+	now := m.clock.Now().UnixNano()
+	s, ok := m.kv[key]
+	if ok {
+		m.mem.add(len(entry.Value) - len(s.value))
+		s.value = entry.Value
+		s.typ = entry.Type
+		if m.mem.tracksAccess() {
+			s.touch(now)
+		}
+	} else {
+		m.mem.add(len(key) + len(entry.Value))
+		s = newSlot(entry.Value, entry.Type, now)
+		m.kv[key] = s
+		m.index.insert(key)
+	}
+
+	s.version = m.vers.next()
+	if entry.TTL > 0 {
+		m.expiry.Set(key, entry.TTL)
+	} else {
+		m.expiry.Delete(key)
+	}
+
+	m.notify(key, op.KeyWritten, s.version)
+	return s.version
+}
+
+// remove deletes key, while write lock is held, telling why as kind
+func (m *Map) remove(key op.Key, kind op.EventKind) {
+	s, ok := m.kv[key]
+	if ok {
+		m.mem.add(-(len(key) + len(s.value)))
+		delete(m.kv, key)
+		m.index.delete(key)
+	}
+
+	m.expiry.Delete(key)
+	if ok {
+		m.notify(key, kind, m.vers.next())
+	}
+}
+
+// notify passes change of key to fn set with OnChange, while write lock
+// is held
+func (m *Map) notify(key op.Key, kind op.EventKind, version uint64) {
+	if m.onChange != nil {
+		m.onChange(op.Event{Key: key, Kind: kind, Version: version})
+	}
+}
+
+// alive tells whether key is present & not yet expired. Expired key is
+// removed right away. Caller must hold write lock.
+func (m *Map) alive(key op.Key) bool {
+	if _, ok := m.kv[key]; !ok {
+		return false
+	}
+
+	if !m.expiry.Expired(key) {
+		return true
+	}
+
+	m.remove(key, op.KeyExpired)
+	return false
+}
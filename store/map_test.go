@@ -0,0 +1,531 @@
+package store_test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+func TestMap(t *testing.T) {
+	clock := expiry.NewManualClock(time.Unix(0, 0))
+	st := store.NewMap(clock)
+
+	if _, ok := st.Get("a"); ok {
+		t.Fatalf("Expected `a` to be missing\n")
+	}
+
+	st.Set("a", store.Entry{Value: op.Value("1")})
+	st.Set("b", store.Entry{Value: op.Value("2"), TTL: time.Second})
+
+	if entry, ok := st.Get("a"); !ok || string(entry.Value) != "1" || entry.TTL != 0 {
+		t.Fatalf("Expected `a` = 1 without TTL, received %+v\n", entry)
+	}
+
+	if entry, ok := st.Get("b"); !ok || entry.TTL != time.Second {
+		t.Fatalf("Expected `b` with TTL of 1s, received %+v\n", entry)
+	}
+
+	clock.Advance(time.Second)
+	if _, ok := st.Get("b"); ok {
+		t.Fatalf("Expected `b` to be expired\n")
+	}
+
+	if st.Len() != 1 {
+		t.Fatalf("Expected expired key to be reclaimed on access\n")
+	}
+
+	st.Update("a", func(entry store.Entry, ok bool) (store.Entry, bool) {
+		if !ok {
+			t.Fatalf("Expected `a` to exist\n")
+		}
+
+		entry.Value = append(entry.Value, '1')
+		return entry, true
+	})
+
+	if entry, _ := st.Get("a"); string(entry.Value) != "11" {
+		t.Fatalf("Expected `a` = 11, received %s\n", entry.Value)
+	}
+
+	st.Update("a", func(entry store.Entry, ok bool) (store.Entry, bool) {
+		return entry, false
+	})
+
+	if st.Delete("a") {
+		t.Fatalf("Expected `a` to be deleted by update\n")
+	}
+}
+
+func TestMapScan(t *testing.T) {
+	clock := expiry.NewManualClock(time.Unix(0, 0))
+	st := store.NewMap(clock)
+
+	for i := 0; i < 10; i++ {
+		var ttl time.Duration
+		if i%2 == 0 {
+			ttl = time.Second
+		}
+
+		st.Set(op.Key(fmt.Sprintf("key-%d", i)), store.Entry{Value: op.Value("v"), TTL: ttl})
+	}
+
+	clock.Advance(time.Second)
+	seen := 0
+	st.Scan(func(key op.Key, entry store.Entry) bool {
+		seen++
+		return true
+	})
+
+	if seen != 5 {
+		t.Fatalf("Expected 5 live keys, scanned %d\n", seen)
+	}
+
+	// expired keys are reclaimed by sweeping
+	for st.Sweep() {
+	}
+
+	if st.Len() != 5 {
+		t.Fatalf("Expected 5 keys after sweeping, found %d\n", st.Len())
+	}
+
+	seen = 0
+	st.Scan(func(key op.Key, entry store.Entry) bool {
+		seen++
+		return false
+	})
+
+	if seen != 1 {
+		t.Fatalf("Expected scan to stop after first key\n")
+	}
+}
+
+func TestMapConcurrentUpdate(t *testing.T) {
+	st := store.NewMap(expiry.System)
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 1000; j++ {
+				st.Update("counter", func(entry store.Entry, ok bool) (store.Entry, bool) {
+					entry.Value = append(op.Value{}, entry.Value...)
+					entry.Value = append(entry.Value, 'x')
+					return entry, true
+				})
+			}
+		}()
+	}
+
+	wg.Wait()
+	if entry, _ := st.Get("counter"); len(entry.Value) != 8000 {
+		t.Fatalf("Expected 8000 updates, found %d\n", len(entry.Value))
+	}
+}
+
+// TestMapConcurrentGet reads key, while it's being overwritten, which is
+// meant to be run with race detector
+func TestMapConcurrentGet(t *testing.T) {
+	st := store.NewMap(expiry.System)
+	st.Set("key", store.Entry{Value: op.Value("0")})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for i := 1; i <= 1000; i++ {
+			st.Set("key", store.Entry{Value: op.Value(fmt.Sprint(i))})
+		}
+	}()
+
+	var last uint64
+	for i := 0; i < 1000; i++ {
+		entry, ok := st.Get("key")
+		if !ok || len(entry.Value) == 0 || entry.Version < last {
+			t.Fatalf("Expected consistent entry, found %+v\n", entry)
+		}
+
+		last = entry.Version
+	}
+
+	<-done
+}
+
+func TestMapRange(t *testing.T) {
+	clock := expiry.NewManualClock(time.Unix(0, 0))
+	testRange(t, store.NewMap(clock), clock)
+}
+
+// testRange checks store lists keys in order, within bounds, skipping
+// expired & deleted ones
+func testRange(t *testing.T, st store.Store, clock *expiry.ManualClock) {
+	// keys are written out of order
+	for _, i := range rand.Perm(1000) {
+		var ttl time.Duration
+		if i%10 == 0 {
+			ttl = time.Second
+		}
+
+		st.Set(op.Key(fmt.Sprintf("key-%03d", i)), store.Entry{Value: op.Value("v"), TTL: ttl})
+	}
+
+	st.Delete("key-001")
+	clock.Advance(time.Second)
+
+	expect := func(start, end op.Key, limit int, first op.Key, count int) {
+		t.Helper()
+
+		keys := st.Range(start, end, limit)
+		if len(keys) != count || (count != 0 && keys[0] != first) {
+			t.Fatalf("Expected %d keys from `%s`, received %d keys %v\n", count, first, len(keys), keys)
+		}
+
+		for i := 1; i < len(keys); i++ {
+			if keys[i-1] >= keys[i] {
+				t.Fatalf("Expected keys in order, received `%s` before `%s`\n", keys[i-1], keys[i])
+			}
+		}
+	}
+
+	// 100 keys expired & 1 got deleted
+	expect("", "", 2000, "key-002", 899)
+	expect("", "", 10, "key-002", 10)
+	expect("key-1", "key-2", 1000, "key-101", 90)
+	expect("key-500", "", 1000, "key-501", 450)
+	expect("key-999", "key-999", 10, "", 0)
+	expect("x", "", 10, "", 0)
+}
+
+func TestMapUpdateMany(t *testing.T) {
+	testUpdateMany(t, store.NewMap(expiry.System))
+}
+
+// testUpdateMany moves units between keys concurrently, while checking
+// total stays same, which holds only when updates are atomic
+func testUpdateMany(t *testing.T, st store.Store) {
+	keys := make([]op.Key, 16)
+	for i := range keys {
+		keys[i] = op.Key(fmt.Sprintf("account-%d", i))
+		st.Set(keys[i], store.Entry{Value: op.Value{100}})
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+
+			rnd := rand.New(rand.NewSource(seed))
+			for j := 0; j < 500; j++ {
+				pick := rnd.Perm(len(keys))
+				pair := []op.Key{keys[pick[0]], keys[pick[1]]}
+
+				st.UpdateMany(pair, func(entries []store.Entry, exist []bool) {
+					if entries[0].Value[0] == 0 {
+						return
+					}
+
+					entries[0].Value = op.Value{entries[0].Value[0] - 1}
+					entries[1].Value = op.Value{entries[1].Value[0] + 1}
+				})
+			}
+		}(int64(i))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for {
+		st.UpdateMany(keys, func(entries []store.Entry, exist []bool) {
+			total := 0
+			for i := range entries {
+				if !exist[i] {
+					t.Errorf("Expected `%s` to exist\n", keys[i])
+				}
+
+				total += int(entries[i].Value[0])
+			}
+
+			if total != 1600 {
+				t.Errorf("Expected total of 1600, found %d\n", total)
+			}
+		})
+
+		select {
+		case <-done:
+			// keys marked missing get deleted
+			st.UpdateMany(keys[:2], func(entries []store.Entry, exist []bool) {
+				exist[0] = false
+			})
+
+			if _, ok := st.Get(keys[0]); ok {
+				t.Fatalf("Expected `%s` to be deleted\n", keys[0])
+			}
+
+			return
+
+		default:
+		}
+	}
+}
+
+func TestMapVersions(t *testing.T) {
+	testVersions(t, store.NewMap(expiry.System))
+}
+
+// testVersions checks every write of key gets a larger version & that
+// conditional writes leave key untouched, when condition doesn't hold
+func testVersions(t *testing.T, st store.Store) {
+	st.Set("a", store.Entry{Value: op.Value("1")})
+	first, _ := st.Get("a")
+
+	st.Update("a", func(entry store.Entry, ok bool) (store.Entry, bool) {
+		entry.Value = op.Value("2")
+		return entry, true
+	})
+
+	second, _ := st.Get("a")
+	if second.Version <= first.Version {
+		t.Fatalf("Expected version to grow past %d, found %d\n", first.Version, second.Version)
+	}
+
+	// versions keep growing across delete
+	st.Delete("a")
+	st.Set("a", store.Entry{Value: op.Value("1"), Version: first.Version})
+	third, _ := st.Get("a")
+	if third.Version <= second.Version {
+		t.Fatalf("Expected version to grow past %d, found %d\n", second.Version, third.Version)
+	}
+
+	absent := func(_ store.Entry, ok bool) bool { return !ok }
+	if entry, ok := st.SetIf("a", store.Entry{Value: op.Value("x")}, absent); ok || entry.Version != third.Version || string(entry.Value) != "1" {
+		t.Fatalf("Expected write of present key to be skipped, received %+v [%v]\n", entry, ok)
+	}
+
+	if entry, _ := st.Get("a"); entry.Version != third.Version {
+		t.Fatalf("Expected skipped write to keep version %d, found %d\n", third.Version, entry.Version)
+	}
+
+	entry, ok := st.SetIf("b", store.Entry{Value: op.Value("y")}, absent)
+	if !ok || entry.Version <= third.Version || string(entry.Value) != "y" {
+		t.Fatalf("Expected write of absent key, received %+v [%v]\n", entry, ok)
+	}
+
+	if stored, _ := st.Get("b"); stored.Version != entry.Version {
+		t.Fatalf("Expected version %d to be stored, found %d\n", entry.Version, stored.Version)
+	}
+
+	// keys of every shard draw from same sequence
+	seen := make(map[uint64]bool)
+	for i := 0; i < 100; i++ {
+		key := op.Key(fmt.Sprintf("key-%d", i))
+		st.Set(key, store.Entry{Value: op.Value("v")})
+
+		entry, _ := st.Get(key)
+		if seen[entry.Version] {
+			t.Fatalf("Expected unique versions, %d repeated\n", entry.Version)
+		}
+
+		seen[entry.Version] = true
+	}
+}
+
+func TestMapModify(t *testing.T) {
+	testModify(t, store.NewMap(expiry.System))
+}
+
+// testModify checks failing fn leaves key untouched, while successful
+// one stores its entry with a new version
+func testModify(t *testing.T, st store.Store) {
+	st.Set("a", store.Entry{Value: op.Value("1")})
+	before, _ := st.Get("a")
+
+	errSkip := errors.New("skip")
+	if _, err := st.Modify("a", func(entry store.Entry, ok bool) (store.Entry, error) {
+		entry.Value = op.Value("x")
+		return entry, errSkip
+	}); err != errSkip {
+		t.Fatalf("Expected error of fn, received %v\n", err)
+	}
+
+	if entry, _ := st.Get("a"); entry.Version != before.Version || string(entry.Value) != "1" {
+		t.Fatalf("Expected `a` to be untouched, found %+v\n", entry)
+	}
+
+	stored, err := st.Modify("b", func(entry store.Entry, ok bool) (store.Entry, error) {
+		if ok {
+			t.Fatalf("Expected `b` to be missing\n")
+		}
+
+		entry.Value = op.Value("2")
+		return entry, nil
+	})
+
+	if err != nil || string(stored.Value) != "2" || stored.Version <= before.Version {
+		t.Fatalf("Expected `b` to be stored with new version, received %+v [%v]\n", stored, err)
+	}
+
+	if entry, _ := st.Get("b"); entry.Version != stored.Version {
+		t.Fatalf("Expected version %d to be stored, found %d\n", stored.Version, entry.Version)
+	}
+}
+
+func TestMapModifyMany(t *testing.T) {
+	testModifyMany(t, store.NewMap(expiry.System))
+}
+
+// testModifyMany checks only dirty keys are written, while failing fn
+// leaves every key untouched
+func testModifyMany(t *testing.T, st store.Store) {
+	keys := []op.Key{"a", "b", "c"}
+	st.Set("a", store.Entry{Value: op.Value("1")})
+	st.Set("b", store.Entry{Value: op.Value("2")})
+	a, _ := st.Get("a")
+	b, _ := st.Get("b")
+
+	errSkip := errors.New("skip")
+	if err := st.ModifyMany(keys, func(entries []store.Entry, exist []bool, dirty []bool) error {
+		for i := range keys {
+			entries[i].Value, exist[i], dirty[i] = op.Value("x"), true, true
+		}
+
+		return errSkip
+	}); err != errSkip {
+		t.Fatalf("Expected error of fn, received %v\n", err)
+	}
+
+	if _, ok := st.Get("c"); ok {
+		t.Fatalf("Expected `c` to be missing\n")
+	}
+
+	if err := st.ModifyMany(keys, func(entries []store.Entry, exist []bool, dirty []bool) error {
+		if !exist[0] || !exist[1] || exist[2] {
+			t.Fatalf("Expected only `a` & `b` to exist, found %v\n", exist)
+		}
+
+		// `a` is modified without being marked dirty
+		entries[0].Value = op.Value("x")
+		exist[1], dirty[1] = false, true
+		entries[2], exist[2], dirty[2] = store.Entry{Value: op.Value("3")}, true, true
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed to modify : %s\n", err.Error())
+	}
+
+	if entry, _ := st.Get("a"); entry.Version != a.Version || string(entry.Value) != "1" {
+		t.Fatalf("Expected `a` to be untouched, found %+v\n", entry)
+	}
+
+	if _, ok := st.Get("b"); ok {
+		t.Fatalf("Expected `b` to be deleted\n")
+	}
+
+	if entry, ok := st.Get("c"); !ok || string(entry.Value) != "3" || entry.Version <= b.Version {
+		t.Fatalf("Expected `c` = 3 with new version, found %+v\n", entry)
+	}
+}
+
+func TestMapChanges(t *testing.T) {
+	clock := expiry.NewManualClock(time.Unix(0, 0))
+	testChanges(t, store.NewMap(clock), clock)
+}
+
+// testChanges checks every write, deletion & expiry is reported once,
+// carrying versions in order they're applied
+func testChanges(t *testing.T, st store.Store, clock *expiry.ManualClock) {
+	var events []op.Event
+	st.(store.Notifier).OnChange(func(ev op.Event) {
+		events = append(events, ev)
+	})
+
+	expect := func(want ...op.Event) {
+		t.Helper()
+
+		if len(events) != len(want) {
+			t.Fatalf("Expected %d events, received %+v\n", len(want), events)
+		}
+
+		for i := range want {
+			if events[i].Key != want[i].Key || events[i].Kind != want[i].Kind {
+				t.Fatalf("Expected `%s` to be %s, received %+v\n", want[i].Key, want[i].Kind, events[i])
+			}
+
+			if i > 0 && events[i].Version <= events[i-1].Version {
+				t.Fatalf("Expected versions to grow, received %+v\n", events)
+			}
+		}
+
+		events = events[:0]
+	}
+
+	st.Set("a", store.Entry{Value: op.Value("1")})
+	entry, _ := st.Get("a")
+	expect(op.Event{Key: "a", Kind: op.KeyWritten})
+	st.Set("a", store.Entry{Value: op.Value("2")})
+	if events[0].Version <= entry.Version {
+		t.Fatalf("Expected version after %d, received %d\n", entry.Version, events[0].Version)
+	}
+
+	expect(op.Event{Key: "a", Kind: op.KeyWritten})
+
+	st.Delete("a")
+	st.Delete("a")
+	st.Update("a", func(entry store.Entry, ok bool) (store.Entry, bool) { return entry, false })
+	expect(op.Event{Key: "a", Kind: op.KeyDeleted})
+
+	st.Set("b", store.Entry{Value: op.Value("1"), TTL: time.Second})
+	st.Set("c", store.Entry{Value: op.Value("1"), TTL: time.Second})
+	st.UpdateMany([]op.Key{"b", "d"}, func(entries []store.Entry, exist []bool) {
+		exist[0] = false
+		entries[1], exist[1] = store.Entry{Value: op.Value("1"), TTL: time.Second}, true
+	})
+
+	expect(
+		op.Event{Key: "b", Kind: op.KeyWritten},
+		op.Event{Key: "c", Kind: op.KeyWritten},
+		op.Event{Key: "b", Kind: op.KeyDeleted},
+		op.Event{Key: "d", Kind: op.KeyWritten},
+	)
+
+	// expired key is reported, once it's accessed for writing or swept
+	clock.Advance(time.Second)
+	if st.Delete("c") {
+		t.Fatalf("Expected expired `c` to be missing\n")
+	}
+
+	expect(op.Event{Key: "c", Kind: op.KeyExpired})
+
+	st.(store.Sweeper).Sweep()
+	expect(op.Event{Key: "d", Kind: op.KeyExpired})
+}
+
+func TestMapTypes(t *testing.T) {
+	st := store.NewMap(expiry.System)
+	st.Set("a", store.Entry{Value: op.List{op.Value("1")}.Encode(), Type: store.List})
+
+	// type is kept, till value is replaced
+	st.Update("a", func(entry store.Entry, ok bool) (store.Entry, bool) {
+		entry.TTL = time.Hour
+		return entry, ok
+	})
+
+	if entry, _ := st.Get("a"); entry.Type != store.List {
+		t.Fatalf("Expected `a` to be a list, found %+v\n", entry)
+	}
+
+	st.Set("a", store.Entry{Value: op.Value("1")})
+	if entry, _ := st.Get("a"); entry.Type != store.String {
+		t.Fatalf("Expected `a` to be a byte string, found %+v\n", entry)
+	}
+}
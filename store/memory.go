@@ -0,0 +1,210 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+// EvictionPolicy tells which keys are evicted, when store with max
+// memory set is full
+type EvictionPolicy uint8
+
+const (
+	NoEviction  EvictionPolicy = iota + 1 // writes fail, till keys are deleted
+	AllKeysLRU                            // least recently accessed key
+	AllKeysLFU                            // least frequently accessed key
+	VolatileTTL                           // key with TTL, expiring soonest
+)
+
+func (e EvictionPolicy) String() string {
+	switch e {
+	case NoEviction:
+		return "noeviction"
+	case AllKeysLRU:
+		return "allkeys-lru"
+	case AllKeysLFU:
+		return "allkeys-lfu"
+	case VolatileTTL:
+		return "volatile-ttl"
+	default:
+		return fmt.Sprintf("eviction policy %d", uint8(e))
+	}
+}
+
+func ParseEvictionPolicy(s string) (EvictionPolicy, error) {
+	for _, e := range []EvictionPolicy{NoEviction, AllKeysLRU, AllKeysLFU, VolatileTTL} {
+		if s == e.String() {
+			return e, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown eviction policy `%s`", s)
+}
+
+// ErrOutOfMemory is returned, when room can't be made for a write
+var ErrOutOfMemory = errors.New("max memory reached, no key can be evicted")
+
+// EvictionSample is number of keys examined for picking one to evict.
+// Evicted key is best candidate among these, which is a close enough
+// approximation of best one in whole store.
+const EvictionSample = 5
+
+// MemoryStats reports memory accounting of store, where used memory is
+// total length of all keys & values
+type MemoryStats struct {
+	Used    uint64
+	Max     uint64 // zero when memory isn't bounded
+	Evicted uint64 // keys evicted so far
+}
+
+// memory is budget of store, shared by all shards of it
+type memory struct {
+	limit   int64 // zero for no limit
+	policy  EvictionPolicy
+	used    int64 // updated atomically
+	evicted uint64
+}
+
+// Option updates one setting of store
+type Option func(*memory)
+
+// WithMaxMemory bounds total length of keys & values in store, evicting
+// keys as per policy when it's reached
+func WithMaxMemory(limit uint64, policy EvictionPolicy) Option {
+	return func(m *memory) {
+		m.limit = int64(limit)
+		m.policy = policy
+	}
+}
+
+func newMemory(opts ...Option) *memory {
+	mem := memory{policy: NoEviction}
+	for _, opt := range opts {
+		opt(&mem)
+	}
+
+	return &mem
+}
+
+func (m *memory) add(n int) {
+	atomic.AddInt64(&m.used, int64(n))
+}
+
+// tracksAccess tells whether accesses need to be recorded for policy
+func (m *memory) tracksAccess() bool {
+	return m.limit > 0 && (m.policy == AllKeysLRU || m.policy == AllKeysLFU)
+}
+
+// reserve evicts keys, other than except, from maps, starting with one
+// at index start, till n more bytes fit in budget. Maps are taken in
+// turns, so that eviction is spread over all of them.
+func (m *memory) reserve(n int, except []op.Key, maps []*Map, start int) error {
+	if m.limit == 0 {
+		return nil
+	}
+
+	for i := start; atomic.LoadInt64(&m.used)+int64(n) > m.limit; i++ {
+		if m.policy == NoEviction {
+			return ErrOutOfMemory
+		}
+
+		evicted := false
+		for j := 0; j < len(maps) && !evicted; j++ {
+			evicted = maps[(i+j)%len(maps)].evict(except)
+		}
+
+		if !evicted {
+			return ErrOutOfMemory
+		}
+
+		atomic.AddUint64(&m.evicted, 1)
+	}
+
+	return nil
+}
+
+// excepted tells whether key is one of except, which mustn't be evicted
+func excepted(key op.Key, except []op.Key) bool {
+	for _, k := range except {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *memory) stats() MemoryStats {
+	used := atomic.LoadInt64(&m.used)
+	if used < 0 {
+		used = 0
+	}
+
+	return MemoryStats{Used: uint64(used), Max: uint64(m.limit), Evicted: atomic.LoadUint64(&m.evicted)}
+}
+
+const (
+	// lfuInitial is access counter of new key, so that it's not evicted
+	// before getting a chance to be accessed
+	lfuInitial = 5
+	lfuMax     = 255
+	// lfuLogFactor slows down growth of counter, with 10 it saturates
+	// after about a million accesses
+	lfuLogFactor = 10
+)
+
+// slot holds value of key, along with access statistics, used for
+// picking keys to evict. Latter are updated atomically, as reads only
+// hold read lock.
+type slot struct {
+	value   []byte
+	typ     Type
+	version uint64
+	access  int64  // unix nanoseconds of last access
+	freq    uint32 // logarithmic access counter
+}
+
+func newSlot(value []byte, typ Type, now int64) *slot {
+	return &slot{value: value, typ: typ, access: now, freq: lfuInitial}
+}
+
+// touch records an access
+func (s *slot) touch(now int64) {
+	freq := s.frequency(now)
+	if freq < lfuMax {
+		// counter grows with probability falling as it grows, so that it
+		// counts orders of magnitude of accesses
+		base := 0
+		if freq > lfuInitial {
+			base = int(freq) - lfuInitial
+		}
+
+		if rand.Float64() < 1/float64(base*lfuLogFactor+1) {
+			freq++
+		}
+	}
+
+	atomic.StoreUint32(&s.freq, freq)
+	atomic.StoreInt64(&s.access, now)
+}
+
+// frequency returns access counter, decayed by one for every minute key
+// wasn't accessed
+func (s *slot) frequency(now int64) uint32 {
+	freq := atomic.LoadUint32(&s.freq)
+	idle := (now - atomic.LoadInt64(&s.access)) / int64(time.Minute)
+	if idle <= 0 {
+		return freq
+	}
+
+	if idle >= int64(freq) {
+		return 0
+	}
+
+	return freq - uint32(idle)
+}
@@ -0,0 +1,245 @@
+package store_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+// write sets key, after reserving room for it
+func write(st store.Store, key op.Key, val string, ttl time.Duration) error {
+	if err := st.Reserve(key, len(key)+len(val)); err != nil {
+		return err
+	}
+
+	st.Set(key, store.Entry{Value: op.Value(val), TTL: ttl})
+	return nil
+}
+
+func TestMemoryAccounting(t *testing.T) {
+	clock := expiry.NewManualClock(time.Unix(0, 0))
+	st := store.NewMap(clock)
+
+	expect := func(used uint64) {
+		t.Helper()
+		if mem := st.Memory(); mem.Used != used {
+			t.Fatalf("Expected %d bytes to be used, found %d\n", used, mem.Used)
+		}
+	}
+
+	st.Set("a", store.Entry{Value: op.Value("123")})
+	st.Set("bb", store.Entry{Value: op.Value("1"), TTL: time.Second})
+	expect(7)
+
+	st.Set("a", store.Entry{Value: op.Value("1")})
+	expect(5)
+
+	st.Update("a", func(entry store.Entry, ok bool) (store.Entry, bool) {
+		entry.Value = op.Value("12345")
+		return entry, true
+	})
+	expect(9)
+
+	clock.Advance(time.Second)
+	for st.Sweep() {
+	}
+	expect(6)
+
+	st.Delete("a")
+	expect(0)
+
+	if mem := st.Memory(); mem.Max != 0 || mem.Evicted != 0 {
+		t.Fatalf("Expected unbounded store, found %+v\n", mem)
+	}
+
+	if err := write(st, "big", string(make([]byte, 1<<20)), 0); err != nil {
+		t.Fatalf("Expected unbounded store to accept write, received %s\n", err.Error())
+	}
+}
+
+func TestNoEviction(t *testing.T) {
+	st := store.NewMap(expiry.System, store.WithMaxMemory(8, store.NoEviction))
+
+	for _, key := range []op.Key{"a", "b", "c", "d"} {
+		if err := write(st, key, "1", 0); err != nil {
+			t.Fatalf("Failed to write `%s` : %s\n", key, err.Error())
+		}
+	}
+
+	if err := write(st, "e", "1", 0); !errors.Is(err, store.ErrOutOfMemory) {
+		t.Fatalf("Expected out of memory error, received %v\n", err)
+	}
+
+	// replacing value with one of same size needs no more room
+	if err := write(st, "a", "2", 0); err != nil {
+		t.Fatalf("Expected overwrite to fit, received %s\n", err.Error())
+	}
+
+	st.Delete("b")
+	if err := write(st, "e", "1", 0); err != nil {
+		t.Fatalf("Expected write to fit after delete, received %s\n", err.Error())
+	}
+
+	if mem := st.Memory(); mem.Used != 8 || mem.Evicted != 0 {
+		t.Fatalf("Expected 8 bytes used without eviction, found %+v\n", mem)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	// with no more keys than eviction sample, victim is always best one
+	cases := []struct {
+		policy store.EvictionPolicy
+		access func(st store.Store, clock *expiry.ManualClock)
+		victim op.Key
+	}{
+		{
+			policy: store.AllKeysLRU,
+			access: func(st store.Store, clock *expiry.ManualClock) {
+				for _, key := range []op.Key{"a", "c", "b"} {
+					clock.Advance(time.Second)
+					st.Get(key)
+				}
+			},
+			victim: "d",
+		},
+		{
+			policy: store.AllKeysLFU,
+			access: func(st store.Store, clock *expiry.ManualClock) {
+				for _, key := range []op.Key{"a", "b", "b", "d", "a"} {
+					st.Get(key)
+				}
+			},
+			victim: "c",
+		},
+		{
+			policy: store.VolatileTTL,
+			access: func(st store.Store, clock *expiry.ManualClock) {
+				st.Set("b", store.Entry{Value: op.Value("1"), TTL: time.Hour})
+				st.Set("c", store.Entry{Value: op.Value("1"), TTL: time.Minute})
+			},
+			victim: "c",
+		},
+	}
+
+	for _, c := range cases {
+		clock := expiry.NewManualClock(time.Unix(0, 0))
+		st := store.NewSharded(1, clock, store.WithMaxMemory(8, c.policy))
+
+		evicted := make([]op.Key, 0)
+		st.OnEvict(func(key op.Key) {
+			evicted = append(evicted, key)
+		})
+
+		// evictions are reported as changes too
+		reported := make([]op.Key, 0)
+		st.OnChange(func(ev op.Event) {
+			if ev.Kind == op.KeyEvicted {
+				reported = append(reported, ev.Key)
+			}
+		})
+
+		for _, key := range []op.Key{"a", "b", "c", "d"} {
+			clock.Advance(time.Second)
+			if err := write(st, key, "1", 0); err != nil {
+				t.Fatalf("[%s] Failed to write `%s` : %s\n", c.policy, key, err.Error())
+			}
+		}
+
+		c.access(st, clock)
+		if err := write(st, "e", "1", 0); err != nil {
+			t.Fatalf("[%s] Failed to write : %s\n", c.policy, err.Error())
+		}
+
+		if len(evicted) != 1 || evicted[0] != c.victim {
+			t.Fatalf("[%s] Expected `%s` to be evicted, evicted %v\n", c.policy, c.victim, evicted)
+		}
+
+		if len(reported) != 1 || reported[0] != c.victim {
+			t.Fatalf("[%s] Expected eviction of `%s` to be reported, reported %v\n", c.policy, c.victim, reported)
+		}
+
+		if _, ok := st.Get(c.victim); ok {
+			t.Fatalf("[%s] Expected `%s` to be missing\n", c.policy, c.victim)
+		}
+
+		if mem := st.Memory(); mem.Used != 8 || mem.Max != 8 || mem.Evicted != 1 {
+			t.Fatalf("[%s] Expected 8 of 8 bytes used after 1 eviction, found %+v\n", c.policy, mem)
+		}
+	}
+
+	// volatile-ttl evicts only keys with TTL
+	st := store.NewMap(expiry.System, store.WithMaxMemory(4, store.VolatileTTL))
+	write(st, "a", "1", 0)
+	write(st, "b", "1", 0)
+	if err := write(st, "c", "1", 0); !errors.Is(err, store.ErrOutOfMemory) {
+		t.Fatalf("Expected out of memory error, received %v\n", err)
+	}
+}
+
+func TestShardedEviction(t *testing.T) {
+	st := store.NewSharded(8, expiry.System, store.WithMaxMemory(1000, store.AllKeysLRU))
+
+	for i := 0; i < 1000; i++ {
+		if err := write(st, op.Key(fmt.Sprintf("key-%04d", i)), "value", 0); err != nil {
+			t.Fatalf("Failed to write : %s\n", err.Error())
+		}
+
+		if mem := st.Memory(); mem.Used > mem.Max {
+			t.Fatalf("Expected used memory to stay within %d bytes, found %d\n", mem.Max, mem.Used)
+		}
+	}
+
+	// every key takes 13 bytes, so 76 of them fit
+	mem := st.Memory()
+	if st.Len() != 76 || mem.Used != 76*13 || mem.Evicted != 1000-76 {
+		t.Fatalf("Expected 76 keys after %d evictions, found %d keys & %+v\n", 1000-76, st.Len(), mem)
+	}
+}
+
+func TestReserveMany(t *testing.T) {
+	for _, st := range []store.Store{
+		store.NewMap(expiry.System, store.WithMaxMemory(8, store.AllKeysLRU)),
+		store.NewSharded(4, expiry.System, store.WithMaxMemory(8, store.AllKeysLRU)),
+	} {
+		for _, key := range []op.Key{"a", "b", "c", "d"} {
+			if err := write(st, key, "1", 0); err != nil {
+				t.Fatalf("Failed to write `%s` : %s\n", key, err.Error())
+			}
+		}
+
+		// least recently used keys are rewritten or kept, so only `c` &
+		// `d` can make room, with `a` taking no more than it does now
+		if err := st.ReserveMany(map[op.Key]int{"a": 2, "e": 2, "f": 2}, []op.Key{"b"}); err != nil {
+			t.Fatalf("Failed to reserve : %s\n", err.Error())
+		}
+
+		for key, present := range map[op.Key]bool{"a": true, "b": true, "c": false, "d": false} {
+			if _, ok := st.Get(key); ok != present {
+				t.Fatalf("Expected `%s` to be present = %v\n", key, present)
+			}
+		}
+
+		// kept keys can't make room either
+		if err := st.ReserveMany(map[op.Key]int{"g": 6}, []op.Key{"a", "b"}); !errors.Is(err, store.ErrOutOfMemory) {
+			t.Fatalf("Expected out of memory error, received %v\n", err)
+		}
+	}
+}
+
+func TestParseEvictionPolicy(t *testing.T) {
+	for _, policy := range []store.EvictionPolicy{store.NoEviction, store.AllKeysLRU, store.AllKeysLFU, store.VolatileTTL} {
+		parsed, err := store.ParseEvictionPolicy(policy.String())
+		if err != nil || parsed != policy {
+			t.Fatalf("Expected `%s`, received `%s`\n", policy, parsed)
+		}
+	}
+
+	if _, err := store.ParseEvictionPolicy("allkeys-random"); err == nil {
+		t.Fatalf("Expected unknown policy to be rejected\n")
+	}
+}
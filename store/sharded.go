@@ -0,0 +1,246 @@
+package store
+
+import (
+	"hash/maphash"
+	"sync/atomic"
+
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/op"
+)
+
+// Sharded partitions keys over N maps by hash of key, each guarded by
+// its own lock, so that requests touching different keys rarely contend
+//
+// Max memory is shared by all shards, so a write may evict keys of any
+// shard.
+type Sharded struct {
+	seed   maphash.Seed
+	shards []*Map
+	mem    *memory
+	next   uint64 // shard to evict from first, advanced atomically
+}
+
+// NewSharded creates store with n shards, where n = 0 is taken as 1
+func NewSharded(n uint, clock expiry.Clock, opts ...Option) *Sharded {
+	if n == 0 {
+		n = 1
+	}
+
+	mem, vers := newMemory(opts...), newVersions(clock)
+	shards := make([]*Map, n)
+	for i := range shards {
+		shards[i] = newMap(clock, mem, vers)
+	}
+
+	return &Sharded{seed: maphash.MakeSeed(), shards: shards, mem: mem}
+}
+
+// shard picks map holding key, by hash of key
+func (s *Sharded) shard(key op.Key) *Map {
+	return s.shards[s.index(key)]
+}
+
+func (s *Sharded) index(key op.Key) int {
+	var h maphash.Hash
+	h.SetSeed(s.seed)
+	h.WriteString(string(key))
+
+	return int(h.Sum64() % uint64(len(s.shards)))
+}
+
+func (s *Sharded) Get(key op.Key) (Entry, bool) {
+	return s.shard(key).Get(key)
+}
+
+func (s *Sharded) Set(key op.Key, entry Entry) {
+	s.shard(key).Set(key, entry)
+}
+
+func (s *Sharded) Delete(key op.Key) bool {
+	return s.shard(key).Delete(key)
+}
+
+func (s *Sharded) Update(key op.Key, fn func(Entry, bool) (Entry, bool)) {
+	s.shard(key).Update(key, fn)
+}
+
+func (s *Sharded) SetIf(key op.Key, entry Entry, cond func(Entry, bool) bool) (Entry, bool) {
+	return s.shard(key).SetIf(key, entry, cond)
+}
+
+func (s *Sharded) Modify(key op.Key, fn func(Entry, bool) (Entry, error)) (Entry, error) {
+	return s.shard(key).Modify(key, fn)
+}
+
+// UpdateMany locks shards holding keys in order of their index, so that
+// concurrent calls can't deadlock
+func (s *Sharded) UpdateMany(keys []op.Key, fn func([]Entry, []bool)) {
+	shards, unlock := s.lockAll(keys)
+	defer unlock()
+
+	entries, exist := make([]Entry, len(keys)), make([]bool, len(keys))
+	for i, key := range keys {
+		entries[i], exist[i] = shards[i].lookup(key)
+	}
+
+	fn(entries, exist)
+	for i, key := range keys {
+		shards[i].apply(key, entries[i], exist[i])
+	}
+}
+
+// ModifyMany locks shards like `UpdateMany`
+func (s *Sharded) ModifyMany(keys []op.Key, fn func([]Entry, []bool, []bool) error) error {
+	shards, unlock := s.lockAll(keys)
+	defer unlock()
+
+	entries, exist, dirty := make([]Entry, len(keys)), make([]bool, len(keys)), make([]bool, len(keys))
+	for i, key := range keys {
+		entries[i], exist[i] = shards[i].lookup(key)
+	}
+
+	if err := fn(entries, exist, dirty); err != nil {
+		return err
+	}
+
+	for i, key := range keys {
+		if dirty[i] {
+			shards[i].apply(key, entries[i], exist[i])
+		}
+	}
+
+	return nil
+}
+
+// lockAll locks shards holding keys, in order of their index, returning
+// shard of each key & func unlocking them
+func (s *Sharded) lockAll(keys []op.Key) ([]*Map, func()) {
+	locked := make([]bool, len(s.shards))
+	shards := make([]*Map, len(keys))
+	for i, key := range keys {
+		idx := s.index(key)
+		locked[idx] = true
+		shards[i] = s.shards[idx]
+	}
+
+	for idx, shard := range s.shards {
+		if locked[idx] {
+			shard.lock.Lock()
+		}
+	}
+
+	return shards, func() {
+		for idx, shard := range s.shards {
+			if locked[idx] {
+				shard.lock.Unlock()
+			}
+		}
+	}
+}
+
+// Scan visits shards one after another, so it's not an atomic view of
+// whole store
+func (s *Sharded) Scan(fn func(op.Key, Entry) bool) {
+	for _, shard := range s.shards {
+		stopped := false
+		shard.Scan(func(key op.Key, entry Entry) bool {
+			if !fn(key, entry) {
+				stopped = true
+			}
+
+			return !stopped
+		})
+
+		if stopped {
+			return
+		}
+	}
+}
+
+// Range merges keys in range of every shard. Like `Scan`, it's not an
+// atomic view of whole store.
+func (s *Sharded) Range(start, end op.Key, limit int) []op.Key {
+	lists := make([][]op.Key, len(s.shards))
+	for i, shard := range s.shards {
+		lists[i] = shard.Range(start, end, limit)
+	}
+
+	keys := make([]op.Key, 0)
+	for len(keys) < limit {
+		min := -1
+		for i, list := range lists {
+			if len(list) != 0 && (min < 0 || list[0] < lists[min][0]) {
+				min = i
+			}
+		}
+
+		if min < 0 {
+			break
+		}
+
+		keys = append(keys, lists[min][0])
+		lists[min] = lists[min][1:]
+	}
+
+	return keys
+}
+
+func (s *Sharded) Len() int {
+	total := 0
+	for _, shard := range s.shards {
+		total += shard.Len()
+	}
+
+	return total
+}
+
+// Reserve evicts keys from shards in turns, till key fits in max memory
+// with size bytes
+func (s *Sharded) Reserve(key op.Key, size int) error {
+	start := int(atomic.AddUint64(&s.next, 1) % uint64(len(s.shards)))
+	return s.mem.reserve(size-s.shard(key).footprint(key), []op.Key{key}, s.shards, start)
+}
+
+// ReserveMany evicts keys from shards in turns, like `Reserve`, till all
+// of keys fit in max memory with their sizes, where none of them nor
+// any of keep is evicted
+func (s *Sharded) ReserveMany(sizes map[op.Key]int, keep []op.Key) error {
+	n := 0
+	except := make([]op.Key, 0, len(sizes)+len(keep))
+	for key, size := range sizes {
+		n += size - s.shard(key).footprint(key)
+		except = append(except, key)
+	}
+
+	start := int(atomic.AddUint64(&s.next, 1) % uint64(len(s.shards)))
+	return s.mem.reserve(n, append(except, keep...), s.shards, start)
+}
+
+func (s *Sharded) Memory() MemoryStats {
+	return s.mem.stats()
+}
+
+func (s *Sharded) OnEvict(fn func(op.Key)) {
+	for _, shard := range s.shards {
+		shard.OnEvict(fn)
+	}
+}
+
+func (s *Sharded) OnChange(fn func(op.Event)) {
+	for _, shard := range s.shards {
+		shard.OnChange(fn)
+	}
+}
+
+// Sweep sweeps every shard, returning true when any of them is worth
+// sweeping again
+func (s *Sharded) Sweep() bool {
+	again := false
+	for _, shard := range s.shards {
+		if shard.Sweep() {
+			again = true
+		}
+	}
+
+	return again
+}
@@ -0,0 +1,128 @@
+package store_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+func TestSharded(t *testing.T) {
+	clock := expiry.NewManualClock(time.Unix(0, 0))
+	st := store.NewSharded(8, clock)
+
+	for i := 0; i < 100; i++ {
+		var ttl time.Duration
+		if i%4 == 0 {
+			ttl = time.Second
+		}
+
+		st.Set(op.Key(fmt.Sprintf("key-%d", i)), store.Entry{Value: op.Value(fmt.Sprint(i)), TTL: ttl})
+	}
+
+	if st.Len() != 100 {
+		t.Fatalf("Expected 100 keys, found %d\n", st.Len())
+	}
+
+	for i := 0; i < 100; i++ {
+		entry, ok := st.Get(op.Key(fmt.Sprintf("key-%d", i)))
+		if !ok || string(entry.Value) != fmt.Sprint(i) {
+			t.Fatalf("Expected key-%d = %d, received %+v\n", i, i, entry)
+		}
+	}
+
+	clock.Advance(time.Second)
+	for st.Sweep() {
+	}
+
+	if st.Len() != 75 {
+		t.Fatalf("Expected 75 keys after sweeping, found %d\n", st.Len())
+	}
+
+	seen := make(map[op.Key]bool)
+	st.Scan(func(key op.Key, entry store.Entry) bool {
+		seen[key] = true
+		return true
+	})
+
+	if len(seen) != 75 {
+		t.Fatalf("Expected to scan 75 keys, scanned %d\n", len(seen))
+	}
+
+	scanned := 0
+	st.Scan(func(key op.Key, entry store.Entry) bool {
+		scanned++
+		return scanned < 10
+	})
+
+	if scanned != 10 {
+		t.Fatalf("Expected scan to stop after 10 keys, scanned %d\n", scanned)
+	}
+
+	if !st.Delete("key-1") || st.Delete("key-1") || st.Delete("key-0") {
+		t.Fatalf("Expected only live key to be deleted, once\n")
+	}
+
+	if store.NewSharded(0, clock).Len() != 0 {
+		t.Fatalf("Expected empty store\n")
+	}
+}
+
+func BenchmarkMap(b *testing.B) {
+	benchmarkStore(b, store.NewMap(expiry.System))
+}
+
+func BenchmarkSharded(b *testing.B) {
+	benchmarkStore(b, store.NewSharded(16, expiry.System))
+}
+
+// benchmarkStore runs same mix of reads & writes as server benchmarks,
+// from parallel goroutines
+func benchmarkStore(b *testing.B, st store.Store) {
+	keys := make([]op.Key, 256)
+	for i := range keys {
+		keys[i] = op.Key(fmt.Sprintf("%255d", i))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(p *testing.PB) {
+		i := 0
+		for p.Next() {
+			key := keys[i%len(keys)]
+			st.Get(key)
+			st.Set(key, store.Entry{Value: op.Value(key)})
+			i++
+		}
+	})
+}
+
+func TestShardedRange(t *testing.T) {
+	clock := expiry.NewManualClock(time.Unix(0, 0))
+	testRange(t, store.NewSharded(8, clock), clock)
+}
+
+func TestShardedUpdateMany(t *testing.T) {
+	testUpdateMany(t, store.NewSharded(8, expiry.System))
+}
+
+func TestShardedVersions(t *testing.T) {
+	testVersions(t, store.NewSharded(8, expiry.System))
+}
+
+func TestShardedModify(t *testing.T) {
+	testModify(t, store.NewSharded(8, expiry.System))
+}
+
+func TestShardedModifyMany(t *testing.T) {
+	testModifyMany(t, store.NewSharded(8, expiry.System))
+}
+
+func TestShardedChanges(t *testing.T) {
+	clock := expiry.NewManualClock(time.Unix(0, 0))
+	testChanges(t, store.NewSharded(8, clock), clock)
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+const (
+	// skiplistLevels bounds height of nodes, which keeps lookups
+	// logarithmic for up to 4^16 keys
+	skiplistLevels = 16
+	// skiplistP is probability of node reaching next level
+	skiplistP = 0.25
+)
+
+type skipNode struct {
+	key  op.Key
+	next []*skipNode
+}
+
+// skiplist keeps keys in ascending order, so that they can be listed
+// from any key onwards.
+//
+// Like expiry table, it's not safe for concurrent use, rather it's
+// guarded by lock of store it indexes.
+type skiplist struct {
+	head   *skipNode
+	levels int
+	rand   *rand.Rand
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{
+		head:   &skipNode{next: make([]*skipNode, skiplistLevels)},
+		levels: 1,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// path fills prev with last node before key on each level
+func (s *skiplist) path(key op.Key, prev []*skipNode) {
+	node := s.head
+	for level := s.levels - 1; level >= 0; level-- {
+		for node.next[level] != nil && node.next[level].key < key {
+			node = node.next[level]
+		}
+
+		prev[level] = node
+	}
+}
+
+// insert adds key, unless it's present already
+func (s *skiplist) insert(key op.Key) {
+	prev := make([]*skipNode, skiplistLevels)
+	s.path(key, prev)
+	if next := prev[0].next[0]; next != nil && next.key == key {
+		return
+	}
+
+	levels := 1
+	for levels < skiplistLevels && s.rand.Float64() < skiplistP {
+		levels++
+	}
+
+	for ; s.levels < levels; s.levels++ {
+		prev[s.levels] = s.head
+	}
+
+	node := &skipNode{key: key, next: make([]*skipNode, levels)}
+	for level := 0; level < levels; level++ {
+		node.next[level] = prev[level].next[level]
+		prev[level].next[level] = node
+	}
+}
+
+// delete removes key, if it's present
+func (s *skiplist) delete(key op.Key) {
+	prev := make([]*skipNode, skiplistLevels)
+	s.path(key, prev)
+
+	node := prev[0].next[0]
+	if node == nil || node.key != key {
+		return
+	}
+
+	for level := range node.next {
+		prev[level].next[level] = node.next[level]
+	}
+
+	for s.levels > 1 && s.head.next[s.levels-1] == nil {
+		s.levels--
+	}
+}
+
+// seek returns first node holding key not less than key, nil when
+// there's none
+func (s *skiplist) seek(key op.Key) *skipNode {
+	node := s.head
+	for level := s.levels - 1; level >= 0; level-- {
+		for node.next[level] != nil && node.next[level].key < key {
+			node = node.next[level]
+		}
+	}
+
+	return node.next[0]
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"time"
+
+	"github.com/itzmeanjan/tseep/op"
+)
+
+// Type tells how value of entry is to be interpreted
+type Type uint8
+
+const (
+	String Type = iota // value is a byte string, stored as is
+	List               // value is an `op.List`, stored encoded
+)
+
+// Entry is what's stored against some key
+type Entry struct {
+	Value op.Value
+	// Type of value, where writes of byte strings replace value of any
+	// type
+	Type Type
+	// TTL is time left till entry expires, zero for entry which never
+	// expires. Writing entry with negative TTL makes key expire right
+	// away.
+	TTL time.Duration
+	// Version is assigned by store on every write of key, so that it's
+	// ignored when writing. Versions only increase, even across deletes.
+	Version uint64
+}
+
+// Store is storage engine, servers keep key-value pairs in. Expired
+// entries must be invisible to all of its methods, except `Len`.
+//
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Get returns entry of key, with false when key doesn't exist
+	Get(key op.Key) (Entry, bool)
+	// Set stores entry against key, replacing earlier one, if any
+	Set(key op.Key, entry Entry)
+	// Delete removes key, returning whether it existed
+	Delete(key op.Key) bool
+	// Update atomically replaces entry of key with one returned by fn,
+	// which is given current entry & whether key exists. Key is deleted,
+	// when fn returns false.
+	Update(key op.Key, fn func(entry Entry, ok bool) (Entry, bool))
+	// SetIf atomically stores entry against key, only when cond holds for
+	// current entry & whether key exists. It returns entry of key
+	// afterwards & whether it got stored. Store is left untouched, when
+	// cond doesn't hold.
+	SetIf(key op.Key, entry Entry, cond func(current Entry, ok bool) bool) (Entry, bool)
+	// Modify atomically stores entry returned by fn against key, where fn
+	// is given current entry & whether key exists. It returns stored
+	// entry. Store is left untouched, when fn fails, with its error
+	// returned.
+	Modify(key op.Key, fn func(current Entry, ok bool) (Entry, error)) (Entry, error)
+	// UpdateMany atomically updates distinct keys, like `Update`. Fn is
+	// given current entries & whether each key exists, which it updates
+	// in place. Keys, which don't exist afterwards, are deleted.
+	UpdateMany(keys []op.Key, fn func(entries []Entry, exist []bool))
+	// ModifyMany atomically updates distinct keys, like `UpdateMany`,
+	// where only keys fn marks dirty are written. Store is left untouched,
+	// when fn fails, with its error returned.
+	ModifyMany(keys []op.Key, fn func(entries []Entry, exist []bool, dirty []bool) error) error
+	// Scan calls fn for each entry, till it returns false. Store must not
+	// be accessed from within fn.
+	Scan(fn func(key op.Key, entry Entry) bool)
+	// Range returns at most limit keys k, such that start <= k < end, in
+	// ascending order, where empty end denotes no upper bound
+	Range(start, end op.Key, limit int) []op.Key
+	// Len returns number of keys, including expired ones, which aren't
+	// reclaimed yet
+	Len() int
+	// Reserve makes room for key to take size bytes, counting both key &
+	// value, before it's written. When max memory is set, other keys are
+	// evicted as per policy, failing with `ErrOutOfMemory` when none can
+	// be. Concurrent writes may overshoot max memory by their size.
+	Reserve(key op.Key, size int) error
+	// ReserveMany makes room for distinct keys to take their sizes in
+	// bytes all at once, like `Reserve`, before they're written together.
+	// None of them, nor any of keep, is evicted to make room for others.
+	ReserveMany(sizes map[op.Key]int, keep []op.Key) error
+	// Memory returns memory accounting of store
+	Memory() MemoryStats
+}
+
+// Sweeper is implemented by stores, which need expired keys to be
+// reclaimed in background. Sweep reclaims some of them, returning true
+// when it's worth sweeping again right away.
+type Sweeper interface {
+	Sweep() bool
+}
+
+// Saver is implemented by stores, which can save snapshot of their
+// entries. Save returns once snapshot is written.
+type Saver interface {
+	Save() error
+}
+
+// Evicter is implemented by stores, which can evict keys. Fn is called
+// with each evicted key, while that key is locked.
+type Evicter interface {
+	OnEvict(fn func(key op.Key))
+}
+
+// Notifier is implemented by stores, which can tell about changes they
+// apply. Fn is called with every write, deletion, expiry & eviction of
+// key, while that key is locked, so events of one key are passed in
+// order. Expired keys are reported once they're reclaimed, not as soon
+// as their TTL runs out.
+type Notifier interface {
+	OnChange(fn func(ev op.Event))
+}
@@ -0,0 +1,98 @@
+package v1_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/itzmeanjan/tseep/config"
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/store"
+	v1 "github.com/itzmeanjan/tseep/v1"
+)
+
+// countingStore holds up every READ, till it's released, keeping track
+// of how many are served at once
+type countingStore struct {
+	store.Store
+	entered chan struct{}
+	release chan struct{}
+	active  int64
+	most    int64
+}
+
+func (c *countingStore) Get(key op.Key) (store.Entry, bool) {
+	active := atomic.AddInt64(&c.active, 1)
+	defer atomic.AddInt64(&c.active, -1)
+
+	for {
+		most := atomic.LoadInt64(&c.most)
+		if active <= most || atomic.CompareAndSwapInt64(&c.most, most, active) {
+			break
+		}
+	}
+
+	c.entered <- struct{}{}
+	<-c.release
+	return c.Store.Get(key)
+}
+
+// TestInFlightV1 pipelines more tagged requests, than may be served at
+// once, where rest of them aren't read, till a slot is free
+func TestInFlightV1(t *testing.T) {
+	proto := "tcp"
+	addr := "127.0.0.1:0"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	count := 8
+	st := &countingStore{Store: store.NewMap(expiry.System), entered: make(chan struct{}, count), release: make(chan struct{})}
+	server, err := v1.New(ctx, proto, addr, st, config.WithMaxInFlight(2))
+	if err != nil {
+		t.Fatalf("Failed to start TCP server : %s\n", err.Error())
+	}
+
+	conn, err := net.Dial(proto, server.Addr())
+	if err != nil {
+		t.Fatalf("Failed to dial TCP server : %s\n", err.Error())
+	}
+	defer conn.Close()
+
+	key := op.Key("key")
+	w := new(bytes.Buffer)
+	for i := 0; i < count; i++ {
+		req := op.ReadRequest{Header: op.Header{Tagged: true, ID: uint32(i)}, Key: &key}
+		req.WriteEnvelope(w)
+		req.WriteTo(w)
+	}
+
+	if _, err := conn.Write(w.Bytes()); err != nil {
+		t.Fatalf("Failed to write request : %s\n", err.Error())
+	}
+
+	for i := 0; i < 2; i++ {
+		<-st.entered
+	}
+
+	select {
+	case <-st.entered:
+		t.Fatalf("Expected at most 2 requests in flight\n")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(st.release)
+	for i := 0; i < count; i++ {
+		if _, _, err := new(op.Value).ReadFrame(conn); !errors.Is(err, op.ErrNotFound) {
+			t.Fatalf("Expected not found response, received %v\n", err)
+		}
+	}
+
+	if most := atomic.LoadInt64(&st.most); most != 2 {
+		t.Fatalf("Expected 2 requests served at once, found %d\n", most)
+	}
+}
@@ -1,45 +1,185 @@
 package v1
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"io"
 	"log"
 	"net"
+	"os"
 	"sync"
+	"time"
 
+	"github.com/itzmeanjan/tseep/config"
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/handler"
+	"github.com/itzmeanjan/tseep/limit"
 	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/pubsub"
+	"github.com/itzmeanjan/tseep/server"
+	"github.com/itzmeanjan/tseep/store"
 )
 
 type Server struct {
-	Addr     string
 	Listener net.Listener
-	KV       map[op.Key]op.Value
-	Lock     *sync.RWMutex
+	Config   config.Config
+	Store    store.Store
+	Handler  *handler.Handler
+
+	limiter  *limit.Limiter
+	cancel   context.CancelFunc
+	shutdown sync.Once
+	err      error          // why shutdown had to close connections
+	wg       sync.WaitGroup // listener, reaper & context goroutines
+	serving  sync.WaitGroup // connection & writer goroutines
+	lock     sync.Mutex     // guards conns & draining
+	conns    map[net.Conn]struct{}
+	draining bool          // no more connections are taken
+	quit     chan struct{} // closed, once no more requests are to be read
 }
 
-func New(ctx context.Context, proto string, addr string) (*Server, error) {
+func New(ctx context.Context, proto string, addr string, st store.Store, opts ...config.Option) (*Server, error) {
+	cfg := config.New(opts...)
 	lis, err := net.Listen(proto, addr)
 	if err != nil {
 		return nil, err
 	}
 
+	ctx, cancel := context.WithCancel(ctx)
 	srv := Server{
-		KV:       make(map[op.Key]op.Value),
-		Lock:     &sync.RWMutex{},
 		Listener: lis,
-		Addr:     lis.Addr().String(),
+		Config:   cfg,
+		Store:    st,
+		Handler:  handler.New(st),
+		limiter:  limit.New(cfg.MaxConns, cfg.MaxConnsPerIP),
+		cancel:   cancel,
+		conns:    make(map[net.Conn]struct{}),
+		quit:     make(chan struct{}),
 	}
 
 	done := make(chan struct{})
-	go srv.Listen(ctx, done)
+	srv.spawn(func() { srv.Listen(ctx, done) })
 	<-done
 
+	if sw, ok := st.(store.Sweeper); ok {
+		srv.spawn(func() { expiry.Reap(ctx, srv.Config.ReapInterval, sw.Sweep) })
+	}
+
+	// cancelling context closes server right away, without waiting for
+	// requests in flight
+	srv.spawn(func() {
+		<-ctx.Done()
+		srv.shutdown.Do(func() { srv.err = srv.drain(ctx) })
+	})
+
 	return &srv, nil
 }
 
+// Server is registered as mode `v1`, so that it can be started by name
+func init() {
+	server.Register("v1", func(ctx context.Context, proto string, addr string, st store.Store, opts ...config.Option) (server.Server, error) {
+		srv, err := New(ctx, proto, addr, st, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		return srv, nil
+	})
+}
+
+func (s *Server) Addr() string {
+	return s.Listener.Addr().String()
+}
+
+func (s *Server) Stats() []op.Counter {
+	return s.Handler.Stats()
+}
+
+func (s *Server) spawn(fn func()) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		fn()
+	}()
+}
+
+// Shutdown closes listener right away & stops reading requests, while
+// ones in flight are answered, till ctx is done, after which remaining
+// connections are closed. It returns once all goroutines of server have
+// exited, with error of ctx, if connections had to be closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.shutdown.Do(func() { s.err = s.drain(ctx) })
+	s.cancel()
+	s.wg.Wait()
+	return s.err
+}
+
+// drain stops taking connections & requests, waiting for connections to
+// be closed by their readers, once requests in flight are answered
+func (s *Server) drain(ctx context.Context) error {
+	if err := s.Listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Printf("Failed to close listener : %s\n", err.Error())
+	}
+
+	s.lock.Lock()
+	s.draining = true
+	close(s.quit)
+	// readers waiting for next request are woken up
+	for conn := range s.conns {
+		conn.SetReadDeadline(time.Now())
+	}
+	s.lock.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.serving.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+
+	case <-ctx.Done():
+		s.lock.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.lock.Unlock()
+
+		<-drained
+		return ctx.Err()
+	}
+}
+
+// track registers accepted connection, unless server is shutting down
+func (s *Server) track(conn net.Conn) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.draining {
+		return false
+	}
+
+	s.conns[conn] = struct{}{}
+	s.serving.Add(1)
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.lock.Lock()
+	delete(s.conns, conn)
+	s.lock.Unlock()
+
+	s.limiter.Release(conn.RemoteAddr())
+	s.serving.Done()
+}
+
 func (s *Server) Listen(ctx context.Context, done chan struct{}) {
 	close(done)
 	defer func() {
-		if err := s.Listener.Close(); err != nil {
+		if err := s.Listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
 			log.Printf("Failed to close listener : %s\n", err.Error())
 		}
 	}()
@@ -52,73 +192,306 @@ func (s *Server) Listen(ctx context.Context, done chan struct{}) {
 		default:
 			conn, err := s.Listener.Accept()
 			if err != nil {
-				log.Printf("Server not listening : %s\n", err.Error())
+				if !errors.Is(err, net.ErrClosed) {
+					log.Printf("Server not listening : %s\n", err.Error())
+				}
 				return
 			}
 
-			func(conn net.Conn) {
-				go s.handleConnection(ctx, conn)
-			}(conn)
+			if err := s.limiter.Acquire(conn.RemoteAddr()); err != nil {
+				s.Handler.Rejected()
+				limit.Reject(conn, err)
+				continue
+			}
+
+			if !s.track(conn) {
+				s.limiter.Release(conn.RemoteAddr())
+				conn.Close()
+				continue
+			}
+
+			go s.handleConnection(conn)
 		}
 	}
 }
 
-func (s *Server) handleConnection(ctx context.Context, conn net.Conn) {
+func (s *Server) handleConnection(conn net.Conn) {
+	var (
+		inFlight  sync.WaitGroup
+		writeLock sync.Mutex
+	)
+
+	defer s.untrack(conn)
 	defer func() {
-		if err := conn.Close(); err != nil {
+		// connection may have been closed by shutdown already
+		if err := conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
 			log.Printf("Failed to close connection : %s\n", err.Error())
 		}
 	}()
 
+	// no message is published for connection, once it's unsubscribed, so
+	// its writer can be stopped
+	sub, stop, kicked := s.subscriber(conn, &writeLock)
+	defer stop()
+	defer s.Handler.Broker.Leave(sub)
+	defer s.Handler.Unblock(sub)
+
+	// tagged requests being served must be answered, before connection
+	// gets closed
+	defer inFlight.Wait()
+
+	// slot is taken by each tagged request being served, so that reader
+	// stops, while as many as allowed are in flight
+	slots := make(chan struct{}, s.Config.MaxInFlight)
+
+	reply := func(hdr op.Header, resp op.Response) error {
+		writeLock.Lock()
+		defer writeLock.Unlock()
+
+		_, err := op.WriteResponse(conn, hdr, resp)
+		return err
+	}
+
+	// parked request is answered by reader, once it's woken up, while
+	// reader keeps reading connection meanwhile, so that client going
+	// away is noticed. Reading is interrupted by expiring read deadline.
+	var (
+		parkLock sync.Mutex
+		watching bool
+	)
+
+	woken := make(chan op.Response, 1)
+	wake := func(_ op.Header, resp op.Response) {
+		parkLock.Lock()
+		defer parkLock.Unlock()
+
+		woken <- resp
+		if watching {
+			conn.SetReadDeadline(time.Now())
+		}
+	}
+
+	// reader stops, when server is shutting down or slow subscriber is
+	// kicked, both of which expire read deadline
+	stopped := func() bool {
+		select {
+		case <-s.quit:
+			return true
+		case <-kicked:
+			return true
+		default:
+			return false
+		}
+	}
+
+	// deadline set by reader mustn't hide one expired by others, which
+	// is why they're checked afterwards
+	deadline := func(timeout time.Duration) bool {
+		conn.SetReadDeadline(config.Deadline(time.Now(), timeout))
+		return !stopped()
+	}
+
+	// read failing with deadline set by reader means client took too long
+	failed := func(err error) {
+		if errors.Is(err, os.ErrDeadlineExceeded) && !stopped() {
+			s.Handler.TimedOut()
+		}
+	}
+
+	r := bufio.NewReader(conn)
+
+	// park waits till parked request is answered, returning nil, if client
+	// goes away or reader is stopped meanwhile. Bytes sent meanwhile are
+	// kept in reader, for requests to be served afterwards, till it's full,
+	// after which client is no longer watched.
+	park := func() op.Response {
+		parkLock.Lock()
+		watching = true
+		conn.SetReadDeadline(time.Time{})
+		parkLock.Unlock()
+
+		defer func() {
+			parkLock.Lock()
+			watching = false
+			parkLock.Unlock()
+		}()
+
+		for {
+			select {
+			case resp := <-woken:
+				return resp
+			default:
+			}
+
+			if stopped() {
+				return nil
+			}
+
+			// reading past buffered bytes blocks, till client sends more
+			// or goes away
+			_, err := r.Peek(r.Buffered() + 1)
+			if errors.Is(err, bufio.ErrBufferFull) {
+				break
+			}
+
+			if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+				return nil
+			}
+		}
+
+		select {
+		case resp := <-woken:
+			return resp
+		case <-s.quit:
+			return nil
+		case <-kicked:
+			return nil
+		}
+	}
+
 	for {
 		select {
-		case <-ctx.Done():
+		case <-s.quit:
 			return
 
 		default:
-			opcode, _, err := op.ReadEnvelope(conn)
+			idle := s.Config.IdleTimeout
+			if s.Handler.Broker.Subscriptions(sub) != 0 {
+				idle = 0
+			}
+
+			if !deadline(idle) {
+				return
+			}
+
+			if _, err := r.Peek(1); err != nil {
+				failed(err)
+				return
+			}
+
+			if !deadline(s.Config.HeaderTimeout) {
+				return
+			}
+
+			env, err := op.ReadEnvelope(r)
 			if err != nil {
+				failed(err)
 				return
 			}
 
-			switch opcode {
-			case op.READ:
-				rReq := new(op.ReadRequest)
-				if _, err := rReq.ReadFrom(conn); err != nil {
-					return
-				}
+			// body is left unread, so connection can't be used any further
+			if err := env.CheckBodyLen(s.Config.MaxFrameSize); err != nil {
+				reply(env.Header, err)
+				return
+			}
 
-				s.Lock.RLock()
-				val, ok := s.KV[*rReq.Key]
-				if !ok {
-					val = op.Value([]byte(""))
-				}
-				s.Lock.RUnlock()
+			if !deadline(s.Config.BodyTimeout) {
+				return
+			}
 
-				if _, err := val.WriteTo(conn); err != nil {
-					return
-				}
+			body := make([]byte, env.BodyLen)
+			if _, err := io.ReadFull(r, body); err != nil {
+				failed(err)
+				return
+			}
 
-			case op.WRITE:
-				wReq := new(op.WriteRequest)
-				if _, err := wReq.ReadFrom(conn); err != nil {
+			// tagged requests are answered in completion order, so they
+			// don't need to wait for earlier ones. Blocking ones are
+			// served in order, as no other request is served, while
+			// they're parked.
+			if env.Tagged && env.Op != op.BRPOP && cap(slots) != 0 {
+				select {
+				case slots <- struct{}{}:
+				case <-s.quit:
 					return
 				}
 
-				s.Lock.Lock()
-				s.KV[*wReq.Key] = *wReq.Value
-				s.Lock.Unlock()
+				inFlight.Add(1)
+				go func() {
+					defer inFlight.Done()
+					defer func() { <-slots }()
+					reply(env.Header, s.Handler.HandleSubscriber(sub, env, body))
+				}()
+				continue
+			}
 
-				if _, err := wReq.Value.WriteTo(conn); err != nil {
+			resp := s.Handler.HandleBlocking(sub, env, body, wake)
+			if resp == nil {
+				if resp = park(); resp == nil {
 					return
 				}
+			}
 
-			default:
+			if err := reply(env.Header, resp); err != nil {
 				return
+			}
 
+		}
+	}
+
+}
+
+// subscriber creates pub/sub side of connection, whose messages are
+// written by a writer goroutine, started when first one is published.
+// Returned func stops writer, which must be called only after subscriber
+// is removed from all channels. Returned channel is closed, once slow
+// subscriber is kicked.
+func (s *Server) subscriber(conn net.Conn, writeLock *sync.Mutex) (*pubsub.Subscriber, func(), <-chan struct{}) {
+	var (
+		queue  chan []byte
+		start  sync.Once
+		sub    *pubsub.Subscriber
+		kick   sync.Once
+		kicked = make(chan struct{})
+	)
+
+	writer := func() {
+		for frame := range queue {
+			writeLock.Lock()
+			_, err := conn.Write(frame)
+			writeLock.Unlock()
+
+			sub.Sent()
+			if err != nil {
+				return
 			}
+		}
+	}
+
+	send := func(frame []byte) bool {
+		start.Do(func() {
+			queue = make(chan []byte, s.Config.PushQueueSize)
+			// connection is being served, till writer is stopped
+			s.serving.Add(1)
+			go func() {
+				defer s.serving.Done()
+				writer()
+			}()
+		})
 
+		select {
+		case queue <- frame:
+			return true
+		default:
+			return false
 		}
 	}
 
+	stop := func() {
+		// writer may never have been started
+		start.Do(func() {})
+		if queue != nil {
+			close(queue)
+		}
+	}
+
+	// expired deadline unblocks both reader & writer, so that connection
+	// gets closed by its reader
+	disconnect := func() {
+		kick.Do(func() { close(kicked) })
+		conn.SetDeadline(time.Now())
+	}
+
+	sub = pubsub.NewSubscriber(s.Config.PushQueueSize, s.Config.PushPolicy, send, disconnect)
+	return sub, stop, kicked
 }
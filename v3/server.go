@@ -4,58 +4,97 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"io"
 	"log"
 	"net"
 	"sync"
+	"time"
 
+	"github.com/itzmeanjan/tseep/config"
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/handler"
+	"github.com/itzmeanjan/tseep/limit"
 	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/pubsub"
+	"github.com/itzmeanjan/tseep/server"
+	"github.com/itzmeanjan/tseep/store"
 	"github.com/xtaci/gaio"
 	pool "gopkg.in/thejerf/gomempool.v1"
 )
 
 type Server struct {
-	Addr         string
 	Listener     net.Listener
 	WatcherCount uint
 	Watchers     map[uint]*watcher
-	KV           map[op.Key]op.Value
-	KVLock       *sync.RWMutex
 	Pool         *pool.Pool
+	Config       config.Config
+	Store        store.Store
+	Handler      *handler.Handler
+
+	limiter  *limit.Limiter
+	cancel   context.CancelFunc
+	shutdown sync.Once
+	err      error          // why shutdown had to free connections
+	wg       sync.WaitGroup // listener, watcher, reaper & context goroutines
+	conns    sync.WaitGroup // connections, yet to be freed
 }
 
 type watcher struct {
 	eventPool      *gaio.Watcher
 	inProgressRead map[net.Conn]*readingState
 	lock           *sync.RWMutex
+	draining       bool // no more connections are taken, guarded by `lock`
 }
 
+// readBufferSize is size of pooled buffer, each connection reads into
+const readBufferSize = 1 << 16
+
 type readingState struct {
-	allocator    pool.Allocator
-	envelopeRead bool
-	opcode       op.OP
+	allocator     pool.Allocator
+	decoder       *op.Decoder
+	sub           *pubsub.Subscriber
+	wake          func(op.Header, op.Response)
+	pendingWrites int  // responses handed to watcher, yet to be written
+	closing       bool // connection to be freed, once pending responses are written
+	parked        bool // no request is served till parked one is answered
+	reading       bool // read is outstanding, so buffer can't be given back to pool
+	watching      bool // outstanding read was issued while parked, to notice client going away
+	stage         op.Stage
+	since         time.Time // when decoder started awaiting `stage`
 }
 
-func New(ctx context.Context, proto string, addr string, watcherCount uint) (*Server, error) {
+func New(ctx context.Context, proto string, addr string, watcherCount uint, st store.Store, opts ...config.Option) (*Server, error) {
+	cfg := config.New(opts...)
 	lis, err := net.Listen(proto, addr)
 	if err != nil {
 		return nil, err
 	}
 
+	ctx, cancel := context.WithCancel(ctx)
 	srv := Server{
-		KV:           make(map[op.Key]op.Value),
-		KVLock:       &sync.RWMutex{},
-		Addr:         lis.Addr().String(),
 		Listener:     lis,
 		Pool:         pool.New(1<<16, 1<<24, 1<<4),
+		Config:       cfg,
+		Store:        st,
+		Handler:      handler.New(st),
 		WatcherCount: watcherCount,
 		Watchers:     make(map[uint]*watcher),
+		limiter:      limit.New(cfg.MaxConns, cfg.MaxConnsPerIP),
+		cancel:       cancel,
 	}
 
-	watcherChan := make(chan struct{}, watcherCount)
+	// all watchers are created before any is run, as their goroutines
+	// look them up in map
 	var i uint
 	for ; i < srv.WatcherCount; i++ {
 		w, err := gaio.NewWatcher()
 		if err != nil {
+			for _, watcher := range srv.Watchers {
+				watcher.eventPool.Close()
+			}
+
+			lis.Close()
+			cancel()
 			return nil, err
 		}
 
@@ -64,13 +103,17 @@ func New(ctx context.Context, proto string, addr string, watcherCount uint) (*Se
 			inProgressRead: make(map[net.Conn]*readingState),
 			lock:           &sync.RWMutex{},
 		}
+	}
+
+	watcherChan := make(chan struct{}, watcherCount)
+	for i = 0; i < srv.WatcherCount; i++ {
 		func(id uint) {
-			go srv.Watch(ctx, id, watcherChan)
+			srv.spawn(func() { srv.Watch(ctx, id, watcherChan) })
 		}(i)
 	}
 
 	lisChan := make(chan struct{})
-	go srv.Listen(ctx, lisChan)
+	srv.spawn(func() { srv.Listen(ctx, lisChan) })
 	<-lisChan
 
 	running := 0
@@ -81,13 +124,142 @@ func New(ctx context.Context, proto string, addr string, watcherCount uint) (*Se
 		}
 	}
 
+	if sw, ok := st.(store.Sweeper); ok {
+		srv.spawn(func() { expiry.Reap(ctx, srv.Config.ReapInterval, sw.Sweep) })
+	}
+
+	// cancelling context closes server right away, without waiting for
+	// responses to be written
+	srv.spawn(func() {
+		<-ctx.Done()
+		srv.shutdown.Do(func() { srv.err = srv.drain(ctx) })
+	})
+
 	return &srv, nil
 }
 
+// Server is registered as mode `v3`, so that it can be started by name,
+// running as many watchers, as set in config
+func init() {
+	server.Register("v3", func(ctx context.Context, proto string, addr string, st store.Store, opts ...config.Option) (server.Server, error) {
+		srv, err := New(ctx, proto, addr, config.New(opts...).WatcherCount, st, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		return srv, nil
+	})
+}
+
+func (s *Server) Addr() string {
+	return s.Listener.Addr().String()
+}
+
+func (s *Server) Stats() []op.Counter {
+	return s.Handler.Stats()
+}
+
+func (s *Server) spawn(fn func()) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		fn()
+	}()
+}
+
+// Shutdown closes listener right away & stops serving requests, while
+// responses of ones already served are written, till ctx is done, after
+// which remaining connections are freed. It returns once all goroutines
+// of server have exited, with error of ctx, if connections had to be
+// freed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.shutdown.Do(func() { s.err = s.drain(ctx) })
+	s.cancel()
+	s.wg.Wait()
+	return s.err
+}
+
+// drain stops taking connections & serving requests. Connections with no
+// response pending are freed right away, rest by loops of their watchers,
+// once their responses are written. Watchers are closed, when none is
+// left.
+func (s *Server) drain(ctx context.Context) error {
+	if err := s.Listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Printf("Failed to close listener : %s\n", err.Error())
+	}
+
+	for _, watcher := range s.Watchers {
+		watcher.lock.Lock()
+		watcher.draining = true
+		idle := make([]net.Conn, 0, len(watcher.inProgressRead))
+		for conn, v := range watcher.inProgressRead {
+			v.closing = true
+			if v.parked && s.Handler.Unblock(v.sub) {
+				// dropped request is never answered, unlike one woken
+				// up, whose response is being written
+				v.parked = false
+				v.pendingWrites--
+			}
+
+			if v.pendingWrites == 0 {
+				idle = append(idle, conn)
+			}
+		}
+		watcher.lock.Unlock()
+		s.free(idle, watcher)
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		s.conns.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+
+	case <-ctx.Done():
+		for _, watcher := range s.Watchers {
+			watcher.lock.RLock()
+			left := make([]net.Conn, 0, len(watcher.inProgressRead))
+			for conn := range watcher.inProgressRead {
+				left = append(left, conn)
+			}
+			watcher.lock.RUnlock()
+			s.free(left, watcher)
+		}
+
+		<-drained
+		err = ctx.Err()
+	}
+
+	for id, watcher := range s.Watchers {
+		if err := watcher.eventPool.Close(); err != nil {
+			log.Printf("Failed to close watcher : %s [ %d ]\n", err.Error(), id)
+		}
+	}
+
+	return err
+}
+
+// free frees connections of watcher right away, from outside of its loop
+func (s *Server) free(conns []net.Conn, watcher *watcher) {
+	for _, conn := range conns {
+		// outstanding read may still fill buffer, till connection is
+		// freed by watcher
+		if v := s.forget(conn, watcher); v != nil && !v.reading {
+			v.allocator.Return()
+		}
+
+		watcher.eventPool.Free(conn)
+	}
+}
+
 func (s *Server) Listen(ctx context.Context, done chan struct{}) {
 	close(done)
 	defer func() {
-		if err := s.Listener.Close(); err != nil {
+		if err := s.Listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
 			log.Printf("Failed to close listener : %s\n", err.Error())
 		}
 	}()
@@ -101,17 +273,33 @@ func (s *Server) Listen(ctx context.Context, done chan struct{}) {
 		default:
 			conn, err := s.Listener.Accept()
 			if err != nil {
-				log.Printf("Server not listening : %s\n", err.Error())
+				if !errors.Is(err, net.ErrClosed) {
+					log.Printf("Server not listening : %s\n", err.Error())
+				}
 				return
 			}
 
+			if err := s.limiter.Acquire(conn.RemoteAddr()); err != nil {
+				s.Handler.Rejected()
+				limit.Reject(conn, err)
+				continue
+			}
+
 			watcher := s.Watchers[nextWatcher]
 			allocator := s.Pool.GetNewAllocator()
 			watcher.lock.Lock()
-			watcher.inProgressRead[conn] = &readingState{allocator: allocator}
+			if watcher.draining {
+				watcher.lock.Unlock()
+				s.limiter.Release(conn.RemoteAddr())
+				conn.Close()
+				continue
+			}
+
+			watcher.inProgressRead[conn] = &readingState{allocator: allocator, decoder: op.NewDecoder(s.Config.MaxFrameSize), sub: s.subscriber(conn, watcher), wake: s.waker(conn, watcher), reading: true, since: time.Now()}
+			s.conns.Add(1)
 			watcher.lock.Unlock()
 
-			if err := watcher.eventPool.Read(ctx, conn, allocator.Allocate(3)); err != nil {
+			if err := watcher.eventPool.ReadTimeout(ctx, conn, allocator.Allocate(readBufferSize), config.Deadline(time.Now(), s.Config.IdleTimeout)); err != nil {
 				return
 			}
 
@@ -145,19 +333,18 @@ func (s *Server) Watch(ctx context.Context, id uint, done chan struct{}) {
 				switch result.Operation {
 				case gaio.OpRead:
 					if err := s.handleRead(ctx, result, watcher); err != nil {
-						watcher.lock.Lock()
-						delete(watcher.inProgressRead, result.Conn)
-						watcher.lock.Unlock()
+						// failed read leaves none outstanding, so its buffer
+						// can be given back to pool
+						if v := s.forget(result.Conn, watcher); v != nil {
+							v.allocator.Return()
+						}
 
 						watcher.eventPool.Free(result.Conn)
 					}
 
 				case gaio.OpWrite:
 					if err := s.handleWrite(ctx, result, watcher); err != nil {
-						watcher.lock.Lock()
-						delete(watcher.inProgressRead, result.Conn)
-						watcher.lock.Unlock()
-
+						s.forget(result.Conn, watcher)
 						watcher.eventPool.Free(result.Conn)
 					}
 
@@ -168,7 +355,37 @@ func (s *Server) Watch(ctx context.Context, id uint, done chan struct{}) {
 }
 
 func (s *Server) handleRead(ctx context.Context, result gaio.OpResult, watcher *watcher) error {
+	watcher.lock.RLock()
+	defer watcher.lock.RUnlock()
+	v, ok := watcher.inProgressRead[result.Conn]
+	if !ok {
+		if result.Error != nil {
+			return result.Error
+		}
+		return errors.New("unknown connection")
+	}
+
+	watched := v.watching
+	v.reading, v.watching = false, false
 	if result.Error != nil {
+		if errors.Is(result.Error, gaio.ErrDeadline) {
+			// read issued while parked expires early, so that it's
+			// issued again with deadline of connection, once resumed
+			if watched && !v.closing {
+				return s.serve(ctx, result.Conn, v, watcher)
+			}
+
+			// connection took too long to send request
+			s.Handler.TimedOut()
+		}
+
+		if errors.Is(result.Error, io.EOF) && !v.parked && v.pendingWrites != 0 {
+			// peer has shut down its side, though responses queued so
+			// far are still written, before connection is freed
+			v.closing = true
+			return nil
+		}
+
 		return result.Error
 	}
 
@@ -176,65 +393,125 @@ func (s *Server) handleRead(ctx context.Context, result gaio.OpResult, watcher *
 		return errors.New("empty read")
 	}
 
-	watcher.lock.RLock()
-	defer watcher.lock.RUnlock()
-	v := watcher.inProgressRead[result.Conn]
-	if !v.envelopeRead {
-		r := bytes.NewReader(result.Buffer[:])
-		opcode, bodyLen, err := op.ReadEnvelope(r)
-		if err != nil {
-			return err
+	if v.closing {
+		// server is shutting down, so no more requests are served
+		if v.pendingWrites == 0 {
+			return errors.New("closing connection")
 		}
 
-		v.opcode = opcode
-		v.envelopeRead = true
+		return nil
+	}
+
+	v.decoder.Feed(result.Buffer[:result.Size])
+	if v.parked {
+		// requests sent while parked are kept, till parked one is
+		// answered, though reading stops, once decoder holds too many
+		v.decoder.Retain()
+		if v.decoder.Buffered() >= readBufferSize {
+			return nil
+		}
 
-		v.allocator.Return()
-		return watcher.eventPool.Read(ctx, result.Conn, v.allocator.Allocate(uint64(bodyLen)))
+		return s.watch(ctx, result.Conn, v, watcher)
 	}
 
-	r := bytes.NewReader(result.Buffer[:])
+	return s.serve(ctx, result.Conn, v, watcher)
+}
+
+// serve serves all complete requests decoded so far, in order, writing
+// their responses back together, after which next read is issued. It
+// stops at request, which parks connection, leaving rest of requests in
+// decoder, till parked one is answered.
+func (s *Server) serve(ctx context.Context, conn net.Conn, v *readingState, watcher *watcher) error {
 	w := new(bytes.Buffer)
+	for {
+		frame, ok, err := v.decoder.Next()
+		if err != nil {
+			// body is left unread, so connection can't be used any further
+			v.closing = true
+			if _, err := op.WriteResponse(w, frame.Header, err); err != nil {
+				return err
+			}
 
-	switch v.opcode {
-	case op.READ:
-		rReq := new(op.ReadRequest)
-		if _, err := rReq.ReadFrom(r); err != nil {
-			return err
+			break
 		}
 
-		s.KVLock.RLock()
-		val, ok := s.KV[*rReq.Key]
 		if !ok {
-			val = op.Value([]byte(""))
+			break
 		}
-		s.KVLock.RUnlock()
 
-		if _, err := val.WriteTo(w); err != nil {
-			return err
+		resp := s.Handler.HandleBlocking(v.sub, frame.Envelope, frame.Body, v.wake)
+		if resp == nil {
+			// response of parked request is written on its own, counted
+			// as pending already
+			v.parked = true
+			v.pendingWrites++
+			break
 		}
 
-	case op.WRITE:
-		wReq := new(op.WriteRequest)
-		if _, err := wReq.ReadFrom(r); err != nil {
+		if _, err := op.WriteResponse(w, frame.Header, resp); err != nil {
 			return err
 		}
+	}
 
-		s.KVLock.Lock()
-		s.KV[*wReq.Key] = *wReq.Value
-		s.KVLock.Unlock()
-
-		if _, err := wReq.Value.WriteTo(w); err != nil {
+	if w.Len() != 0 {
+		if err := watcher.eventPool.Write(ctx, conn, w.Bytes()); err != nil {
 			return err
 		}
 
-	default:
-		return errors.New("bad opcode")
+		v.pendingWrites++
+	}
+
+	if v.closing {
+		return nil
+	}
+
+	if v.parked {
+		// rest of requests are kept by decoder, as buffer is read into
+		// while parked
+		v.decoder.Retain()
+	}
+
+	// read issued while parked is still outstanding
+	if v.reading {
+		return nil
+	}
 
+	if v.parked {
+		return s.watch(ctx, conn, v, watcher)
 	}
 
-	v.allocator.Return()
-	return watcher.eventPool.Write(ctx, result.Conn, w.Bytes())
+	// decoder keeps bytes of partial frame on its own, so buffer can be
+	// read into again, without waiting for responses to be written
+	v.reading = true
+	return watcher.eventPool.ReadTimeout(ctx, conn, v.allocator.Bytes(), s.deadline(v, w.Len() != 0))
+}
+
+// watch reads parked connection, so that client going away is noticed,
+// freeing it, instead of parked request being answered to no one. Read
+// expires no later than deadline of connection, once it's resumed, as
+// it can't be cancelled.
+func (s *Server) watch(ctx context.Context, conn net.Conn, v *readingState, watcher *watcher) error {
+	v.reading, v.watching = true, true
+	return watcher.eventPool.ReadTimeout(ctx, conn, v.allocator.Bytes(), config.Deadline(time.Now(), s.Config.ShortestTimeout()))
+}
+
+// deadline returns deadline of next read of connection, counted from
+// when decoder started awaiting part of request it's awaiting now, so
+// that trickling bytes don't push it back. Clock is restarted, once any
+// request is served.
+func (s *Server) deadline(v *readingState, served bool) time.Time {
+	if stage := v.decoder.Stage(); served || stage != v.stage {
+		v.stage = stage
+		v.since = time.Now()
+	}
+
+	timeout := s.Config.Timeout(v.stage)
+	// subscribed connection may wait for messages, sending nothing
+	if timeout > 0 && v.stage == op.AwaitingFrame && s.Handler.Broker.Subscriptions(v.sub) != 0 {
+		timeout = 0
+	}
+
+	return config.Deadline(v.since, timeout)
 }
 
 func (s *Server) handleWrite(ctx context.Context, result gaio.OpResult, watcher *watcher) error {
@@ -242,11 +519,97 @@ func (s *Server) handleWrite(ctx context.Context, result gaio.OpResult, watcher
 		return result.Error
 	}
 
+	switch c := result.Context.(type) {
+	case *pubsub.Subscriber:
+		c.Sent()
+		return nil
+
+	case disconnect:
+		return errors.New("slow subscriber")
+
+	}
+
 	watcher.lock.RLock()
 	defer watcher.lock.RUnlock()
 
-	v := watcher.inProgressRead[result.Conn]
-	v.envelopeRead = false
+	v, ok := watcher.inProgressRead[result.Conn]
+	if !ok {
+		return errors.New("unknown connection")
+	}
+
+	v.pendingWrites--
+	if _, ok := result.Context.(resume); ok && !v.closing {
+		// connection waited for parked request, not for client
+		v.parked = false
+		v.since = time.Now()
+		return s.serve(ctx, result.Conn, v, watcher)
+	}
+
+	if v.closing && v.pendingWrites == 0 {
+		// no more reads are issued for closing connection, though one
+		// may be outstanding, if server is shutting down
+		if !v.reading {
+			v.allocator.Return()
+		}
+		return errors.New("closing connection")
+	}
+
+	return nil
+}
+
+// forget removes state of connection, which is being freed, returning it
+// unless it's unknown
+func (s *Server) forget(conn net.Conn, watcher *watcher) *readingState {
+	watcher.lock.Lock()
+	v, ok := watcher.inProgressRead[conn]
+	delete(watcher.inProgressRead, conn)
+	watcher.lock.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	s.conns.Done()
+	s.limiter.Release(conn.RemoteAddr())
+	s.Handler.Broker.Leave(v.sub)
+	s.Handler.Unblock(v.sub)
+	return v
+}
+
+// disconnect is context of write, which frees connection of slow
+// subscriber, once it completes or times out. Connection is freed from
+// loop of its watcher, as only it knows when no read is outstanding.
+type disconnect struct{}
 
-	return watcher.eventPool.Read(ctx, result.Conn, v.allocator.Allocate(3))
+// subscriber creates pub/sub side of connection, whose messages are
+// written using its watcher, as they're published from any watcher, with
+// subscriber as context of write
+func (s *Server) subscriber(conn net.Conn, watcher *watcher) *pubsub.Subscriber {
+	var sub *pubsub.Subscriber
+
+	send := func(frame []byte) bool {
+		return watcher.eventPool.Write(sub, conn, frame) == nil
+	}
+
+	kick := func() {
+		watcher.eventPool.WriteTimeout(disconnect{}, conn, pubsub.QueueFull, time.Now())
+	}
+
+	sub = pubsub.NewSubscriber(s.Config.PushQueueSize, s.Config.PushPolicy, send, kick)
+	return sub
+}
+
+// resume is context of write, which answers parked request of
+// connection. Once it's written, connection is served again by loop of
+// its watcher.
+type resume struct{}
+
+// waker creates func, which writes response of parked request of
+// connection using its watcher, from whichever goroutine it's answered
+func (s *Server) waker(conn net.Conn, watcher *watcher) func(op.Header, op.Response) {
+	return func(hdr op.Header, resp op.Response) {
+		buf := new(bytes.Buffer)
+		op.WriteResponse(buf, hdr, resp)
+		watcher.eventPool.Write(resume{}, conn, buf.Bytes())
+	}
 }
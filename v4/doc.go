@@ -0,0 +1,4 @@
+// Package v4 is TCP server, which runs its own epoll event loops, doing
+// non-blocking I/O with system calls, in place of an event library. It's
+// built on linux only.
+package v4
@@ -0,0 +1,577 @@
+//go:build linux
+// +build linux
+
+package v4
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/itzmeanjan/tseep/config"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/pubsub"
+)
+
+const (
+	// readBufferSize is size of buffer, each loop reads into, for all of
+	// its connections
+	readBufferSize = 1 << 16
+	// maxEvents is how many events are taken from epoll at once
+	maxEvents = 1 << 10
+	// tick is how often read deadlines are checked, when any is set
+	tick = 10 * time.Millisecond
+	// edgeTriggered is EPOLLET, which syscall defines as negative
+	edgeTriggered = 1 << 31
+)
+
+// loop is one epoll event loop, run by its own goroutine, which does all
+// I/O of connections taken by it, in edge triggered mode. Other
+// goroutines hand work over to it by submitting tasks, which it runs in
+// between events.
+type loop struct {
+	srv      *Server
+	epfd     int
+	pipe     [2]int             // written to, for waking loop up
+	conns    map[int]*conn      // by file descriptor
+	timed    map[*conn]struct{} // connections with read deadline
+	checked  time.Time          // when read deadlines were last checked
+	rbuf     []byte             // connections are read into, one at a time
+	wbuf     bytes.Buffer       // responses of requests served together
+	draining bool               // connections are closed, once responses are written
+
+	lock    sync.Mutex // guards tasks & stopped
+	tasks   []func()
+	stopped bool
+}
+
+type conn struct {
+	fd       int
+	addr     net.Addr
+	decoder  *op.Decoder
+	sub      *pubsub.Subscriber
+	wake     func(op.Header, op.Response)
+	out      []byte // bytes socket didn't take, yet to be written from `off`
+	off      int
+	queued   uint64   // bytes handed to connection for writing, ever
+	written  uint64   // bytes written to socket, ever
+	pushes   []uint64 // `queued` right after each pushed frame, yet to be written
+	closing  bool     // connection to be closed, once pending bytes are written
+	parked   bool     // no request is served, nor read, till parked one is answered
+	hangup   bool     // peer has shut down its side, as reported by EPOLLRDHUP
+	closed   bool
+	stage    op.Stage
+	since    time.Time // when decoder started awaiting `stage`
+	deadline time.Time // zero, when connection may wait as long as it wants
+}
+
+func newLoop(s *Server) (*loop, error) {
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &loop{
+		srv:   s,
+		epfd:  epfd,
+		conns: make(map[int]*conn),
+		timed: make(map[*conn]struct{}),
+		rbuf:  make([]byte, readBufferSize),
+	}
+
+	if err := syscall.Pipe2(l.pipe[:], syscall.O_NONBLOCK|syscall.O_CLOEXEC); err != nil {
+		syscall.Close(epfd)
+		return nil, err
+	}
+
+	// pipe is level triggered, so it's enough to drain it, whenever it's
+	// reported readable
+	ev := syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: int32(l.pipe[0])}
+	if err := syscall.EpollCtl(epfd, syscall.EPOLL_CTL_ADD, l.pipe[0], &ev); err != nil {
+		l.close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// close closes file descriptors of loop, once it's stopped
+func (l *loop) close() {
+	syscall.Close(l.pipe[0])
+	syscall.Close(l.pipe[1])
+	syscall.Close(l.epfd)
+}
+
+// submit hands task over to loop, reporting whether it's going to be
+// run, which it's not, once loop is stopped. Loop is woken up, unless
+// tasks submitted earlier are yet to be run.
+func (l *loop) submit(task func()) bool {
+	l.lock.Lock()
+	if l.stopped {
+		l.lock.Unlock()
+		return false
+	}
+
+	l.tasks = append(l.tasks, task)
+	wake := len(l.tasks) == 1
+	l.lock.Unlock()
+
+	if wake {
+		// full pipe already wakes loop up
+		syscall.Write(l.pipe[1], []byte{0})
+	}
+
+	return true
+}
+
+// stop is task, after which loop exits
+func (l *loop) stop() {
+	l.lock.Lock()
+	l.stopped = true
+	l.lock.Unlock()
+}
+
+func (l *loop) run() {
+	defer l.close()
+
+	events := make([]syscall.EpollEvent, maxEvents)
+	for {
+		n, err := syscall.EpollWait(l.epfd, events, l.timeout())
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+
+			log.Printf("Event loop stopped : %s\n", err.Error())
+			return
+		}
+
+		for i := 0; i < n; i++ {
+			fd := int(events[i].Fd)
+			if fd == l.pipe[0] {
+				l.drainPipe()
+				continue
+			}
+
+			c, ok := l.conns[fd]
+			if !ok {
+				continue
+			}
+
+			ev := events[i].Events
+			if ev&(syscall.EPOLLERR|syscall.EPOLLHUP) != 0 {
+				// connection is reset, so nothing can be written to it
+				l.free(c)
+				continue
+			}
+
+			if ev&syscall.EPOLLOUT != 0 {
+				l.flush(c)
+			}
+
+			if ev&syscall.EPOLLRDHUP != 0 {
+				c.hangup = true
+			}
+
+			if ev&(syscall.EPOLLIN|syscall.EPOLLRDHUP) != 0 {
+				l.input(c)
+			}
+		}
+
+		l.expire()
+		if !l.runTasks() {
+			return
+		}
+	}
+}
+
+// timeout returns how long loop may wait for events, in milliseconds,
+// where it waits forever, unless some read deadline is to be checked
+func (l *loop) timeout() int {
+	if len(l.timed) == 0 {
+		return -1
+	}
+
+	return int(tick / time.Millisecond)
+}
+
+func (l *loop) drainPipe() {
+	var buf [64]byte
+	for {
+		if n, err := syscall.Read(l.pipe[0], buf[:]); n <= 0 && err != syscall.EINTR {
+			return
+		}
+	}
+}
+
+// runTasks runs tasks submitted so far, reporting whether loop is to keep
+// running
+func (l *loop) runTasks() bool {
+	l.lock.Lock()
+	tasks := l.tasks
+	l.tasks = nil
+	l.lock.Unlock()
+
+	for _, task := range tasks {
+		task()
+	}
+
+	// only loop itself sets it
+	return !l.stopped
+}
+
+// add starts serving connection, whose file descriptor is taken over by
+// loop
+func (l *loop) add(fd int, addr net.Addr) {
+	c := &conn{fd: fd, addr: addr, decoder: op.NewDecoder(l.srv.Config.MaxFrameSize), since: time.Now()}
+	c.sub = l.subscriber(c)
+	c.wake = l.waker(c)
+
+	ev := syscall.EpollEvent{Events: syscall.EPOLLIN | syscall.EPOLLOUT | syscall.EPOLLRDHUP | edgeTriggered, Fd: int32(fd)}
+	if err := syscall.EpollCtl(l.epfd, syscall.EPOLL_CTL_ADD, fd, &ev); err != nil {
+		log.Printf("Failed to watch connection : %s\n", err.Error())
+		syscall.Close(fd)
+		l.srv.forget(c)
+		return
+	}
+
+	l.conns[fd] = c
+	if l.draining {
+		c.closing = true
+		l.settle(c)
+		return
+	}
+
+	// bytes which arrived before connection was watched are reported
+	// too, though it costs nothing to look for them
+	l.input(c)
+}
+
+// input reads connection till socket has no more bytes, serving requests
+// as they're decoded. Edge triggered epoll doesn't report connection
+// again, till more bytes arrive, so if reading stops early, as request
+// parks connection, it's resumed explicitly.
+func (l *loop) input(c *conn) {
+	served := false
+	for !c.closing && !c.parked {
+		n, err := syscall.Read(c.fd, l.rbuf)
+		if err == syscall.EINTR {
+			continue
+		}
+
+		if err == syscall.EAGAIN {
+			break
+		}
+
+		if err != nil {
+			l.free(c)
+			return
+		}
+
+		if n == 0 {
+			// peer has shut down its side, though responses queued so far
+			// are still written, before connection is closed
+			c.closing, c.hangup = true, true
+			break
+		}
+
+		// frames are decoded right out of read buffer, which is reused
+		// only once they're served
+		c.decoder.Feed(l.rbuf[:n])
+		if l.serve(c) {
+			served = true
+		}
+
+		if c.closed {
+			return
+		}
+	}
+
+	// parked connection isn't read, so peer going away is known only from
+	// hangup, after which parked request would be answered to no one
+	if c.parked && c.hangup {
+		l.free(c)
+		return
+	}
+
+	l.arm(c, served)
+	l.settle(c)
+}
+
+// serve serves all complete requests decoded so far, in order, writing
+// their responses back together, reporting whether any was served. It
+// stops at request, which parks connection, leaving rest of requests in
+// decoder, till parked one is answered.
+func (l *loop) serve(c *conn) bool {
+	l.wbuf.Reset()
+	for {
+		frame, ok, err := c.decoder.Next()
+		if err != nil {
+			// body is left unread, so connection can't be used any further
+			c.closing = true
+			op.WriteResponse(&l.wbuf, frame.Header, err)
+			break
+		}
+
+		if !ok {
+			break
+		}
+
+		resp := l.srv.Handler.HandleBlocking(c.sub, frame.Envelope, frame.Body, c.wake)
+		if resp == nil {
+			// read buffer is reused by other connections meanwhile
+			c.parked = true
+			c.decoder.Retain()
+			break
+		}
+
+		op.WriteResponse(&l.wbuf, frame.Header, resp)
+	}
+
+	if l.wbuf.Len() == 0 {
+		return false
+	}
+
+	l.write(c, l.wbuf.Bytes(), false)
+	return true
+}
+
+// arm sets deadline of connection, which waits for more bytes, counted
+// from when decoder started awaiting part of request it's awaiting now,
+// so that trickling bytes don't push it back. Clock is restarted, once
+// any request is served.
+func (l *loop) arm(c *conn, served bool) {
+	if c.closed {
+		return
+	}
+
+	if c.closing || c.parked {
+		delete(l.timed, c)
+		return
+	}
+
+	if stage := c.decoder.Stage(); served || stage != c.stage {
+		c.stage = stage
+		c.since = time.Now()
+	}
+
+	timeout := l.srv.Config.Timeout(c.stage)
+	// subscribed connection may wait for messages, sending nothing
+	if timeout > 0 && c.stage == op.AwaitingFrame && l.srv.Handler.Broker.Subscriptions(c.sub) != 0 {
+		timeout = 0
+	}
+
+	if c.deadline = config.Deadline(c.since, timeout); c.deadline.IsZero() {
+		delete(l.timed, c)
+		return
+	}
+
+	l.timed[c] = struct{}{}
+}
+
+// expire closes connections, whose deadline has passed, looking at them
+// at most once per tick
+func (l *loop) expire() {
+	if len(l.timed) == 0 {
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(l.checked) < tick {
+		return
+	}
+
+	l.checked = now
+	for c := range l.timed {
+		if now.After(c.deadline) {
+			// connection took too long to send request
+			l.srv.Handler.TimedOut()
+			l.free(c)
+		}
+	}
+}
+
+// write hands bytes over to connection, writing as many as socket takes
+// right away, while rest are copied, to be written once it's writable
+// again. Pushed frame is counted as sent, once it's written completely.
+func (l *loop) write(c *conn, p []byte, push bool) {
+	if c.closed {
+		return
+	}
+
+	c.queued += uint64(len(p))
+	if push {
+		c.pushes = append(c.pushes, c.queued)
+	}
+
+	if c.off == len(c.out) {
+		n, err := send(c.fd, p)
+		if err != nil {
+			l.free(c)
+			return
+		}
+
+		c.written += uint64(n)
+		p = p[n:]
+	}
+
+	c.out = append(c.out, p...)
+	l.sent(c)
+}
+
+// flush writes bytes socket didn't take earlier, as it's writable now
+func (l *loop) flush(c *conn) {
+	if c.off == len(c.out) {
+		return
+	}
+
+	n, err := send(c.fd, c.out[c.off:])
+	if err != nil {
+		l.free(c)
+		return
+	}
+
+	c.written += uint64(n)
+	if c.off += n; c.off == len(c.out) {
+		// large buffer, grown for slow reader, isn't held on to
+		if cap(c.out) > readBufferSize {
+			c.out = nil
+		}
+
+		c.out = c.out[:0]
+		c.off = 0
+	}
+
+	l.sent(c)
+	l.settle(c)
+}
+
+// sent counts pushed frames, which are written completely, as sent
+func (l *loop) sent(c *conn) {
+	for len(c.pushes) != 0 && c.pushes[0] <= c.written {
+		c.sub.Sent()
+		c.pushes = c.pushes[1:]
+	}
+}
+
+// send writes as many bytes as socket takes, without blocking
+func send(fd int, p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		// closed peer is reported as error, not with SIGPIPE
+		n, err := syscall.SendmsgN(fd, p[written:], nil, nil, syscall.MSG_NOSIGNAL)
+		if err == syscall.EINTR {
+			continue
+		}
+
+		if err == syscall.EAGAIN {
+			break
+		}
+
+		if err != nil {
+			return written, err
+		}
+
+		written += n
+	}
+
+	return written, nil
+}
+
+// settle closes connection, which is closing, once nothing is left to be
+// written to it
+func (l *loop) settle(c *conn) {
+	if c.closing && !c.closed && !c.parked && c.off == len(c.out) {
+		l.free(c)
+	}
+}
+
+// free closes connection right away, which also stops it being watched
+func (l *loop) free(c *conn) {
+	if c.closed {
+		return
+	}
+
+	c.closed = true
+	delete(l.conns, c.fd)
+	delete(l.timed, c)
+	syscall.Close(c.fd)
+	l.srv.forget(c)
+}
+
+// drain is task, which stops serving requests of connections, closing
+// those with nothing left to be written. Dropped parked request is never
+// answered, unlike one woken up, whose response is yet to be written.
+func (l *loop) drain() {
+	l.draining = true
+	for _, c := range l.conns {
+		c.closing = true
+		if c.parked && l.srv.Handler.Unblock(c.sub) {
+			c.parked = false
+		}
+
+		delete(l.timed, c)
+		l.settle(c)
+	}
+}
+
+// closeAll is task, which closes all connections, without waiting for
+// their responses to be written
+func (l *loop) closeAll() {
+	for _, c := range l.conns {
+		l.free(c)
+	}
+}
+
+// subscriber creates pub/sub side of connection, whose messages are
+// written by loop, as they're published
+func (l *loop) subscriber(c *conn) *pubsub.Subscriber {
+	send := func(frame []byte) bool {
+		return l.submit(func() { l.write(c, frame, true) })
+	}
+
+	// error frame is written, if socket takes it right away
+	kick := func() {
+		l.submit(func() {
+			l.write(c, pubsub.QueueFull, false)
+			l.free(c)
+		})
+	}
+
+	return pubsub.NewSubscriber(l.srv.Config.PushQueueSize, l.srv.Config.PushPolicy, send, kick)
+}
+
+// waker creates func, which hands response of parked request of
+// connection over to loop, from whichever goroutine it's answered
+func (l *loop) waker(c *conn) func(op.Header, op.Response) {
+	return func(hdr op.Header, resp op.Response) {
+		buf := new(bytes.Buffer)
+		op.WriteResponse(buf, hdr, resp)
+		l.submit(func() { l.resume(c, buf.Bytes()) })
+	}
+}
+
+// resume writes response of parked request, after which rest of requests
+// are served & connection is read again, unless it's closing
+func (l *loop) resume(c *conn, resp []byte) {
+	if c.closed {
+		return
+	}
+
+	c.parked = false
+	l.write(c, resp, false)
+	if c.closing {
+		l.settle(c)
+		return
+	}
+
+	// connection waited for parked request, not for client
+	c.since = time.Now()
+	l.serve(c)
+	if !c.closed {
+		l.input(c)
+	}
+}
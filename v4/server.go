@@ -0,0 +1,283 @@
+//go:build linux
+// +build linux
+
+package v4
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"syscall"
+
+	"github.com/itzmeanjan/tseep/config"
+	"github.com/itzmeanjan/tseep/expiry"
+	"github.com/itzmeanjan/tseep/handler"
+	"github.com/itzmeanjan/tseep/limit"
+	"github.com/itzmeanjan/tseep/op"
+	"github.com/itzmeanjan/tseep/server"
+	"github.com/itzmeanjan/tseep/store"
+)
+
+type Server struct {
+	Listener  net.Listener
+	LoopCount uint
+	Loops     []*loop
+	Config    config.Config
+	Store     store.Store
+	Handler   *handler.Handler
+
+	limiter  *limit.Limiter
+	cancel   context.CancelFunc
+	shutdown sync.Once
+	err      error          // why shutdown had to close connections
+	wg       sync.WaitGroup // listener, loop, reaper & context goroutines
+	conns    sync.WaitGroup // connections, yet to be closed
+	lock     sync.Mutex     // guards draining
+	draining bool           // no more connections are taken
+}
+
+func New(ctx context.Context, proto string, addr string, loopCount uint, st store.Store, opts ...config.Option) (*Server, error) {
+	if loopCount == 0 {
+		return nil, errors.New("at least one event loop is required")
+	}
+
+	cfg := config.New(opts...)
+	lis, err := net.Listen(proto, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	srv := Server{
+		Listener:  lis,
+		LoopCount: loopCount,
+		Loops:     make([]*loop, 0, loopCount),
+		Config:    cfg,
+		Store:     st,
+		Handler:   handler.New(st),
+		limiter:   limit.New(cfg.MaxConns, cfg.MaxConnsPerIP),
+		cancel:    cancel,
+	}
+
+	for i := uint(0); i < loopCount; i++ {
+		l, err := newLoop(&srv)
+		if err != nil {
+			for _, l := range srv.Loops {
+				l.close()
+			}
+
+			lis.Close()
+			cancel()
+			return nil, err
+		}
+
+		srv.Loops = append(srv.Loops, l)
+	}
+
+	for _, l := range srv.Loops {
+		srv.spawn(l.run)
+	}
+
+	lisChan := make(chan struct{})
+	srv.spawn(func() { srv.Listen(ctx, lisChan) })
+	<-lisChan
+
+	if sw, ok := st.(store.Sweeper); ok {
+		srv.spawn(func() { expiry.Reap(ctx, srv.Config.ReapInterval, sw.Sweep) })
+	}
+
+	// cancelling context closes server right away, without waiting for
+	// responses to be written
+	srv.spawn(func() {
+		<-ctx.Done()
+		srv.shutdown.Do(func() { srv.err = srv.drain(ctx) })
+	})
+
+	return &srv, nil
+}
+
+// Server is registered as mode `v4`, so that it can be started by name,
+// running as many event loops, as set in config
+func init() {
+	server.Register("v4", func(ctx context.Context, proto string, addr string, st store.Store, opts ...config.Option) (server.Server, error) {
+		srv, err := New(ctx, proto, addr, config.New(opts...).WatcherCount, st, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		return srv, nil
+	})
+}
+
+func (s *Server) Addr() string {
+	return s.Listener.Addr().String()
+}
+
+func (s *Server) Stats() []op.Counter {
+	return s.Handler.Stats()
+}
+
+func (s *Server) spawn(fn func()) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		fn()
+	}()
+}
+
+// Shutdown closes listener right away & stops serving requests, while
+// responses of ones already served are written, till ctx is done, after
+// which remaining connections are closed. It returns once all goroutines
+// of server have exited, with error of ctx, if connections had to be
+// closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.shutdown.Do(func() { s.err = s.drain(ctx) })
+	s.cancel()
+	s.wg.Wait()
+	return s.err
+}
+
+// drain stops taking connections & serving requests. Each loop closes
+// its connections, once their responses are written, & is stopped, when
+// none is left.
+func (s *Server) drain(ctx context.Context) error {
+	if err := s.Listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Printf("Failed to close listener : %s\n", err.Error())
+	}
+
+	s.lock.Lock()
+	s.draining = true
+	s.lock.Unlock()
+
+	for _, l := range s.Loops {
+		l.submit(l.drain)
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		s.conns.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+
+	case <-ctx.Done():
+		for _, l := range s.Loops {
+			l.submit(l.closeAll)
+		}
+
+		<-drained
+		err = ctx.Err()
+	}
+
+	for _, l := range s.Loops {
+		l.submit(l.stop)
+	}
+
+	return err
+}
+
+func (s *Server) Listen(ctx context.Context, done chan struct{}) {
+	close(done)
+	defer func() {
+		if err := s.Listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Printf("Failed to close listener : %s\n", err.Error())
+		}
+	}()
+
+	var nextLoop uint
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		default:
+			conn, err := s.Listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					log.Printf("Server not listening : %s\n", err.Error())
+				}
+				return
+			}
+
+			addr := conn.RemoteAddr()
+			if err := s.limiter.Acquire(addr); err != nil {
+				s.Handler.Rejected()
+				limit.Reject(conn, err)
+				continue
+			}
+
+			s.lock.Lock()
+			if s.draining {
+				s.lock.Unlock()
+				s.limiter.Release(addr)
+				conn.Close()
+				continue
+			}
+
+			s.conns.Add(1)
+			s.lock.Unlock()
+
+			fd, err := detach(conn)
+			if err != nil {
+				log.Printf("Failed to take over connection : %s\n", err.Error())
+				s.limiter.Release(addr)
+				s.conns.Done()
+				continue
+			}
+
+			// loop is stopped only once all connections are closed, so
+			// it takes this one
+			l := s.Loops[nextLoop]
+			l.submit(func() { l.add(fd, addr) })
+			nextLoop = (nextLoop + 1) % s.LoopCount
+
+		}
+	}
+}
+
+// detach takes file descriptor of accepted connection over from runtime,
+// in non-blocking mode, closing connection itself
+func detach(conn net.Conn) (int, error) {
+	defer conn.Close()
+
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return -1, errors.New("no file descriptor")
+	}
+
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return -1, err
+	}
+
+	fd := -1
+	var dupErr error
+	if err := raw.Control(func(f uintptr) { fd, dupErr = syscall.Dup(int(f)) }); err != nil {
+		return -1, err
+	}
+
+	if dupErr != nil {
+		return -1, dupErr
+	}
+
+	syscall.CloseOnExec(fd)
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return -1, err
+	}
+
+	return fd, nil
+}
+
+// forget releases what's held for connection, which is closed
+func (s *Server) forget(c *conn) {
+	s.conns.Done()
+	s.limiter.Release(c.addr)
+	s.Handler.Broker.Leave(c.sub)
+	s.Handler.Unblock(c.sub)
+}